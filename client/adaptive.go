@@ -0,0 +1,162 @@
+package client
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "sync"
+    "time"
+)
+
+// AdaptiveConcurrency configures WithAdaptiveConcurrency.
+type AdaptiveConcurrency struct {
+    Initial          int           // starting per-host limit; default Max
+    Min              int           // floor; default 1
+    Max              int           // ceiling; default 64
+    LatencyThreshold time.Duration // slower responses count as congestion; 0 disables
+    Backoff          float64       // multiplier applied on congestion; default 0.5
+}
+
+// WithAdaptiveConcurrency limits in-flight attempts per host with an AIMD
+// controller: each successful attempt raises the host's limit by one, and a
+// transport error, 429, 5xx, or response slower than LatencyThreshold
+// multiplies it by Backoff. Attempts the caller canceled, or that ran out its
+// context deadline, leave the limit unchanged, since they say nothing about
+// the host. Only attempts sent after the last decrease can
+// cause another, so a burst of concurrent failures backs off once rather than
+// collapsing the limit to Min. Attempts over the limit wait for a slot before
+// taking one of WithMaxConcurrency's, so a congested host does not hold up
+// requests to others. The current limit is reported as
+// RequestMetrics.ConcurrencyLimit. A host's state is dropped after it has
+// had nothing in flight for adaptiveIdleTTL, so the limit restarts from
+// Initial and hosts contacted once are not remembered forever.
+func WithAdaptiveConcurrency(cfg AdaptiveConcurrency) Option {
+    if cfg.Min <= 0 { cfg.Min = 1 }
+    if cfg.Max <= 0 { cfg.Max = 64 }
+    if cfg.Max < cfg.Min { cfg.Max = cfg.Min }
+    if cfg.Initial <= 0 || cfg.Initial > cfg.Max { cfg.Initial = cfg.Max }
+    if cfg.Initial < cfg.Min { cfg.Initial = cfg.Min }
+    if cfg.Backoff <= 0 || cfg.Backoff >= 1 { cfg.Backoff = 0.5 }
+    return func(c *Client) { c.adaptive = &adaptiveLimiter{cfg: cfg, hosts: map[string]*hostLimit{}} }
+}
+
+// adaptiveIdleTTL is how long a host's limit is kept with nothing in flight.
+const adaptiveIdleTTL = 5 * time.Minute
+
+type adaptiveLimiter struct {
+    mu    sync.Mutex
+    cfg   AdaptiveConcurrency
+    hosts map[string]*hostLimit
+    swept time.Time // last sweep for idle hosts
+}
+
+type hostLimit struct {
+    limit     int
+    inFlight  int
+    waiters   []chan struct{}
+    decreased time.Time // last multiplicative decrease
+    idle      time.Time // when inFlight last dropped to zero
+}
+
+// host returns h's state, creating it if needed. At most once per
+// adaptiveIdleTTL it first drops hosts that have been idle that long.
+func (l *adaptiveLimiter) host(h string) *hostLimit {
+    if now := time.Now(); now.Sub(l.swept) >= adaptiveIdleTTL {
+        l.swept = now
+        for k, hl := range l.hosts {
+            if hl.inFlight == 0 && len(hl.waiters) == 0 && now.Sub(hl.idle) >= adaptiveIdleTTL { delete(l.hosts, k) }
+        }
+    }
+    hl := l.hosts[h]
+    if hl == nil { hl = &hostLimit{limit: l.cfg.Initial}; l.hosts[h] = hl }
+    return hl
+}
+
+// acquire waits until host has room under its current limit.
+func (l *adaptiveLimiter) acquire(ctx context.Context, host string) error {
+    l.mu.Lock()
+    hl := l.host(host)
+    if hl.inFlight < hl.limit && len(hl.waiters) == 0 {
+        hl.inFlight++
+        l.mu.Unlock()
+        return nil
+    }
+    ch := make(chan struct{})
+    hl.waiters = append(hl.waiters, ch)
+    l.mu.Unlock()
+
+    select {
+    case <-ch:
+        return nil
+    case <-ctx.Done():
+        l.mu.Lock(); defer l.mu.Unlock()
+        for i, w := range hl.waiters {
+            if w == ch {
+                hl.waiters = append(hl.waiters[:i], hl.waiters[i+1:]...)
+                return ctx.Err()
+            }
+        }
+        // Admitted while giving up; hand the slot back without judging it.
+        hl.inFlight--
+        l.admit(hl)
+        return ctx.Err()
+    }
+}
+
+// release ends an attempt to host sent at sent, adjusting the limit by its
+// outcome. Congestion seen by attempts sent before the last decrease was
+// already accounted for.
+func (l *adaptiveLimiter) release(host string, sent time.Time, congested bool) {
+    l.mu.Lock(); defer l.mu.Unlock()
+    hl := l.host(host)
+    hl.inFlight--
+    switch {
+    case congested && sent.After(hl.decreased):
+        hl.limit = max(l.cfg.Min, int(float64(hl.limit)*l.cfg.Backoff))
+        hl.decreased = time.Now()
+    case !congested:
+        hl.limit = min(l.cfg.Max, hl.limit+1)
+    }
+    l.admit(hl)
+}
+
+// abandon gives back a slot for an attempt that was never sent, or whose
+// outcome should not move the limit.
+func (l *adaptiveLimiter) abandon(host string) {
+    l.mu.Lock(); defer l.mu.Unlock()
+    hl := l.host(host)
+    hl.inFlight--
+    l.admit(hl)
+}
+
+// settle releases an attempt sent with ctx, judging its outcome unless the
+// caller gave up on it.
+func (l *adaptiveLimiter) settle(ctx context.Context, host string, sent time.Time, resp *http.Response, err error, latency time.Duration) {
+    if err != nil && (ctx.Err() != nil || errors.Is(err, context.Canceled)) {
+        l.abandon(host)
+        return
+    }
+    l.release(host, sent, l.congested(resp, err, latency))
+}
+
+// admit wakes waiters while the host has room, and notes when it goes idle.
+func (l *adaptiveLimiter) admit(hl *hostLimit) {
+    for len(hl.waiters) > 0 && hl.inFlight < hl.limit {
+        close(hl.waiters[0])
+        hl.waiters = hl.waiters[1:]
+        hl.inFlight++
+    }
+    if hl.inFlight == 0 && len(hl.waiters) == 0 { hl.idle = time.Now() }
+}
+
+func (l *adaptiveLimiter) limit(host string) int {
+    l.mu.Lock(); defer l.mu.Unlock()
+    return l.host(host).limit
+}
+
+// congested reports whether an attempt's outcome signals an overloaded host.
+func (l *adaptiveLimiter) congested(resp *http.Response, err error, latency time.Duration) bool {
+    if err != nil { return true }
+    if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 { return true }
+    return l.cfg.LatencyThreshold > 0 && latency > l.cfg.LatencyThreshold
+}
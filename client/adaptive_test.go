@@ -0,0 +1,151 @@
+package client
+
+import (
+    "context"
+    "net/http"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestAdaptiveConcurrencyBacksOffAndRecovers(t *testing.T) {
+    var mu sync.Mutex
+    var limits []int
+    mode := "ok"
+    c := New([]Endpoint{{BaseURL: "http://a"}},
+        WithAdaptiveConcurrency(AdaptiveConcurrency{Max: 8, LatencyThreshold: 20 * time.Millisecond}),
+        WithMetricsHook(func(m RequestMetrics) { mu.Lock(); limits = append(limits, m.ConcurrencyLimit); mu.Unlock() }),
+    )
+    c.retry.MaxAttempts = 1
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            switch mode {
+            case "error":
+                w.WriteHeader(http.StatusServiceUnavailable)
+            case "slow":
+                time.Sleep(30 * time.Millisecond)
+            }
+        }),
+    }}
+    run := func(n int) int {
+        for i := 0; i < n; i++ {
+            req, _ := http.NewRequest(http.MethodGet, "/x", nil)
+            resp, err := c.Do(context.Background(), req)
+            if err == nil { resp.Body.Close() }
+        }
+        mu.Lock(); defer mu.Unlock()
+        return limits[len(limits)-1]
+    }
+
+    if got := run(1); got != 8 { t.Fatalf("expected limit to start at max 8, got %d", got) }
+    mode = "error"
+    if got := run(2); got != 2 { t.Fatalf("expected errors to halve the limit twice to 2, got %d", got) }
+    mode = "ok"
+    if got := run(3); got != 5 { t.Fatalf("expected additive recovery to 5, got %d", got) }
+    mode = "slow"
+    if got := run(1); got != 2 { t.Fatalf("expected latency spike to cut the limit to 2, got %d", got) }
+    mode = "ok"
+    if got := run(10); got != 8 { t.Fatalf("expected full recovery to 8, got %d", got) }
+}
+
+func TestAdaptiveConcurrencyQueuesOverLimit(t *testing.T) {
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithAdaptiveConcurrency(AdaptiveConcurrency{Initial: 1, Max: 1}))
+    release := make(chan struct{})
+    var mu sync.Mutex
+    active, peak := 0, 0
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            mu.Lock(); active++; peak = max(peak, active); mu.Unlock()
+            <-release
+            mu.Lock(); active--; mu.Unlock()
+        }),
+    }}
+    var wg sync.WaitGroup
+    for i := 0; i < 3; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            req, _ := http.NewRequest(http.MethodGet, "/x", nil)
+            if resp, err := c.Do(context.Background(), req); err == nil { resp.Body.Close() }
+        }()
+    }
+    time.Sleep(20 * time.Millisecond)
+    close(release)
+    wg.Wait()
+    if peak != 1 { t.Fatalf("expected at most 1 in-flight attempt, saw %d", peak) }
+}
+
+func TestAdaptiveConcurrencyBacksOffOncePerWindow(t *testing.T) {
+    l := &adaptiveLimiter{cfg: AdaptiveConcurrency{Initial: 8, Min: 1, Max: 8, Backoff: 0.5}, hosts: map[string]*hostLimit{}}
+    for i := 0; i < 4; i++ {
+        if err := l.acquire(context.Background(), "a"); err != nil { t.Fatal(err) }
+    }
+    sent := time.Now()
+    for i := 0; i < 4; i++ { l.release("a", sent, true) }
+    if got := l.limit("a"); got != 4 { t.Fatalf("expected concurrent failures to halve the limit once to 4, got %d", got) }
+    l.acquire(context.Background(), "a")
+    l.release("a", time.Now(), true)
+    if got := l.limit("a"); got != 2 { t.Fatalf("expected a later failure to halve it again to 2, got %d", got) }
+}
+
+func TestAdaptiveConcurrencyDoesNotHoldGlobalSlots(t *testing.T) {
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithMaxConcurrency(2), WithAdaptiveConcurrency(AdaptiveConcurrency{Initial: 1, Max: 1}))
+    c.retry.MaxAttempts = 1
+    release := make(chan struct{})
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-release }),
+        "b": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+    }}
+    var wg sync.WaitGroup
+    for i := 0; i < 3; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            req, _ := http.NewRequest(http.MethodGet, "http://a/slow", nil)
+            if resp, err := c.Do(context.Background(), req); err == nil { resp.Body.Close() }
+        }()
+    }
+    time.Sleep(20 * time.Millisecond) // one request to a in flight, two waiting on its limit
+    ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+    defer cancel()
+    req, _ := http.NewRequest(http.MethodGet, "http://b/fast", nil)
+    resp, err := c.Do(ctx, req)
+    if err != nil { t.Fatalf("expected request to another host to proceed, got %v", err) }
+    resp.Body.Close()
+    close(release)
+    wg.Wait()
+}
+
+func TestAdaptiveConcurrencyIgnoresCallerCancellation(t *testing.T) {
+    l := &adaptiveLimiter{cfg: AdaptiveConcurrency{Initial: 8, Min: 1, Max: 8, Backoff: 0.5}, hosts: map[string]*hostLimit{}}
+    ctx, cancel := context.WithCancel(context.Background())
+    if err := l.acquire(ctx, "a"); err != nil { t.Fatal(err) }
+    cancel()
+    l.settle(ctx, "a", time.Now(), nil, context.Canceled, time.Millisecond)
+    if got := l.limit("a"); got != 8 { t.Fatalf("expected a canceled attempt to leave the limit at 8, got %d", got) }
+
+    dctx, dcancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+    defer dcancel()
+    l.acquire(context.Background(), "a")
+    l.settle(dctx, "a", time.Now(), nil, context.DeadlineExceeded, time.Second)
+    if got := l.limit("a"); got != 8 { t.Fatalf("expected an expired caller deadline to leave the limit at 8, got %d", got) }
+}
+
+func TestAdaptiveConcurrencyEvictsIdleHosts(t *testing.T) {
+    l := &adaptiveLimiter{cfg: AdaptiveConcurrency{Initial: 8, Min: 1, Max: 8, Backoff: 0.5}, hosts: map[string]*hostLimit{}}
+    l.acquire(context.Background(), "a")
+    l.release("a", time.Now(), true)
+    l.acquire(context.Background(), "b")
+    if got := l.limit("a"); got != 4 { t.Fatalf("expected a recently used host to keep its limit, got %d", got) }
+
+    l.mu.Lock()
+    l.hosts["a"].idle = time.Now().Add(-adaptiveIdleTTL)
+    l.swept = time.Time{}
+    l.mu.Unlock()
+    if got := l.limit("b"); got != 8 { t.Fatalf("unexpected limit for busy host b: %d", got) }
+    l.mu.Lock()
+    _, kept := l.hosts["a"]
+    _, busy := l.hosts["b"]
+    l.mu.Unlock()
+    if kept || !busy { t.Fatalf("expected only the idle host to be evicted, a kept=%v b kept=%v", kept, busy) }
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+    "net/http"
+    "sync"
+)
+
+// WithAffinityCookie enables sticky sessions keyed by the named cookie. When an
+// endpoint sets the cookie, the client remembers which endpoint issued that
+// value and routes later requests carrying it back to the same endpoint while
+// it is healthy. At most maxEntries cookie values are tracked; the oldest are
+// forgotten first.
+func WithAffinityCookie(name string, maxEntries int) Option {
+    return func(c *Client) {
+        if maxEntries <= 0 { maxEntries = 1024 }
+        c.affinity = &affinityTable{name: name, max: maxEntries, hosts: map[string]string{}}
+    }
+}
+
+// affinityTable maps affinity cookie values to the host that issued them.
+type affinityTable struct {
+    mu    sync.Mutex
+    name  string
+    max   int
+    hosts map[string]string // cookie value -> host
+    order []string          // insertion order for eviction
+}
+
+// lookup returns the host pinned by the request's affinity cookie, if any.
+func (a *affinityTable) lookup(req *http.Request) string {
+    ck, err := req.Cookie(a.name)
+    if err != nil || ck.Value == "" { return "" }
+    a.mu.Lock(); defer a.mu.Unlock()
+    return a.hosts[ck.Value]
+}
+
+// record remembers affinity cookies set by a response from host.
+func (a *affinityTable) record(host string, resp *http.Response) {
+    for _, ck := range resp.Cookies() {
+        if ck.Name != a.name || ck.Value == "" { continue }
+        a.mu.Lock()
+        if ck.MaxAge < 0 {
+            delete(a.hosts, ck.Value)
+        } else {
+            if _, ok := a.hosts[ck.Value]; !ok {
+                a.order = append(a.order, ck.Value)
+            }
+            a.hosts[ck.Value] = host
+            a.evict()
+        }
+        a.mu.Unlock()
+    }
+}
+
+func (a *affinityTable) evict() {
+    for len(a.hosts) > a.max && len(a.order) > 0 {
+        oldest := a.order[0]
+        a.order = a.order[1:]
+        delete(a.hosts, oldest)
+    }
+    // Drop order entries for values deleted via expiry so the slice stays bounded.
+    if len(a.order) > 2*a.max {
+        kept := a.order[:0]
+        for _, v := range a.order { if _, ok := a.hosts[v]; ok { kept = append(kept, v) } }
+        a.order = kept
+    }
+}
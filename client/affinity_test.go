@@ -0,0 +1,35 @@
+package client
+
+import (
+    "context"
+    "net/http"
+    "sync/atomic"
+    "testing"
+)
+
+func TestAffinityCookieRoutesBack(t *testing.T) {
+    var gotA, gotB int32
+    c := New([]Endpoint{{BaseURL: "http://a"}, {BaseURL: "http://b"}}, WithAffinityCookie("SRV", 16))
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            atomic.AddInt32(&gotA, 1)
+            http.SetCookie(w, &http.Cookie{Name: "SRV", Value: "sess-a"})
+        }),
+        "b": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { atomic.AddInt32(&gotB, 1) }),
+    }}
+
+    req, _ := http.NewRequest(http.MethodGet, "/login", nil)
+    resp, err := c.Do(context.Background(), req)
+    if err != nil { t.Fatalf("do: %v", err) }
+    resp.Body.Close()
+    if gotA != 1 { t.Fatalf("expected first request on A, got A=%d B=%d", gotA, gotB) }
+
+    for i := 0; i < 4; i++ {
+        req, _ := http.NewRequest(http.MethodGet, "/x", nil)
+        req.AddCookie(&http.Cookie{Name: "SRV", Value: "sess-a"})
+        resp, err := c.Do(context.Background(), req)
+        if err != nil { t.Fatalf("do: %v", err) }
+        resp.Body.Close()
+    }
+    if gotA != 5 || gotB != 0 { t.Fatalf("expected sticky routing to A: A=%d B=%d", gotA, gotB) }
+}
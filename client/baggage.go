@@ -0,0 +1,32 @@
+package client
+
+import (
+    "net/http"
+    "strings"
+
+    // ctxutil only depends on the standard library, so this does not pull
+    // the router into client builds.
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+// WithBaggage copies the correlation headers collected by
+// middleware.Baggage from the request context (ctxutil.GetBaggage) onto
+// outgoing requests, so trace and request IDs follow a call across
+// services. Only requests to the client's endpoints, or to one of hosts
+// ("host" or "host:port" as in the URL), carry them, so tenant and request
+// IDs are not sent to third-party APIs reached with absolute URLs. Headers
+// already set on the request are kept.
+func WithBaggage(hosts ...string) Option {
+    allowed := map[string]bool{}
+    for _, h := range hosts { allowed[strings.ToLower(h)] = true }
+    return func(c *Client) {
+        c.interceptors = append(c.interceptors, func(req *http.Request, next Invoker) (*http.Response, error) {
+            if allowed[strings.ToLower(req.URL.Host)] || c.isEndpointHost(req.URL.Host) {
+                for k, v := range ctxutil.GetBaggage(req.Context()) {
+                    if req.Header.Get(k) == "" { req.Header.Set(k, v) }
+                }
+            }
+            return next(req)
+        })
+    }
+}
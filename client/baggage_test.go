@@ -0,0 +1,32 @@
+package client
+
+import (
+    "context"
+    "net/http"
+    "testing"
+
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+func TestBaggage(t *testing.T) {
+    var got http.Header
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithBaggage("partner"))
+    record := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = r.Header.Clone() })
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{"a": record, "partner": record, "third": record}}
+    ctx := ctxutil.WithBaggage(context.Background(), map[string]string{"X-Request-Id": "req-1", "Traceparent": "00-abc-def-01", "X-Tenant-Id": "acme"})
+    req, _ := http.NewRequest(http.MethodGet, "/x", nil)
+    req.Header.Set("X-Tenant-ID", "override")
+    resp, err := c.Do(ctx, req)
+    if err != nil { t.Fatalf("do: %v", err) }
+    resp.Body.Close()
+    if got.Get("X-Request-ID") != "req-1" || got.Get("Traceparent") != "00-abc-def-01" { t.Fatalf("baggage not propagated: %v", got) }
+    if got.Get("X-Tenant-ID") != "override" { t.Fatalf("explicit headers must win, got %q", got.Get("X-Tenant-ID")) }
+
+    for host, want := range map[string]string{"partner": "req-1", "third": ""} {
+        req, _ := http.NewRequest(http.MethodGet, "http://"+host+"/x", nil)
+        resp, err := c.Do(ctx, req)
+        if err != nil { t.Fatalf("do: %v", err) }
+        resp.Body.Close()
+        if got.Get("X-Request-ID") != want { t.Fatalf("%s: expected request ID %q, got %q", host, want, got.Get("X-Request-ID")) }
+    }
+}
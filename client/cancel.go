@@ -0,0 +1,32 @@
+package client
+
+import "context"
+
+// CancelAll cancels every request currently in flight through Do, including
+// responses whose bodies are still being read. Requests started afterwards
+// are unaffected. Use it during shutdown to abort outstanding calls quickly.
+func (c *Client) CancelAll() {
+    c.mu.Lock()
+    cancels := c.inflight
+    c.inflight = nil
+    c.mu.Unlock()
+    for _, cancel := range cancels { cancel() }
+}
+
+// track derives a cancellable context for one Do call and registers it for
+// CancelAll. The returned func cancels and unregisters it.
+func (c *Client) track(ctx context.Context) (context.Context, func()) {
+    ctx, cancel := context.WithCancel(ctx)
+    c.mu.Lock()
+    if c.inflight == nil { c.inflight = map[uint64]context.CancelFunc{} }
+    c.nextID++
+    id := c.nextID
+    c.inflight[id] = cancel
+    c.mu.Unlock()
+    return ctx, func() {
+        c.mu.Lock()
+        delete(c.inflight, id)
+        c.mu.Unlock()
+        cancel()
+    }
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestCancelAll(t *testing.T) {
+    c := New([]Endpoint{{BaseURL: "http://a"}})
+    c.hc.Timeout = 0
+    started := make(chan struct{}, 5)
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            started <- struct{}{}
+            select {
+            case <-r.Context().Done():
+            case <-time.After(5 * time.Second):
+            }
+        }),
+    }}
+
+    errs := make([]error, 5)
+    var wg sync.WaitGroup
+    for i := range errs {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            req, _ := http.NewRequest(http.MethodGet, "/slow", nil)
+            _, errs[i] = c.Do(context.Background(), req)
+        }(i)
+    }
+    for range errs { <-started }
+
+    start := time.Now()
+    c.CancelAll()
+    wg.Wait()
+    if d := time.Since(start); d > time.Second { t.Fatalf("requests took %s to return after CancelAll", d) }
+    for i, err := range errs {
+        if !errors.Is(err, context.Canceled) { t.Fatalf("request %d: expected context.Canceled, got %v", i, err) }
+    }
+
+    req, _ := http.NewRequest(http.MethodGet, "/slow", nil)
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{"a": http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})}}
+    resp, err := c.Do(context.Background(), req)
+    if err != nil { t.Fatalf("request after CancelAll: %v", err) }
+    resp.Body.Close()
+}
@@ -31,8 +31,17 @@ type RetryPolicy struct {
     InitialBackoff            time.Duration
     MaxBackoff                time.Duration
     BackoffJitterFraction     float64 // 0.5 => +/-50%
+    MaxElapsed                time.Duration // total budget across attempts and backoffs; 0 = unlimited
+    RetryIdempotencyKeyed     bool          // also retry any method whose request carries an Idempotency-Key header
 }
 
+// ErrMaxElapsed is returned (wrapped) when RetryPolicy.MaxElapsed is reached.
+var ErrMaxElapsed = errors.New("client: max elapsed time exceeded")
+
+// ErrBodyNotRewindable is returned (wrapped) when an attempt failed after
+// streaming a body that cannot be replayed, so retrying would send it truncated.
+var ErrBodyNotRewindable = errors.New("client: request body cannot be rewound for retry")
+
 // DefaultRetryPolicy returns a conservative default retry policy.
 func DefaultRetryPolicy() RetryPolicy {
     return RetryPolicy{
@@ -51,6 +60,8 @@ func DefaultRetryPolicy() RetryPolicy {
     }
 }
 
+const defaultAccept = "application/json"
+
 // Option configures the Client.
 type Option func(*Client)
 
@@ -63,6 +74,40 @@ func WithRetryPolicy(rp RetryPolicy) Option { return func(c *Client) { c.retry =
 // WithPreferredDC sets a preferred data center label to try first.
 func WithPreferredDC(dc string) Option { return func(c *Client) { c.preferredDC = dc } }
 
+type preferredDCKey struct{}
+
+// ContextWithPreferredDC overrides the client's preferred data center for
+// requests made with the returned context only, e.g. to read your writes from
+// the region that handled the write. An empty dc clears the preference.
+func ContextWithPreferredDC(ctx context.Context, dc string) context.Context {
+    return context.WithValue(ctx, preferredDCKey{}, dc)
+}
+
+// preferredDCFor returns the per-request DC override or the client default.
+func (c *Client) preferredDCFor(ctx context.Context) string {
+    if dc, ok := ctx.Value(preferredDCKey{}).(string); ok { return dc }
+    return c.preferredDC
+}
+
+// WithQueueOnUnavailable makes requests wait, up to maxWait and while their
+// context allows, for an endpoint to come out of backoff when every endpoint is
+// currently unhealthy, smoothing over brief outages instead of failing fast.
+// After maxWait the request proceeds against the least recently tried endpoint.
+func WithQueueOnUnavailable(maxWait time.Duration) Option { return func(c *Client) { c.queueWait = maxWait } }
+
+// WithBufferWarning calls hook whenever a request body larger than threshold
+// bytes has to be buffered in memory so it can be replayed on retries, so
+// operators can spot memory pressure. Bodies with GetBody or a seekable body
+// are never buffered and never trigger the hook.
+func WithBufferWarning(threshold int64, hook func(req *http.Request, size int64)) Option {
+    return func(c *Client) { c.bufferWarnAt, c.bufferWarn = threshold, hook }
+}
+
+// WithMaxBufferedBody caps how many bytes of a body without GetBody or Seek
+// are buffered for retries. Larger bodies are streamed once and the request is
+// not retried after a failure; Do returns ErrBodyNotRewindable instead.
+func WithMaxBufferedBody(n int64) Option { return func(c *Client) { c.maxBuffered = n } }
+
 // WithHeader adds a default header applied to every request (unless already set).
 func WithHeader(k, v string) Option {
     return func(c *Client) {
@@ -77,56 +122,121 @@ func New(endpoints []Endpoint, opts ...Option) *Client {
         endpoints:   make([]Endpoint, len(endpoints)),
         retry:       DefaultRetryPolicy(),
         baseTimeout: 10 * time.Second,
+        done:        make(chan struct{}),
     }
     copy(c.endpoints, endpoints)
     c.bal = newBalancer(c.endpoints)
     c.hc = &http.Client{Timeout: c.baseTimeout, Transport: defaultTransport()}
     c.headers = map[string]string{
         "User-Agent": "httplib-client/1.0",
-        "Accept":     "application/json",
+        "Accept":     defaultAccept,
     }
     for _, opt := range opts { opt(c) }
+    c.applyCodecAccept()
+    c.installPins()
+    c.startBackground()
     return c
 }
 
 // Client is a convenient HTTP client with retry and client-side balancing.
 type Client struct {
-    hc          *http.Client
-    endpoints   []Endpoint
-    bal         *balancer
-    preferredDC string
-    retry       RetryPolicy
-    headers     map[string]string
-    baseTimeout time.Duration
-    mu          sync.Mutex
+    hc           *http.Client
+    endpoints    []Endpoint
+    bal          *balancer
+    preferredDC  string
+    retry        RetryPolicy
+    headers      map[string]string
+    baseTimeout  time.Duration
+    wire         *wireLogger
+    affinity     *affinityTable
+    decoders     map[string]Decompressor
+    interceptors []Interceptor
+    pins         [][]byte
+    queueWait    time.Duration
+    codec        Codec
+    bufferWarnAt int64
+    bufferWarn   func(*http.Request, int64)
+    maxBuffered  int64
+    limiter      *priorityLimiter
+    maxPages     int
+    pingEvery    time.Duration
+    pingPath     string
+    pingTicks    <-chan time.Time // replaces the ping ticker in tests
+    inflight     map[uint64]context.CancelFunc
+    nextID       uint64
+    bodyIdle     time.Duration
+    reqEncoding  string
+    metrics      func(RequestMetrics)
+    tokens       TokenSource
+    adaptive     *adaptiveLimiter
+    resolver     Resolver
+    resolveMin   time.Duration
+    resolveMax   time.Duration
+    done         chan struct{}
+    closeOnce    sync.Once
+    wg           sync.WaitGroup
+    mu           sync.Mutex
 }
 
 // Do sends the HTTP request, applying base URL from a balanced endpoint, default headers,
 // and retry policy. If req.URL is absolute, it is used as-is and no endpoint is selected.
+// The request stays cancellable by CancelAll until the response body is closed.
 func (c *Client) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
-    if ctx != nil {
-        req = req.WithContext(ctx)
+    if ctx == nil { ctx = req.Context() }
+    ctx, untrack := c.track(ctx)
+    if sb, ok := req.Body.(*seekerBody); ok { defer sb.Close() }
+    resp, err := c.do(req.WithContext(ctx))
+    if err != nil {
+        untrack()
+        return nil, err
     }
+    resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: untrack}
+    if c.bodyIdle > 0 { resp.Body = &watchdogBody{ReadCloser: resp.Body, idle: c.bodyIdle, abort: untrack} }
+    return resp, nil
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
     attempts := 0
     var lastErr error
+    start := time.Now()
+    refreshed := false
 
     for {
         attempts++
         // Prepare request for this attempt: apply endpoint if needed and clone body.
         attemptReq, cleanup, err := c.prepareAttempt(req)
         if err != nil { return nil, err }
+        phase := &phaseTracker{}
+        attemptReq = attemptReq.WithContext(phase.trace(context.WithValue(attemptReq.Context(), attemptKey{}, attempts)))
 
         // Default headers (do not override if already present)
         for k, v := range c.headers {
             if attemptReq.Header.Get(k) == "" { attemptReq.Header.Set(k, v) }
         }
+        if len(c.decoders) > 0 && attemptReq.Header.Get("Accept-Encoding") == "" {
+            attemptReq.Header.Set("Accept-Encoding", c.acceptEncoding())
+        }
+
+        token, err := c.applyToken(attemptReq)
+        if err != nil { return nil, err }
 
         // Request-ID: if caller set one in headers, keep it.
 
-        resp, err := c.hc.Do(attemptReq)
+        resp, err := c.send(attemptReq)
+        err = phase.classify(attemptReq, err)
+        // A rejected token is refreshed and the request resent once.
+        if err == nil && resp.StatusCode == http.StatusUnauthorized && token != "" && !refreshed && bodyRewindable(req) {
+            refreshed = true
+            c.tokens.Invalidate(token)
+            resp.Body.Close()
+            if cleanup != nil { cleanup() }
+            continue
+        }
+        if err == nil && c.affinity != nil { c.affinity.record(attemptReq.URL.Host, resp) }
         if err == nil && !c.shouldRetry(attemptReq, resp, nil, attempts) {
+            if c.retry.RetryOnStatuses[resp.StatusCode] { c.bal.markFailure(attemptReq.URL.Host) } else { c.bal.markSuccess(attemptReq.URL.Host) }
             if cleanup != nil { cleanup() }
-            return resp, nil
+            return c.decodeBody(resp)
         }
 
         // Decide retry and update balancer health.
@@ -138,9 +248,17 @@ func (c *Client) Do(ctx context.Context, req *http.Request) (*http.Response, err
             if err != nil { return nil, err }
             return nil, lastErr
         }
+        if !bodyRewindable(req) {
+            if err == nil { err = lastErr }
+            return nil, fmt.Errorf("%w after attempt %d: %v", ErrBodyNotRewindable, attempts, err)
+        }
 
         // Backoff with jitter.
         backoff := backoffWithJitter(c.retry.InitialBackoff, c.retry.MaxBackoff, c.retry.BackoffJitterFraction, attempts-1)
+        if c.retry.MaxElapsed > 0 && time.Since(start)+backoff >= c.retry.MaxElapsed {
+            if err == nil { err = lastErr }
+            return nil, fmt.Errorf("%w after %d attempts: %v", ErrMaxElapsed, attempts, err)
+        }
         select {
         case <-time.After(backoff):
         case <-attemptReq.Context().Done():
@@ -148,12 +266,14 @@ func (c *Client) Do(ctx context.Context, req *http.Request) (*http.Response, err
         }
 
         // On next attempt, choose next endpoint.
-        c.bal.nextHost(c.preferredDC)
+        c.bal.nextHost(c.preferredDCFor(req.Context()))
     }
 }
 
 // prepareAttempt clones the request and applies a base endpoint if req.URL is relative.
-// It also rewinds the body for retries by buffering small bodies in-memory.
+// It also makes the body replayable for retries: bodies that support ReadAt
+// get a section reader per attempt, so an abandoned attempt still being
+// written never shares an offset with the next one; others are buffered.
 func (c *Client) prepareAttempt(req *http.Request) (*http.Request, func(), error) {
     // Clone request shallowly.
     r2 := req.Clone(req.Context())
@@ -161,19 +281,42 @@ func (c *Client) prepareAttempt(req *http.Request) (*http.Request, func(), error
     // Ensure body can be re-read across attempts by buffering if necessary.
     var cleanup func()
     if req.Body != nil {
-        // If GetBody is set, use it; otherwise buffer into memory.
+        // Use GetBody if set; otherwise read seekable bodies through a
+        // section reader per attempt, or buffer into memory.
         if req.GetBody != nil {
             b, err := req.GetBody()
             if err != nil { return nil, nil, err }
             r2.Body = b
+        } else if s, ok := req.Body.(interface{ io.ReaderAt; io.Seeker }); ok {
+            size, err := s.Seek(0, io.SeekEnd)
+            if err != nil { return nil, nil, err }
+            req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(io.NewSectionReader(s, 0, size)), nil }
+            r2.Body, _ = req.GetBody()
+            r2.GetBody = req.GetBody
         } else {
-            data, err := io.ReadAll(req.Body)
+            src := req.Body
+            if c.maxBuffered > 0 { src = io.NopCloser(io.LimitReader(req.Body, c.maxBuffered+1)) }
+            data, err := io.ReadAll(src)
             if err != nil { return nil, nil, err }
-            _ = req.Body.Close()
-            r2.Body = io.NopCloser(bytes.NewReader(data))
-            // reset original req.Body for potential future prepareAttempt calls
-            req.Body = io.NopCloser(bytes.NewReader(data))
-            cleanup = func() {}
+            if c.maxBuffered > 0 && int64(len(data)) > c.maxBuffered {
+                // Too large to keep for retries: stream it once. req keeps the
+                // consumed body without GetBody, so Do will not retry it.
+                r2.Body = struct {
+                    io.Reader
+                    io.Closer
+                }{io.MultiReader(bytes.NewReader(data), req.Body), req.Body}
+            } else {
+                _ = req.Body.Close()
+                if c.bufferWarnAt > 0 && int64(len(data)) > c.bufferWarnAt && c.bufferWarn != nil {
+                    c.bufferWarn(req, int64(len(data)))
+                }
+                r2.Body = io.NopCloser(bytes.NewReader(data))
+                // reset original req.Body and GetBody so later attempts reuse the buffer
+                req.Body = io.NopCloser(bytes.NewReader(data))
+                req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil }
+                r2.GetBody = req.GetBody
+                cleanup = func() {}
+            }
         }
     }
 
@@ -182,8 +325,15 @@ func (c *Client) prepareAttempt(req *http.Request) (*http.Request, func(), error
         return r2, cleanup, nil
     }
 
-    // Choose endpoint and resolve URL
-    base := c.bal.currentBaseURL(c.preferredDC)
+    // Choose endpoint and resolve URL, honoring session affinity first.
+    var base string
+    if c.affinity != nil {
+        if host := c.affinity.lookup(r2); host != "" { base = c.bal.healthyBaseForHost(host) }
+    }
+    if base == "" && c.queueWait > 0 {
+        if err := c.waitForHealthy(req.Context()); err != nil { return nil, cleanup, err }
+    }
+    if base == "" { base = c.bal.currentBaseURL(c.preferredDCFor(req.Context())) }
     if base == "" {
         return nil, cleanup, errors.New("no endpoints configured")
     }
@@ -194,6 +344,54 @@ func (c *Client) prepareAttempt(req *http.Request) (*http.Request, func(), error
     return r2, cleanup, nil
 }
 
+// NewSeekerRequest builds a request whose body is read from body, from
+// offset 0 to its end, instead of being buffered in memory for retries. Each
+// attempt reads through its own io.SectionReader, so an attempt the transport
+// is still reading never races with the next. If body is an io.Closer (e.g.
+// an *os.File), Do closes it once the request is done.
+func NewSeekerRequest(method, path string, body interface{ io.ReaderAt; io.Seeker }) (*http.Request, error) {
+    size, err := body.Seek(0, io.SeekEnd)
+    if err != nil { return nil, err }
+    if _, err := body.Seek(0, io.SeekStart); err != nil { return nil, err }
+    req, err := http.NewRequest(method, path, &seekerBody{SectionReader: io.NewSectionReader(body, 0, size), src: body})
+    if err != nil { return nil, err }
+    req.ContentLength = size
+    req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(io.NewSectionReader(body, 0, size)), nil }
+    return req, nil
+}
+
+// seekerBody is the Body of a NewSeekerRequest request; closing it closes
+// the source.
+type seekerBody struct {
+    *io.SectionReader
+    src any
+}
+
+func (b *seekerBody) Close() error {
+    if c, ok := b.src.(io.Closer); ok { return c.Close() }
+    return nil
+}
+
+// waitForHealthy blocks until some endpoint is healthy, c.queueWait elapses,
+// or ctx is done.
+func (c *Client) waitForHealthy(ctx context.Context) error {
+    deadline := time.Now().Add(c.queueWait)
+    for {
+        next := c.bal.earliestRecovery()
+        if next.IsZero() { return nil }
+        now := time.Now()
+        if !now.Before(deadline) { return nil }
+        if next.After(deadline) { next = deadline }
+        t := time.NewTimer(next.Sub(now))
+        select {
+        case <-t.C:
+        case <-ctx.Done():
+            t.Stop()
+            return ctx.Err()
+        }
+    }
+}
+
 // GetJSON issues a GET to a relative path and unmarshals JSON into out.
 func (c *Client) GetJSON(ctx context.Context, path string, out interface{}) (*http.Response, error) {
     req, _ := http.NewRequest(http.MethodGet, path, nil)
@@ -209,16 +407,29 @@ func (c *Client) GetJSON(ctx context.Context, path string, out interface{}) (*ht
 
 // PostJSON issues a POST with a JSON body and unmarshals JSON into out.
 func (c *Client) PostJSON(ctx context.Context, path string, in, out interface{}) (*http.Response, error) {
-    var body io.ReadCloser
+    return c.sendJSON(ctx, http.MethodPost, path, in, out)
+}
+
+// PutJSON issues a PUT with a JSON body and unmarshals JSON into out.
+func (c *Client) PutJSON(ctx context.Context, path string, in, out interface{}) (*http.Response, error) {
+    return c.sendJSON(ctx, http.MethodPut, path, in, out)
+}
+
+func (c *Client) sendJSON(ctx context.Context, method, path string, in, out interface{}) (*http.Response, error) {
+    var body io.Reader
+    encoding := ""
     if in != nil {
         buf := &bytes.Buffer{}
         if err := json.NewEncoder(buf).Encode(in); err != nil { return nil, err }
-        body = io.NopCloser(bytes.NewReader(buf.Bytes()))
+        data, enc, err := c.compressBody(buf.Bytes())
+        if err != nil { return nil, err }
+        body, encoding = bytes.NewReader(data), enc
     }
-    req, _ := http.NewRequest(http.MethodPost, path, body)
+    req, _ := http.NewRequest(method, path, body)
     if in != nil {
         req.Header.Set("Content-Type", "application/json")
     }
+    if encoding != "" { req.Header.Set("Content-Encoding", encoding) }
     resp, err := c.Do(ctx, req)
     if err != nil { return nil, err }
     defer resp.Body.Close()
@@ -234,13 +445,21 @@ func (c *Client) shouldRetry(req *http.Request, resp *http.Response, err error,
     if attempts >= max(1, c.retry.MaxAttempts) { return false }
     // Respect context cancellation
     if err != nil {
+        if req.Context().Err() != nil { return false }
+        // Timeouts before the request was written are safe to retry for any
+        // method; later ones only for methods that may be retried.
+        var te *TimeoutError
+        if errors.As(err, &te) {
+            if te.Phase == TimeoutConnect { return c.retry.RetryOnConnectionErrors }
+            return c.retryOnMethod(req)
+        }
         if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
             return false
         }
         // Network errors
         var netErr net.Error
         if c.retry.RetryOnConnectionErrors && (errors.As(err, &netErr) || isConnRefused(err) || isNoSuchHost(err)) {
-            return c.retryOnMethod(req.Method)
+            return c.retryOnMethod(req)
         }
         // Other errors: don't retry
         return false
@@ -248,13 +467,28 @@ func (c *Client) shouldRetry(req *http.Request, resp *http.Response, err error,
 
     if resp != nil {
         if c.retry.RetryOnStatuses[resp.StatusCode] {
-            return c.retryOnMethod(req.Method)
+            return c.retryOnMethod(req)
         }
     }
     return false
 }
 
-func (c *Client) retryOnMethod(m string) bool { return c.retry.RetryOnMethods[strings.ToUpper(m)] }
+// retryOnMethod reports whether req's method may be retried. With
+// RetryIdempotencyKeyed, requests carrying an Idempotency-Key header are
+// retried whatever their method, as long as their body can be sent again (see
+// GetBody); the server must honour the key for that to be safe.
+func (c *Client) retryOnMethod(req *http.Request) bool {
+    replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+    if c.retry.RetryIdempotencyKeyed && req.Header.Get("Idempotency-Key") != "" && replayable { return true }
+    return c.retry.RetryOnMethods[strings.ToUpper(req.Method)]
+}
+
+// bodyRewindable reports whether req's body can be sent again in full.
+func bodyRewindable(req *http.Request) bool {
+    if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil { return true }
+    _, ok := req.Body.(io.ReadSeeker)
+    return ok
+}
 
 // defaultTransport returns a tuned http.Transport.
 func defaultTransport() http.RoundTripper {
@@ -294,10 +528,65 @@ type balancer struct {
     mu           sync.Mutex
     failures     map[string]int       // host -> consecutive failures
     unhealthyTil map[string]time.Time // host -> time until considered unhealthy
+    totals       map[string]*HostStat // host -> cumulative counts
+    inflight     map[string]int       // host -> attempts in progress
+    draining     map[string]bool      // removed hosts with attempts still in progress
+    drained      chan struct{}        // closed once draining is empty
 }
 
 func newBalancer(eps []Endpoint) *balancer {
-    return &balancer{eps: eps, failures: map[string]int{}, unhealthyTil: map[string]time.Time{}}
+    drained := make(chan struct{})
+    close(drained)
+    return &balancer{eps: eps, failures: map[string]int{}, unhealthyTil: map[string]time.Time{}, totals: map[string]*HostStat{},
+        inflight: map[string]int{}, draining: map[string]bool{}, drained: drained}
+}
+
+// HostStat is the balancer's view of one endpoint host.
+type HostStat struct {
+    ConsecutiveFailures int
+    UnhealthyUntil      time.Time // zero if the host is healthy
+    Successes           int64     // cumulative successful attempts
+    Failures            int64     // cumulative failed attempts
+    InFlight            int       // attempts currently in progress
+    Draining            bool      // removed by SetEndpoints, finishing in-flight attempts
+}
+
+// HostStats returns a snapshot of per-host health and cumulative
+// success/failure counts, keyed by host, for every configured endpoint and
+// every removed endpoint that is still draining.
+func (c *Client) HostStats() map[string]HostStat { return c.bal.stats() }
+
+func (b *balancer) stats() map[string]HostStat {
+    b.mu.Lock(); defer b.mu.Unlock()
+    out := make(map[string]HostStat, len(b.eps))
+    now := time.Now()
+    hosts := make([]string, 0, len(b.eps)+len(b.draining))
+    for _, e := range b.eps { hosts = append(hosts, hostOf(e.BaseURL)) }
+    for host := range b.draining { hosts = append(hosts, host) }
+    for _, host := range hosts {
+        var st HostStat
+        if t := b.totals[host]; t != nil { st = *t }
+        st.ConsecutiveFailures = b.failures[host]
+        if until := b.unhealthyTil[host]; until.After(now) { st.UnhealthyUntil = until }
+        st.InFlight = b.inflight[host]
+        st.Draining = b.draining[host]
+        out[host] = st
+    }
+    return out
+}
+
+func (b *balancer) total(host string) *HostStat {
+    t := b.totals[host]
+    if t == nil { t = &HostStat{}; b.totals[host] = t }
+    return t
+}
+
+// markSuccess records a successful attempt and clears the host's failure streak.
+func (b *balancer) markSuccess(hostport string) {
+    b.mu.Lock(); defer b.mu.Unlock()
+    b.total(hostport).Successes++
+    b.failures[hostport] = 0
+    delete(b.unhealthyTil, hostport)
 }
 
 // currentBaseURL returns baseURL of next host based on RR and preferred DC, skipping unhealthy.
@@ -326,6 +615,78 @@ func (b *balancer) currentBaseURL(preferredDC string) string {
     return ""
 }
 
+// healthyBaseForHost returns the base URL of the endpoint serving host, or ""
+// if there is none or it is currently unhealthy.
+func (b *balancer) healthyBaseForHost(host string) string {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    for i, e := range b.eps {
+        if hostOf(e.BaseURL) == host && b.isHealthyHostIdx(i) { return e.BaseURL }
+    }
+    return ""
+}
+
+// earliestRecovery returns the zero time if any endpoint is healthy, otherwise
+// the soonest time at which an unhealthy endpoint leaves backoff.
+func (b *balancer) earliestRecovery() time.Time {
+    b.mu.Lock(); defer b.mu.Unlock()
+    var soonest time.Time
+    for i, e := range b.eps {
+        if b.isHealthyHostIdx(i) { return time.Time{} }
+        until := b.unhealthyTil[hostOf(e.BaseURL)]
+        if soonest.IsZero() || until.Before(soonest) { soonest = until }
+    }
+    return soonest
+}
+
+// setEndpoints swaps the endpoint set, keeping health state for known hosts.
+// Removed hosts with attempts in flight are marked draining: they receive no
+// new requests, and their in-flight attempts run to completion.
+func (b *balancer) setEndpoints(eps []Endpoint) {
+    b.mu.Lock(); defer b.mu.Unlock()
+    keep := make(map[string]bool, len(eps))
+    for _, e := range eps { keep[hostOf(e.BaseURL)] = true }
+    for host := range b.draining {
+        if keep[host] { delete(b.draining, host) }
+    }
+    for _, e := range b.eps {
+        if host := hostOf(e.BaseURL); !keep[host] && b.inflight[host] > 0 { b.draining[host] = true }
+    }
+    b.eps = eps
+    b.updateDrained()
+}
+
+// begin records an attempt to host and returns the func that ends it.
+func (b *balancer) begin(host string) func() {
+    b.mu.Lock()
+    b.inflight[host]++
+    b.mu.Unlock()
+    return func() {
+        b.mu.Lock(); defer b.mu.Unlock()
+        if b.inflight[host]--; b.inflight[host] > 0 { return }
+        delete(b.inflight, host)
+        if b.draining[host] {
+            delete(b.draining, host)
+            b.updateDrained()
+        }
+    }
+}
+
+// updateDrained keeps b.drained open exactly while hosts are draining.
+func (b *balancer) updateDrained() {
+    select {
+    case <-b.drained:
+        if len(b.draining) > 0 { b.drained = make(chan struct{}) }
+    default:
+        if len(b.draining) == 0 { close(b.drained) }
+    }
+}
+
+func (b *balancer) drainedChan() <-chan struct{} {
+    b.mu.Lock(); defer b.mu.Unlock()
+    return b.drained
+}
+
 // nextHost advances RR counters to encourage moving to next on next attempt.
 func (b *balancer) nextHost(preferredDC string) {
     b.mu.Lock(); defer b.mu.Unlock()
@@ -354,6 +715,7 @@ func (b *balancer) markFailure(hostport string) {
         host = hostOf(host)
     }
     b.failures[host] = b.failures[host] + 1
+    b.total(host).Failures++
     // Exponential backoff unhealthy period with cap
     base := 500 * time.Millisecond
     n := b.failures[host]
@@ -4,8 +4,10 @@ import (
     "bytes"
     "context"
     "encoding/json"
+    "errors"
     "io"
     "net/http"
+    "strings"
     "sync/atomic"
     "testing"
     "time"
@@ -109,3 +111,258 @@ func TestContextCancelStopsRetries(t *testing.T) {
     _, err := c.Do(ctx, req)
     if err == nil { t.Fatalf("expected error due to timeout") }
 }
+
+// countingSeeker records how often it is rewound.
+type countingSeeker struct {
+    *bytes.Reader
+    seeks int32
+}
+
+func (s *countingSeeker) Seek(off int64, whence int) (int64, error) {
+    atomic.AddInt32(&s.seeks, 1)
+    return s.Reader.Seek(off, whence)
+}
+
+func TestSeekerBodyRewoundBetweenAttempts(t *testing.T) {
+    var calls int32
+    var bodies []string
+    c := New([]Endpoint{{BaseURL: "http://a"}})
+    c.retry.RetryOnMethods[http.MethodPost] = true
+    c.retry.InitialBackoff = time.Millisecond
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            b, _ := io.ReadAll(r.Body)
+            bodies = append(bodies, string(b))
+            if atomic.AddInt32(&calls, 1) == 1 { w.WriteHeader(503); return }
+            w.WriteHeader(200)
+        }),
+    }}
+
+    body := &countingSeeker{Reader: bytes.NewReader([]byte("payload"))}
+    req, err := NewSeekerRequest(http.MethodPost, "/upload", body)
+    if err != nil { t.Fatalf("new request: %v", err) }
+    orig, seeks := req.Body, atomic.LoadInt32(&body.seeks)
+    resp, err := c.Do(context.Background(), req)
+    if err != nil { t.Fatalf("do: %v", err) }
+    resp.Body.Close()
+
+    if len(bodies) != 2 || bodies[0] != "payload" || bodies[1] != "payload" {
+        t.Fatalf("expected full body on both attempts, got %q", bodies)
+    }
+    if req.Body != orig {
+        t.Fatalf("request body was replaced by an in-memory buffer")
+    }
+    if got := atomic.LoadInt32(&body.seeks); got != seeks {
+        t.Fatalf("attempts must read through their own section readers, not rewind the shared seeker; seeks %d -> %d", seeks, got)
+    }
+}
+
+// seekerFile is a file-like body passed straight to http.NewRequest.
+type seekerFile struct{ *countingSeeker }
+
+func (seekerFile) Close() error { return nil }
+
+func TestReaderAtBodyReadPerAttempt(t *testing.T) {
+    var bodies []string
+    c := New([]Endpoint{{BaseURL: "http://a"}})
+    c.retry.RetryOnMethods[http.MethodPost] = true
+    c.retry.InitialBackoff = time.Millisecond
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            b, _ := io.ReadAll(r.Body)
+            bodies = append(bodies, string(b))
+            if len(bodies) == 1 { w.WriteHeader(503) }
+        }),
+    }}
+
+    body := seekerFile{&countingSeeker{Reader: bytes.NewReader([]byte("payload"))}}
+    req, _ := http.NewRequest(http.MethodPost, "/upload", body)
+    resp, err := c.Do(context.Background(), req)
+    if err != nil { t.Fatalf("do: %v", err) }
+    resp.Body.Close()
+    if len(bodies) != 2 || bodies[0] != "payload" || bodies[1] != "payload" { t.Fatalf("expected full body on both attempts, got %q", bodies) }
+    if got := atomic.LoadInt32(&body.seeks); got != 1 { t.Fatalf("expected a single size probe and no rewinds, got %d seeks", got) }
+}
+
+// closeTracker is a file-like body that records Close.
+type closeTracker struct {
+    *bytes.Reader
+    closed int32
+}
+
+func (c *closeTracker) Close() error { atomic.AddInt32(&c.closed, 1); return nil }
+
+func TestSeekerRequestAttemptsReadIndependently(t *testing.T) {
+    var calls int32
+    var stale io.Reader
+    c := New([]Endpoint{{BaseURL: "http://a"}})
+    c.retry.RetryOnMethods[http.MethodPut] = true
+    c.retry.InitialBackoff = time.Millisecond
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if atomic.AddInt32(&calls, 1) == 1 {
+                // Leave the first attempt's body unread, as a transport
+                // still writing it would.
+                stale = r.Body
+                w.WriteHeader(503)
+                return
+            }
+            b, _ := io.ReadAll(r.Body)
+            if string(b) != "payload" { t.Errorf("retry sent %q", b) }
+        }),
+    }}
+    body := &closeTracker{Reader: bytes.NewReader([]byte("payload"))}
+    req, err := NewSeekerRequest(http.MethodPut, "/upload", body)
+    if err != nil { t.Fatalf("new request: %v", err) }
+    resp, err := c.Do(context.Background(), req)
+    if err != nil { t.Fatalf("do: %v", err) }
+    resp.Body.Close()
+    if b, _ := io.ReadAll(stale); string(b) != "payload" { t.Fatalf("first attempt's body was disturbed by the retry, read %q", b) }
+    if atomic.LoadInt32(&body.closed) != 1 { t.Fatalf("expected source to be closed once, closed %d times", body.closed) }
+}
+
+func TestPerRequestPreferredDC(t *testing.T) {
+    var gotEU, gotUS int32
+    c := New([]Endpoint{{BaseURL: "http://a", DC: "eu"}, {BaseURL: "http://b", DC: "us"}}, WithPreferredDC("eu"))
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { atomic.AddInt32(&gotEU, 1) }),
+        "b": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { atomic.AddInt32(&gotUS, 1) }),
+    }}
+    do := func(ctx context.Context) {
+        req, _ := http.NewRequest(http.MethodGet, "/x", nil)
+        resp, err := c.Do(ctx, req)
+        if err != nil { t.Fatalf("do: %v", err) }
+        resp.Body.Close()
+    }
+
+    do(ContextWithPreferredDC(context.Background(), "us"))
+    if gotUS != 1 || gotEU != 0 { t.Fatalf("expected override to route to us: eu=%d us=%d", gotEU, gotUS) }
+    for i := 0; i < 3; i++ { do(context.Background()) }
+    if gotEU != 3 || gotUS != 1 { t.Fatalf("expected default requests to use eu: eu=%d us=%d", gotEU, gotUS) }
+}
+
+func TestHostStatsFailureThenRecovery(t *testing.T) {
+    var fail int32 = 1
+    c := New([]Endpoint{{BaseURL: "http://a"}})
+    c.retry.MaxAttempts = 1
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if atomic.LoadInt32(&fail) == 1 { w.WriteHeader(503); return }
+            w.WriteHeader(200)
+        }),
+    }}
+    do := func() {
+        req, _ := http.NewRequest(http.MethodGet, "/x", nil)
+        if resp, err := c.Do(context.Background(), req); err == nil { resp.Body.Close() }
+    }
+
+    do()
+    st := c.HostStats()["a"]
+    if st.ConsecutiveFailures != 1 || st.Failures != 1 || st.UnhealthyUntil.IsZero() {
+        t.Fatalf("expected failure to be recorded, got %+v", st)
+    }
+
+    atomic.StoreInt32(&fail, 0)
+    do()
+    st = c.HostStats()["a"]
+    if st.ConsecutiveFailures != 0 || !st.UnhealthyUntil.IsZero() || st.Successes != 1 || st.Failures != 1 {
+        t.Fatalf("expected recovery with cumulative counts kept, got %+v", st)
+    }
+}
+
+func TestQueueOnUnavailableWaitsForRecovery(t *testing.T) {
+    c := New([]Endpoint{{BaseURL: "http://a"}, {BaseURL: "http://b"}}, WithQueueOnUnavailable(time.Second))
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(200) }),
+        "b": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(200) }),
+    }}
+    recoverAt := time.Now().Add(50 * time.Millisecond)
+    c.bal.unhealthyTil["a"] = recoverAt
+    c.bal.unhealthyTil["b"] = recoverAt.Add(time.Hour)
+
+    req, _ := http.NewRequest(http.MethodGet, "/x", nil)
+    resp, err := c.Do(context.Background(), req)
+    if err != nil { t.Fatalf("do: %v", err) }
+    resp.Body.Close()
+    if time.Now().Before(recoverAt) { t.Fatalf("request was sent before any endpoint recovered") }
+    if st := c.HostStats()["a"]; st.Successes != 1 { t.Fatalf("expected recovered host a to serve the request, got %+v", c.HostStats()) }
+}
+
+func TestRetryMaxElapsed(t *testing.T) {
+    var calls int32
+    c := New([]Endpoint{{BaseURL: "http://a"}})
+    c.retry.MaxAttempts = 1000
+    c.retry.InitialBackoff = 10 * time.Millisecond
+    c.retry.MaxBackoff = 10 * time.Millisecond
+    c.retry.BackoffJitterFraction = 0
+    c.retry.MaxElapsed = 60 * time.Millisecond
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if atomic.AddInt32(&calls, 1) == 1 { w.WriteHeader(200); return }
+            w.WriteHeader(503)
+        }),
+    }}
+    req, _ := http.NewRequest(http.MethodGet, "/x", nil)
+    resp, err := c.Do(context.Background(), req)
+    if err != nil { t.Fatalf("first request: %v", err) }
+    resp.Body.Close()
+
+    start := time.Now()
+    req, _ = http.NewRequest(http.MethodGet, "/x", nil)
+    _, err = c.Do(context.Background(), req)
+    if !errors.Is(err, ErrMaxElapsed) { t.Fatalf("expected ErrMaxElapsed, got %v", err) }
+    if el := time.Since(start); el > 500*time.Millisecond { t.Fatalf("retry loop ran too long: %s", el) }
+    if n := atomic.LoadInt32(&calls); n < 3 || n > 20 { t.Fatalf("unexpected attempt count %d", n) }
+}
+
+func TestBufferWarningHook(t *testing.T) {
+    var warned []int64
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithBufferWarning(8, func(_ *http.Request, size int64) { warned = append(warned, size) }))
+    c.retry.RetryOnMethods[http.MethodPost] = true
+    c.retry.InitialBackoff = time.Millisecond
+    var calls int32
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if atomic.AddInt32(&calls, 1) == 1 { w.WriteHeader(503) }
+        }),
+    }}
+    post := func(body string) {
+        req, _ := http.NewRequest(http.MethodPost, "/x", io.NopCloser(strings.NewReader(body)))
+        resp, err := c.Do(context.Background(), req)
+        if err != nil { t.Fatalf("do: %v", err) }
+        resp.Body.Close()
+    }
+
+    post("a large request body")
+    if len(warned) != 1 || warned[0] != 20 { t.Fatalf("expected one warning for 20 bytes across retries, got %v", warned) }
+    post("small")
+    if len(warned) != 1 { t.Fatalf("unexpected warning for small body: %v", warned) }
+}
+
+func TestOversizedOneShotBodyIsNotRetried(t *testing.T) {
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithMaxBufferedBody(8))
+    c.retry.RetryOnMethods[http.MethodPost] = true
+    c.retry.InitialBackoff = time.Millisecond
+    var bodies []string
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            b, _ := io.ReadAll(r.Body)
+            bodies = append(bodies, string(b))
+            if len(bodies) == 1 { w.WriteHeader(503) }
+        }),
+    }}
+
+    req, _ := http.NewRequest(http.MethodPost, "/x", io.NopCloser(strings.NewReader("a body larger than the cap")))
+    _, err := c.Do(context.Background(), req)
+    if !errors.Is(err, ErrBodyNotRewindable) { t.Fatalf("expected ErrBodyNotRewindable, got %v", err) }
+    if !strings.Contains(err.Error(), "status 503") { t.Fatalf("expected original failure in error, got %v", err) }
+    if len(bodies) != 1 || bodies[0] != "a body larger than the cap" { t.Fatalf("expected one full attempt, got %q", bodies) }
+
+    // Bodies under the cap are still buffered and retried.
+    bodies = nil
+    req, _ = http.NewRequest(http.MethodPost, "/x", io.NopCloser(strings.NewReader("small")))
+    resp, err := c.Do(context.Background(), req)
+    if err != nil { t.Fatalf("do: %v", err) }
+    resp.Body.Close()
+    if len(bodies) != 2 || bodies[1] != "small" { t.Fatalf("expected a full retry, got %q", bodies) }
+}
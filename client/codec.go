@@ -0,0 +1,76 @@
+package client
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+)
+
+// Codec encodes request bodies and decodes response bodies for Get and Post.
+type Codec interface {
+    ContentType() string
+    Marshal(v any) ([]byte, error)
+    Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec is the default Codec.
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string                { return "application/json" }
+func (JSONCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (JSONCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// WithCodec sets the codec used by Get and Post. Unless the Accept header was
+// changed with WithHeader, the client also advertises the codec's content type
+// in Accept so the server negotiates the same format. Individual requests may
+// still set their own Accept header.
+func WithCodec(codec Codec) Option { return func(c *Client) { c.codec = codec } }
+
+// applyCodecAccept makes the default Accept header follow the configured codec.
+func (c *Client) applyCodecAccept() {
+    if c.codec != nil && c.headers["Accept"] == defaultAccept { c.headers["Accept"] = c.codec.ContentType() }
+}
+
+func (c *Client) codecOrDefault() Codec {
+    if c.codec == nil { return JSONCodec{} }
+    return c.codec
+}
+
+// Get issues a GET to a relative path and decodes the response into out using
+// the client's codec.
+func (c *Client) Get(ctx context.Context, path string, out any) (*http.Response, error) {
+    req, err := http.NewRequest(http.MethodGet, path, nil)
+    if err != nil { return nil, err }
+    return c.doCodec(ctx, req, out)
+}
+
+// Post issues a POST with in encoded by the client's codec and decodes the
+// response into out. Either in or out may be nil.
+func (c *Client) Post(ctx context.Context, path string, in, out any) (*http.Response, error) {
+    codec := c.codecOrDefault()
+    var body io.Reader
+    if in != nil {
+        data, err := codec.Marshal(in)
+        if err != nil { return nil, err }
+        body = bytes.NewReader(data)
+    }
+    req, err := http.NewRequest(http.MethodPost, path, body)
+    if err != nil { return nil, err }
+    if in != nil { req.Header.Set("Content-Type", codec.ContentType()) }
+    return c.doCodec(ctx, req, out)
+}
+
+func (c *Client) doCodec(ctx context.Context, req *http.Request, out any) (*http.Response, error) {
+    resp, err := c.Do(ctx, req)
+    if err != nil { return nil, err }
+    defer resp.Body.Close()
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        return resp, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+    }
+    data, err := io.ReadAll(resp.Body)
+    if err != nil || out == nil || len(data) == 0 { return resp, err }
+    return resp, c.codecOrDefault().Unmarshal(data, out)
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+    "context"
+    "net/http"
+    "testing"
+)
+
+// fakeProtoCodec stands in for a protobuf codec; it carries strings verbatim.
+type fakeProtoCodec struct{}
+
+func (fakeProtoCodec) ContentType() string           { return "application/x-protobuf" }
+func (fakeProtoCodec) Marshal(v any) ([]byte, error) { return []byte(*v.(*string)), nil }
+func (fakeProtoCodec) Unmarshal(data []byte, v any) error {
+    *v.(*string) = string(data)
+    return nil
+}
+
+func TestCodecSetsAcceptHeader(t *testing.T) {
+    var accept string
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithCodec(fakeProtoCodec{}))
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            accept = r.Header.Get("Accept")
+            w.Header().Set("Content-Type", "application/x-protobuf")
+            w.Write([]byte("pb-bytes"))
+        }),
+    }}
+
+    var out string
+    if _, err := c.Get(context.Background(), "/x", &out); err != nil { t.Fatalf("get: %v", err) }
+    if accept != "application/x-protobuf" { t.Fatalf("expected protobuf Accept header, got %q", accept) }
+    if out != "pb-bytes" { t.Fatalf("unexpected decoded body: %q", out) }
+
+    c2 := New([]Endpoint{{BaseURL: "http://a"}}, WithCodec(fakeProtoCodec{}), WithHeader("Accept", "*/*"))
+    c2.hc.Transport = c.hc.Transport
+    if _, err := c2.Get(context.Background(), "/x", &out); err != nil { t.Fatalf("get: %v", err) }
+    if accept != "*/*" { t.Fatalf("explicit Accept header should win, got %q", accept) }
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+    "bytes"
+    "compress/gzip"
+    "errors"
+    "fmt"
+)
+
+// RequestCompressionMinSize is the smallest body, in bytes, that
+// WithRequestCompression compresses; smaller bodies are sent as-is.
+const RequestCompressionMinSize = 1024
+
+// ErrUnsupportedEncoding is returned by PostJSON and PutJSON when
+// WithRequestCompression was given an encoding other than "gzip".
+var ErrUnsupportedEncoding = errors.New("client: unsupported request encoding")
+
+// WithRequestCompression compresses PostJSON and PutJSON bodies of at least
+// RequestCompressionMinSize bytes and sets Content-Encoding. Only "gzip" is
+// supported; "" leaves bodies uncompressed, and any other encoding makes
+// those calls fail with ErrUnsupportedEncoding. The compressed bytes are
+// kept, so retries resend them unchanged.
+func WithRequestCompression(encoding string) Option {
+    return func(c *Client) { c.reqEncoding = encoding }
+}
+
+// compressBody returns data encoded per WithRequestCompression and the
+// Content-Encoding to send, or data unchanged and "" when not compressing.
+func (c *Client) compressBody(data []byte) ([]byte, string, error) {
+    if c.reqEncoding == "" { return data, "", nil }
+    if c.reqEncoding != "gzip" { return nil, "", fmt.Errorf("%w %q", ErrUnsupportedEncoding, c.reqEncoding) }
+    if len(data) < RequestCompressionMinSize { return data, "", nil }
+    var buf bytes.Buffer
+    zw := gzip.NewWriter(&buf)
+    if _, err := zw.Write(data); err != nil { return nil, "", err }
+    if err := zw.Close(); err != nil { return nil, "", err }
+    return buf.Bytes(), "gzip", nil
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+    "bytes"
+    "compress/gzip"
+    "context"
+    "encoding/json"
+    "errors"
+    "io"
+    "net/http"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestRequestCompression(t *testing.T) {
+    type seen struct {
+        encoding string
+        raw      []byte
+    }
+    var got []seen
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithRequestCompression("gzip"))
+    c.retry.RetryOnMethods[http.MethodPost] = true
+    c.retry.InitialBackoff = time.Millisecond
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            raw, _ := io.ReadAll(r.Body)
+            got = append(got, seen{r.Header.Get("Content-Encoding"), raw})
+            if r.URL.Path == "/flaky" && len(got) == 1 { w.WriteHeader(503) }
+        }),
+    }}
+
+    large := map[string]string{"blob": strings.Repeat("x", 4096)}
+    if _, err := c.PostJSON(context.Background(), "/flaky", large, nil); err != nil { t.Fatalf("post: %v", err) }
+    if len(got) != 2 { t.Fatalf("expected a retry, got %d attempts", len(got)) }
+    if got[0].encoding != "gzip" || !bytes.Equal(got[0].raw, got[1].raw) {
+        t.Fatalf("expected identical gzip bodies on both attempts, got %q/%q", got[0].encoding, got[1].encoding)
+    }
+    zr, err := gzip.NewReader(bytes.NewReader(got[0].raw))
+    if err != nil { t.Fatalf("gzip: %v", err) }
+    var decoded map[string]string
+    if err := json.NewDecoder(zr).Decode(&decoded); err != nil || decoded["blob"] != large["blob"] { t.Fatalf("decoded body mismatch: %v", err) }
+
+    got = nil
+    if _, err := c.PutJSON(context.Background(), "/small", map[string]string{"a": "b"}, nil); err != nil { t.Fatalf("put: %v", err) }
+    if got[0].encoding != "" || string(got[0].raw) != "{\"a\":\"b\"}\n" { t.Fatalf("small body should not be compressed: %q %q", got[0].encoding, got[0].raw) }
+}
+
+func TestRequestCompressionRejectsUnknownEncoding(t *testing.T) {
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithRequestCompression("br"))
+    c.hc.Transport = rtFunc(func(*http.Request) (*http.Response, error) {
+        t.Fatal("request with an unsupported encoding should not be sent")
+        return nil, nil
+    })
+    _, err := c.PostJSON(context.Background(), "/x", map[string]string{"a": "b"}, nil)
+    if !errors.Is(err, ErrUnsupportedEncoding) || !strings.Contains(err.Error(), `"br"`) { t.Fatalf("expected ErrUnsupportedEncoding naming the encoding, got %v", err) }
+
+    c = New([]Endpoint{{BaseURL: "http://a"}}, WithRequestCompression(""))
+    if _, _, err := c.compressBody(bytes.Repeat([]byte("x"), RequestCompressionMinSize)); err != nil { t.Fatalf("empty encoding should be a no-op, got %v", err) }
+}
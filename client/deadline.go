@@ -0,0 +1,27 @@
+package client
+
+import (
+    "net/http"
+    "strconv"
+    "time"
+)
+
+// DeadlineHeader is the header middleware.Deadline reads a caller's
+// remaining budget from, in milliseconds.
+const DeadlineHeader = "X-Request-Timeout-Ms"
+
+// WithDeadlineHeader sends the time left until the request context's
+// deadline, in whole milliseconds, in header (DeadlineHeader if empty) on
+// every attempt, so a server using middleware.Deadline stops working once
+// this caller has given up. Requests without a deadline are sent unchanged.
+func WithDeadlineHeader(header string) Option {
+    if header == "" { header = DeadlineHeader }
+    return WithInterceptors(func(req *http.Request, next Invoker) (*http.Response, error) {
+        if dl, ok := req.Context().Deadline(); ok {
+            ms := time.Until(dl).Milliseconds()
+            if ms < 0 { ms = 0 }
+            req.Header.Set(header, strconv.FormatInt(ms, 10))
+        }
+        return next(req)
+    })
+}
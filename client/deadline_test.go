@@ -0,0 +1,29 @@
+package client
+
+import (
+    "context"
+    "net/http"
+    "strconv"
+    "testing"
+    "time"
+)
+
+func TestDeadlineHeader(t *testing.T) {
+    var got []string
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithDeadlineHeader(""))
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = append(got, r.Header.Get(DeadlineHeader)) }),
+    }}
+
+    ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+    defer cancel()
+    for _, ctx := range []context.Context{ctx, context.Background()} {
+        req, _ := http.NewRequest(http.MethodGet, "/x", nil)
+        resp, err := c.Do(ctx, req)
+        if err != nil { t.Fatalf("do: %v", err) }
+        resp.Body.Close()
+    }
+    ms, err := strconv.Atoi(got[0])
+    if err != nil || ms <= 1000 || ms > 2000 { t.Fatalf("expected remaining budget in ms, got %q", got[0]) }
+    if got[1] != "" { t.Fatalf("expected no header without a deadline, got %q", got[1]) }
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+    "compress/gzip"
+    "io"
+    "net/http"
+    "sort"
+    "strings"
+)
+
+// Decompressor wraps a compressed response body in a decoding reader.
+type Decompressor func(io.Reader) (io.ReadCloser, error)
+
+// WithDecompressor registers d for responses whose Content-Encoding matches
+// encoding (e.g. "br" or "zstd"). This keeps codecs such as brotli and zstd
+// optional: the client only depends on them if the caller plugs one in.
+//
+// Once any decompressor is registered the client advertises the registered
+// encodings, plus gzip, in Accept-Encoding and decodes matching responses
+// itself, since net/http only decodes gzip transparently when it sets the
+// header on its own.
+func WithDecompressor(encoding string, d Decompressor) Option {
+    return func(c *Client) {
+        if c.decoders == nil {
+            c.decoders = map[string]Decompressor{"gzip": func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }}
+        }
+        c.decoders[strings.ToLower(encoding)] = d
+    }
+}
+
+// acceptEncoding returns the Accept-Encoding value for registered decompressors.
+func (c *Client) acceptEncoding() string {
+    encs := make([]string, 0, len(c.decoders))
+    for e := range c.decoders { encs = append(encs, e) }
+    sort.Strings(encs)
+    return strings.Join(encs, ", ")
+}
+
+// decodeBody replaces resp.Body with a decoding reader when its
+// Content-Encoding has a registered decompressor.
+func (c *Client) decodeBody(resp *http.Response) (*http.Response, error) {
+    enc := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+    d, ok := c.decoders[enc]
+    if enc == "" || !ok { return resp, nil }
+    dr, err := d(resp.Body)
+    if err != nil {
+        resp.Body.Close()
+        return nil, err
+    }
+    resp.Body = &decodedBody{ReadCloser: dr, raw: resp.Body}
+    resp.Header.Del("Content-Encoding")
+    resp.Header.Del("Content-Length")
+    resp.ContentLength = -1
+    resp.Uncompressed = true
+    return resp, nil
+}
+
+// decodedBody closes both the decoder and the raw body beneath it.
+type decodedBody struct {
+    io.ReadCloser
+    raw io.Closer
+}
+
+func (b *decodedBody) Close() error {
+    err := b.ReadCloser.Close()
+    if rerr := b.raw.Close(); err == nil { err = rerr }
+    return err
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+    "compress/flate"
+    "context"
+    "encoding/json"
+    "io"
+    "net/http"
+    "testing"
+)
+
+func TestRegisteredDecompressor(t *testing.T) {
+    // flate stands in for a real zstd codec; the registry only cares about the encoding label.
+    fakeZstd := func(r io.Reader) (io.ReadCloser, error) { return flate.NewReader(r), nil }
+    var acceptEnc string
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithDecompressor("zstd", fakeZstd))
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            acceptEnc = r.Header.Get("Accept-Encoding")
+            w.Header().Set("Content-Type", "application/json")
+            w.Header().Set("Content-Encoding", "zstd")
+            zw, _ := flate.NewWriter(w, flate.DefaultCompression)
+            json.NewEncoder(zw).Encode(map[string]any{"ok": true})
+            zw.Close()
+        }),
+    }}
+
+    var out struct{ Ok bool `json:"ok"` }
+    resp, err := c.GetJSON(context.Background(), "/x", &out)
+    if err != nil { t.Fatalf("get: %v", err) }
+    if !out.Ok { t.Fatalf("expected decoded body, got %+v", out) }
+    if resp.Header.Get("Content-Encoding") != "" { t.Fatalf("content-encoding should be removed after decoding") }
+    if acceptEnc != "gzip, zstd" { t.Fatalf("unexpected Accept-Encoding: %q", acceptEnc) }
+}
@@ -0,0 +1,35 @@
+package client
+
+import (
+    "context"
+    "errors"
+    "net/http"
+)
+
+// Failover sends requests to a primary Client and replays them against a
+// backup Client when the primary fails, e.g. for cross-service failover. This
+// sits above endpoint balancing: the primary exhausts its own endpoints and
+// retries before the backup is tried.
+type Failover struct {
+    primary *Client
+    backup  *Client
+}
+
+// Chain returns a Failover that tries primary first, then backup.
+func Chain(primary, backup *Client) *Failover { return &Failover{primary: primary, backup: backup} }
+
+// Do sends req via the primary client and, if it fails with an error (retries
+// exhausted or connection failure), replays it via the backup client. The body
+// is rewound between clients the same way as between retry attempts.
+// Cancellation of ctx is never treated as a failover condition.
+func (f *Failover) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+    if ctx == nil { ctx = req.Context() }
+    resp, err := f.primary.Do(ctx, req)
+    if err == nil { return resp, nil }
+    if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+        return nil, err
+    }
+    resp, berr := f.backup.Do(ctx, req)
+    if berr != nil { return nil, errors.Join(err, berr) }
+    return resp, nil
+}
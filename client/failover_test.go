@@ -0,0 +1,41 @@
+package client
+
+import (
+    "context"
+    "errors"
+    "io"
+    "net/http"
+    "strings"
+    "testing"
+    "time"
+)
+
+type rtFunc func(*http.Request) (*http.Response, error)
+
+func (f rtFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestChainFailsOverToBackup(t *testing.T) {
+    primary := New([]Endpoint{{BaseURL: "http://p1"}, {BaseURL: "http://p2"}})
+    primary.retry.InitialBackoff = time.Millisecond
+    primary.hc.Transport = rtFunc(func(*http.Request) (*http.Response, error) {
+        return nil, errors.New("dial tcp: connection refused")
+    })
+    var gotBody string
+    backup := New([]Endpoint{{BaseURL: "http://backup"}})
+    backup.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "backup": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            b, _ := io.ReadAll(r.Body)
+            gotBody = string(b)
+            io.WriteString(w, "from backup")
+        }),
+    }}
+
+    req, _ := http.NewRequest(http.MethodPut, "/items/1", strings.NewReader("data"))
+    resp, err := Chain(primary, backup).Do(context.Background(), req)
+    if err != nil { t.Fatalf("do: %v", err) }
+    body, _ := io.ReadAll(resp.Body)
+    resp.Body.Close()
+    if string(body) != "from backup" || gotBody != "data" {
+        t.Fatalf("expected backup to serve full request, got body=%q sent=%q", body, gotBody)
+    }
+}
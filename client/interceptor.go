@@ -0,0 +1,76 @@
+package client
+
+import (
+    "context"
+    "net/http"
+    "time"
+)
+
+// Invoker sends a single attempt of a request.
+type Invoker func(*http.Request) (*http.Response, error)
+
+// Interceptor wraps every attempt made by Do. It may modify the request,
+// inspect or replace the response, or short-circuit the chain by returning a
+// response without calling next, in which case nothing is sent (useful for
+// local mocking or offline mode). Use Attempt to read the attempt number.
+type Interceptor func(req *http.Request, next Invoker) (*http.Response, error)
+
+// WithInterceptors appends interceptors. The first one is outermost.
+func WithInterceptors(ics ...Interceptor) Option {
+    return func(c *Client) { c.interceptors = append(c.interceptors, ics...) }
+}
+
+type attemptKey struct{}
+
+// Attempt returns the 1-based attempt number for a request context passed
+// through Do, or 0 outside of an attempt.
+func Attempt(ctx context.Context) int {
+    n, _ := ctx.Value(attemptKey{}).(int)
+    return n
+}
+
+// send runs the interceptor chain for one attempt, ending in transmit.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+    next := Invoker(c.transmit)
+    for i := len(c.interceptors) - 1; i >= 0; i-- {
+        ic, inner := c.interceptors[i], next
+        next = func(r *http.Request) (*http.Response, error) { return ic(r, inner) }
+    }
+    return next(req)
+}
+
+// transmit sends req over the underlying http.Client, holding concurrency
+// slots and counting the attempt against its host until the response body is
+// closed. The host's adaptive slot is taken first so that waiting on one
+// congested host does not hold a client-wide slot.
+func (c *Client) transmit(req *http.Request) (*http.Response, error) {
+    host := req.URL.Host
+    if c.adaptive != nil {
+        if err := c.adaptive.acquire(req.Context(), host); err != nil { return nil, err }
+    }
+    slot, err := c.acquireSlot(req)
+    if err != nil {
+        if c.adaptive != nil { c.adaptive.abandon(host) }
+        return nil, err
+    }
+    done := c.bal.begin(host)
+    if c.wire.enabled() { c.wire.logRequest(req) }
+    measured := c.meter(req)
+    start := time.Now()
+    resp, err := c.hc.Do(req)
+    latency := time.Since(start)
+    report := measured(resp, err)
+    release := func() {
+        done()
+        if c.adaptive != nil { c.adaptive.settle(req.Context(), host, start, resp, err, latency) }
+        slot()
+        report()
+    }
+    if c.wire.enabled() { c.wire.logResponse(resp, err) }
+    if err != nil {
+        release()
+        return resp, err
+    }
+    resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+    return resp, nil
+}
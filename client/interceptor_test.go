@@ -0,0 +1,47 @@
+package client
+
+import (
+    "bytes"
+    "context"
+    "io"
+    "net/http"
+    "sync/atomic"
+    "testing"
+)
+
+func TestInterceptorShortCircuit(t *testing.T) {
+    var sent int32
+    var attempts []int
+    mock := func(req *http.Request, next Invoker) (*http.Response, error) {
+        attempts = append(attempts, Attempt(req.Context()))
+        if req.URL.Path == "/mocked" {
+            return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(bytes.NewBufferString("canned")), Request: req}, nil
+        }
+        return next(req)
+    }
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithInterceptors(mock))
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { atomic.AddInt32(&sent, 1); io.WriteString(w, "real") }),
+    }}
+
+    req, _ := http.NewRequest(http.MethodGet, "/mocked", nil)
+    resp, err := c.Do(context.Background(), req)
+    if err != nil { t.Fatalf("do: %v", err) }
+    body, _ := io.ReadAll(resp.Body)
+    resp.Body.Close()
+    if string(body) != "canned" || atomic.LoadInt32(&sent) != 0 {
+        t.Fatalf("expected canned response without sending, got %q sent=%d", body, sent)
+    }
+
+    req, _ = http.NewRequest(http.MethodGet, "/real", nil)
+    resp, err = c.Do(context.Background(), req)
+    if err != nil { t.Fatalf("do: %v", err) }
+    body, _ = io.ReadAll(resp.Body)
+    resp.Body.Close()
+    if string(body) != "real" || atomic.LoadInt32(&sent) != 1 {
+        t.Fatalf("expected pass-through to transport, got %q sent=%d", body, sent)
+    }
+    if len(attempts) != 2 || attempts[0] != 1 || attempts[1] != 1 {
+        t.Fatalf("unexpected attempt numbers: %v", attempts)
+    }
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+    "context"
+    "errors"
+    "io"
+    "net/http"
+    "strings"
+    "time"
+)
+
+// WithKeepAlivePing sends a HEAD request for path to every endpoint each
+// interval, keeping pooled connections from being dropped by NAT or firewall
+// idle timeouts. Pings start after New returns and stop on Close; use Warmup
+// to open connections synchronously before the first real request.
+func WithKeepAlivePing(interval time.Duration, path string) Option {
+    return func(c *Client) { c.pingEvery, c.pingPath = interval, path }
+}
+
+// Warmup pings every endpoint once and waits for the results, returning the
+// joined errors of endpoints that could not be reached.
+func (c *Client) Warmup(ctx context.Context) error {
+    c.mu.Lock()
+    eps := append([]Endpoint(nil), c.endpoints...)
+    c.mu.Unlock()
+    var errs []error
+    for _, ep := range eps {
+        if err := c.ping(ctx, ep.BaseURL); err != nil { errs = append(errs, err) }
+    }
+    return errors.Join(errs...)
+}
+
+// ping sends one keep-alive request straight to base, bypassing retries,
+// balancing, and interceptors, and drains the body so the connection is reused.
+func (c *Client) ping(ctx context.Context, base string) error {
+    path := c.pingPath
+    if path == "" { path = "/" }
+    req, err := http.NewRequestWithContext(ctx, http.MethodHead, strings.TrimRight(base, "/")+"/"+strings.TrimLeft(path, "/"), nil)
+    if err != nil { return err }
+    req.Header.Set("User-Agent", c.headers["User-Agent"])
+    resp, err := c.hc.Do(req)
+    if err != nil { return err }
+    _, _ = io.Copy(io.Discard, resp.Body)
+    return resp.Body.Close()
+}
+
+func (c *Client) pingLoop() {
+    defer c.wg.Done()
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    go func() { <-c.done; cancel() }()
+
+    ticks := c.pingTicks
+    if ticks == nil {
+        t := time.NewTicker(c.pingEvery)
+        defer t.Stop()
+        ticks = t.C
+    }
+    for {
+        select {
+        case <-c.done:
+            return
+        case <-ticks:
+            _ = c.Warmup(ctx)
+        }
+    }
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+    "context"
+    "net/http"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestKeepAlivePing(t *testing.T) {
+    var pings, other int32
+    pinged := make(chan struct{}, 16)
+    rt := &fakeRT{handlers: map[string]http.Handler{}}
+    for _, host := range []string{"a", "b"} {
+        rt.handlers[host] = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if r.Method == http.MethodHead && r.URL.Path == "/healthz" { atomic.AddInt32(&pings, 1); pinged <- struct{}{} } else { atomic.AddInt32(&other, 1) }
+        })
+    }
+    ticks := make(chan time.Time)
+    c := New([]Endpoint{{BaseURL: "http://a"}, {BaseURL: "http://b"}}, WithKeepAlivePing(time.Hour, "/healthz"), func(c *Client) {
+        c.pingTicks = ticks
+        c.hc.Transport = rt
+    })
+
+    if err := c.Warmup(context.Background()); err != nil { t.Fatalf("warmup: %v", err) }
+    if got := atomic.LoadInt32(&pings); got != 2 { t.Fatalf("expected warmup to ping both endpoints, got %d", got) }
+    <-pinged
+    <-pinged
+
+    for i := 0; i < 3; i++ {
+        ticks <- time.Now()
+        for j := 0; j < 2; j++ {
+            select {
+            case <-pinged:
+            case <-time.After(5 * time.Second):
+                t.Fatalf("round %d: timed out waiting for pings", i+1)
+            }
+        }
+    }
+    c.Close()
+    if got := atomic.LoadInt32(&pings); got != 8 { t.Fatalf("expected 3 rounds of pings to 2 endpoints after warmup, got %d", got) }
+    select {
+    case ticks <- time.Now():
+        t.Fatal("ping loop still running after Close")
+    default:
+    }
+    if other != 0 { t.Fatalf("unexpected non-ping requests: %d", other) }
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+    "io"
+    "net/http"
+    "sync/atomic"
+    "time"
+)
+
+// RequestMetrics describes one attempt made by Do. It is reported to the
+// WithMetricsHook hook once the attempt fails or its response body is closed.
+type RequestMetrics struct {
+    Method        string
+    Host          string
+    Path          string
+    Attempt       int
+    Status        int           // 0 if no response was received
+    Err           error         // transport error, if any
+    BytesSent     int64         // request body bytes written
+    BytesReceived int64         // response body bytes read, before decompression
+    Latency       time.Duration // until response headers arrived
+    Duration      time.Duration // until the response body was closed
+    // ConcurrencyLimit is the host's adaptive limit after this attempt, or 0
+    // without WithAdaptiveConcurrency.
+    ConcurrencyLimit int
+}
+
+// WithMetricsHook calls hook with RequestMetrics for every attempt, e.g. for
+// egress accounting or latency histograms. The hook must be safe for
+// concurrent use and should not block.
+func WithMetricsHook(hook func(RequestMetrics)) Option { return func(c *Client) { c.metrics = hook } }
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+    io.ReadCloser
+    n atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+    n, err := r.ReadCloser.Read(p)
+    r.n.Add(int64(n))
+    return n, err
+}
+
+// meter instruments req for the metrics hook and returns the func that
+// reports the attempt once its outcome is known.
+func (c *Client) meter(req *http.Request) func(resp *http.Response, err error) func() {
+    if c.metrics == nil { return func(*http.Response, error) func() { return func() {} } }
+    start := time.Now()
+    m := RequestMetrics{Method: req.Method, Host: req.URL.Host, Path: req.URL.Path, Attempt: Attempt(req.Context())}
+    var sent *countingReader
+    if req.Body != nil && req.Body != http.NoBody {
+        sent = &countingReader{ReadCloser: req.Body}
+        req.Body = sent
+    }
+    return func(resp *http.Response, err error) func() {
+        m.Latency, m.Err = time.Since(start), err
+        var received *countingReader
+        if resp != nil {
+            m.Status = resp.StatusCode
+            received = &countingReader{ReadCloser: resp.Body}
+            resp.Body = received
+        }
+        return func() {
+            m.Duration = time.Since(start)
+            if sent != nil { m.BytesSent = sent.n.Load() }
+            if received != nil { m.BytesReceived = received.n.Load() }
+            if c.adaptive != nil { m.ConcurrencyLimit = c.adaptive.limit(m.Host) }
+            c.metrics(m)
+        }
+    }
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+    "context"
+    "io"
+    "net/http"
+    "strings"
+    "sync"
+    "testing"
+)
+
+func TestMetricsHookCountsBytes(t *testing.T) {
+    var mu sync.Mutex
+    var got []RequestMetrics
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithMetricsHook(func(m RequestMetrics) {
+        mu.Lock(); got = append(got, m); mu.Unlock()
+    }))
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            io.Copy(io.Discard, r.Body)
+            io.WriteString(w, strings.Repeat("r", 250))
+        }),
+    }}
+
+    req, _ := http.NewRequest(http.MethodPost, "/upload", strings.NewReader(strings.Repeat("s", 100)))
+    resp, err := c.Do(context.Background(), req)
+    if err != nil { t.Fatalf("do: %v", err) }
+    io.Copy(io.Discard, resp.Body)
+    if len(got) != 0 { t.Fatal("metrics reported before the body was closed") }
+    resp.Body.Close()
+
+    if len(got) != 1 { t.Fatalf("expected one report, got %d", len(got)) }
+    m := got[0]
+    if m.BytesSent != 100 || m.BytesReceived != 250 { t.Fatalf("expected 100 sent / 250 received, got %d / %d", m.BytesSent, m.BytesReceived) }
+    if m.Status != http.StatusOK || m.Host != "a" || m.Path != "/upload" || m.Attempt != 1 || m.Method != http.MethodPost {
+        t.Fatalf("unexpected metrics %+v", m)
+    }
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+)
+
+// DefaultMaxPages bounds GetAllPages unless WithMaxPages sets another cap.
+const DefaultMaxPages = 1000
+
+// ErrTooManyPages is returned when GetAllPages reaches its page cap while a
+// next link is still present.
+var ErrTooManyPages = errors.New("client: too many pages")
+
+// ErrForeignLink is returned when a rel="next" link points at another host.
+// Such links are not followed, since follow-up requests carry the client's
+// default headers and credentials.
+var ErrForeignLink = errors.New("client: next link points to another host")
+
+// WithMaxPages caps how many pages GetAllPages follows.
+func WithMaxPages(n int) Option { return func(c *Client) { c.maxPages = n } }
+
+// GetAllPages GETs path and follows RFC 8288 Link headers with rel="next"
+// until none remain, calling each for every page. The response body is closed
+// after each returns. Links are requested relative to the client's endpoints,
+// so retries and failover still apply; links to any other host stop the walk
+// with ErrForeignLink rather than sending the client's headers there.
+func (c *Client) GetAllPages(ctx context.Context, path string, each func(*http.Response) error) error {
+    limit := c.maxPages
+    if limit <= 0 { limit = DefaultMaxPages }
+    for page := 0; path != ""; page++ {
+        if page == limit { return fmt.Errorf("%w: stopped after %d", ErrTooManyPages, limit) }
+        req, err := http.NewRequest(http.MethodGet, path, nil)
+        if err != nil { return err }
+        resp, err := c.Do(ctx, req)
+        if err != nil { return err }
+        if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+            resp.Body.Close()
+            return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+        }
+        err = each(resp)
+        resp.Body.Close()
+        if err != nil { return err }
+        if path, err = c.nextPage(resp); err != nil { return err }
+    }
+    return nil
+}
+
+// nextPage returns the path and query of the response's rel="next" link, or
+// "" when there is none. Links to a host other than the one that served the
+// response or one of the client's endpoints yield ErrForeignLink.
+func (c *Client) nextPage(resp *http.Response) (string, error) {
+    link := nextLink(resp.Header.Values("Link"))
+    if link == "" { return "", nil }
+    u, err := url.Parse(link)
+    if err != nil { return "", nil }
+    if resp.Request != nil && resp.Request.URL != nil { u = resp.Request.URL.ResolveReference(u) }
+    if u.IsAbs() || u.Host != "" {
+        served := resp.Request != nil && resp.Request.URL != nil && u.Host == resp.Request.URL.Host
+        if !served && !c.isEndpointHost(u.Host) { return "", fmt.Errorf("%w: %s", ErrForeignLink, u.Host) }
+    }
+    return (&url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery}).String(), nil
+}
+
+// isEndpointHost reports whether host is the host of one of c's endpoints.
+func (c *Client) isEndpointHost(host string) bool {
+    c.mu.Lock(); defer c.mu.Unlock()
+    for _, e := range c.endpoints {
+        if u, err := url.Parse(e.BaseURL); err == nil && u.Host == host { return true }
+    }
+    return false
+}
+
+// nextLink extracts the target of the first rel="next" entry from Link
+// header values such as `<https://api/items?page=2>; rel="next"`.
+func nextLink(values []string) string {
+    for _, v := range values {
+        for v != "" {
+            start := strings.IndexByte(v, '<')
+            end := strings.IndexByte(v, '>')
+            if start < 0 || end < start { break }
+            target := v[start+1 : end]
+            params := v[end+1:]
+            if i := strings.IndexByte(params, '<'); i >= 0 { params, v = params[:i], params[i:] } else { v = "" }
+            for _, p := range strings.Split(params, ";") {
+                k, val, ok := strings.Cut(strings.TrimSpace(p), "=")
+                if !ok || !strings.EqualFold(strings.TrimSpace(k), "rel") { continue }
+                for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(val), `",`)) {
+                    if strings.EqualFold(rel, "next") { return target }
+                }
+            }
+        }
+    }
+    return ""
+}
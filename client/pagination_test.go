@@ -0,0 +1,64 @@
+package client
+
+import (
+    "context"
+    "errors"
+    "io"
+    "net/http"
+    "testing"
+)
+
+func TestGetAllPagesFollowsNextLinks(t *testing.T) {
+    c := New([]Endpoint{{BaseURL: "http://a"}})
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            switch r.URL.Query().Get("page") {
+            case "":
+                w.Header().Add("Link", `<http://a/items?page=1>; rel="first", <http://a/items?page=2>; rel="next"`)
+                io.WriteString(w, "one")
+            case "2":
+                w.Header().Add("Link", `</items?page=1>; rel="prev first"`)
+                io.WriteString(w, "two")
+            default:
+                t.Errorf("unexpected request %s", r.URL)
+            }
+        }),
+    }}
+
+    var pages []string
+    err := c.GetAllPages(context.Background(), "/items", func(resp *http.Response) error {
+        b, err := io.ReadAll(resp.Body)
+        pages = append(pages, string(b))
+        return err
+    })
+    if err != nil { t.Fatalf("get all pages: %v", err) }
+    if len(pages) != 2 || pages[0] != "one" || pages[1] != "two" { t.Fatalf("unexpected pages %q", pages) }
+}
+
+func TestGetAllPagesCap(t *testing.T) {
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithMaxPages(3))
+    calls := 0
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            calls++
+            w.Header().Set("Link", `</loop>; rel="next"`)
+        }),
+    }}
+    err := c.GetAllPages(context.Background(), "/loop", func(*http.Response) error { return nil })
+    if !errors.Is(err, ErrTooManyPages) || calls != 3 { t.Fatalf("expected ErrTooManyPages after 3 pages, got %v after %d", err, calls) }
+}
+
+func TestGetAllPagesRejectsForeignLink(t *testing.T) {
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithHeader("Authorization", "Bearer secret"))
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            w.Header().Set("Link", `<http://evil/steal?page=2>; rel="next"`)
+        }),
+        "evil": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            t.Errorf("followed foreign link with Authorization %q", r.Header.Get("Authorization"))
+        }),
+    }}
+    pages := 0
+    err := c.GetAllPages(context.Background(), "/items", func(*http.Response) error { pages++; return nil })
+    if !errors.Is(err, ErrForeignLink) || pages != 1 { t.Fatalf("expected ErrForeignLink after 1 page, got %v after %d", err, pages) }
+}
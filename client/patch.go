@@ -0,0 +1,49 @@
+package client
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+)
+
+// Content types for JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902).
+const (
+    ContentTypeMergePatch = "application/merge-patch+json"
+    ContentTypeJSONPatch  = "application/json-patch+json"
+)
+
+// PatchMergeJSON sends patch as a JSON Merge Patch and decodes the JSON
+// response into out. PATCH is not retried unless the method is listed in
+// RetryOnMethods, or RetryIdempotencyKeyed is set and the request carries an
+// Idempotency-Key header.
+func (c *Client) PatchMergeJSON(ctx context.Context, path string, patch, out any) (*http.Response, error) {
+    return c.patchJSON(ctx, path, ContentTypeMergePatch, patch, out)
+}
+
+// PatchJSONPatch sends ops as a JSON Patch document and decodes the JSON
+// response into out. Retries follow the same rules as PatchMergeJSON.
+func (c *Client) PatchJSONPatch(ctx context.Context, path string, ops, out any) (*http.Response, error) {
+    return c.patchJSON(ctx, path, ContentTypeJSONPatch, ops, out)
+}
+
+func (c *Client) patchJSON(ctx context.Context, path, contentType string, patch, out any) (*http.Response, error) {
+    data, err := json.Marshal(patch)
+    if err != nil { return nil, err }
+    // bytes.Reader gives the request a GetBody, so retries resend the patch.
+    req, err := http.NewRequest(http.MethodPatch, path, bytes.NewReader(data))
+    if err != nil { return nil, err }
+    req.Header.Set("Content-Type", contentType)
+    req.Header.Set("Accept", "application/json")
+    resp, err := c.Do(ctx, req)
+    if err != nil { return nil, err }
+    defer resp.Body.Close()
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        return resp, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+    }
+    data, err = io.ReadAll(resp.Body)
+    if err != nil || out == nil || len(data) == 0 { return resp, err }
+    return resp, json.Unmarshal(data, out)
+}
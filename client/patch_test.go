@@ -0,0 +1,99 @@
+package client
+
+import (
+    "context"
+    "io"
+    "net/http"
+    "strings"
+    "testing"
+)
+
+func TestPatchContentTypes(t *testing.T) {
+    var ctype, body string
+    c := New([]Endpoint{{BaseURL: "http://a"}})
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if r.Method != http.MethodPatch { t.Errorf("expected PATCH, got %s", r.Method) }
+            ctype = r.Header.Get("Content-Type")
+            b, _ := io.ReadAll(r.Body)
+            body = string(b)
+            w.Write([]byte(`{"name":"bob"}`))
+        }),
+    }}
+
+    var out struct{ Name string `json:"name"` }
+    if _, err := c.PatchMergeJSON(context.Background(), "/users/1", map[string]any{"name": "bob"}, &out); err != nil { t.Fatalf("merge patch: %v", err) }
+    if ctype != ContentTypeMergePatch { t.Fatalf("unexpected content type %q", ctype) }
+    if body != `{"name":"bob"}` || out.Name != "bob" { t.Fatalf("unexpected body %q / out %+v", body, out) }
+
+    ops := []map[string]any{{"op": "replace", "path": "/name", "value": "bob"}}
+    if _, err := c.PatchJSONPatch(context.Background(), "/users/1", ops, nil); err != nil { t.Fatalf("json patch: %v", err) }
+    if ctype != ContentTypeJSONPatch { t.Fatalf("unexpected content type %q", ctype) }
+}
+
+func TestPatchDecodesJSONWithOtherCodec(t *testing.T) {
+    var accept string
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithCodec(fakeProtoCodec{}))
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            accept = r.Header.Get("Accept")
+            w.Write([]byte(`{"name":"bob"}`))
+        }),
+    }}
+    var out struct{ Name string `json:"name"` }
+    if _, err := c.PatchMergeJSON(context.Background(), "/users/1", map[string]any{"name": "bob"}, &out); err != nil { t.Fatalf("merge patch: %v", err) }
+    if accept != "application/json" || out.Name != "bob" { t.Fatalf("expected a JSON response decoded as JSON, got Accept %q and %+v", accept, out) }
+}
+
+func TestPatchRetriesOnlyWithIdempotencyKey(t *testing.T) {
+    calls := 0
+    rt := &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            calls++
+            b, _ := io.ReadAll(r.Body)
+            if string(b) != `{"n":1}` { t.Errorf("attempt %d sent body %q", calls, b) }
+            if calls == 1 { w.WriteHeader(http.StatusServiceUnavailable); return }
+            w.WriteHeader(http.StatusOK)
+        }),
+    }}
+    policy := DefaultRetryPolicy()
+    policy.InitialBackoff = 0
+
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithRetryPolicy(policy), WithHeader("Idempotency-Key", "k1"))
+    c.hc.Transport = rt
+    if _, err := c.PatchMergeJSON(context.Background(), "/x", map[string]int{"n": 1}, nil); err == nil { t.Fatal("expected 503 without retry") }
+    if calls != 1 { t.Fatalf("idempotency key retries should be opt-in, got %d calls", calls) }
+
+    calls = 0
+    policy.RetryIdempotencyKeyed = true
+    c = New([]Endpoint{{BaseURL: "http://a"}}, WithRetryPolicy(policy))
+    c.hc.Transport = rt
+    if _, err := c.PatchMergeJSON(context.Background(), "/x", map[string]int{"n": 1}, nil); err == nil { t.Fatal("expected 503 without retry") }
+    if calls != 1 { t.Fatalf("PATCH without idempotency key should not retry, got %d calls", calls) }
+
+    calls = 0
+    c = New([]Endpoint{{BaseURL: "http://a"}}, WithRetryPolicy(policy), WithHeader("Idempotency-Key", "k1"))
+    c.hc.Transport = rt
+    if _, err := c.PatchMergeJSON(context.Background(), "/x", map[string]int{"n": 1}, nil); err != nil { t.Fatalf("patch: %v", err) }
+    if calls != 2 { t.Fatalf("expected retry with idempotency key, got %d calls", calls) }
+}
+
+func TestIdempotencyKeyRetryNeedsReplayableBody(t *testing.T) {
+    calls := 0
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithMaxBufferedBody(4))
+    c.retry.InitialBackoff = 0
+    c.retry.RetryIdempotencyKeyed = true
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            calls++
+            io.Copy(io.Discard, r.Body)
+            w.WriteHeader(http.StatusServiceUnavailable)
+        }),
+    }}
+    req, _ := http.NewRequest(http.MethodPost, "/upload", io.NopCloser(strings.NewReader("too large to buffer")))
+    req.Header.Set("Idempotency-Key", "k2")
+    resp, err := c.Do(context.Background(), req)
+    if err != nil { t.Fatalf("expected the 503 response, got %v", err) }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusServiceUnavailable || calls != 1 { t.Fatalf("expected one attempt with a streamed body, got %d after %d calls", resp.StatusCode, calls) }
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "crypto/tls"
+    "crypto/x509"
+    "errors"
+    "net/http"
+)
+
+// ErrCertificatePin is returned when a server's certificate matches no pin.
+var ErrCertificatePin = errors.New("client: server certificate does not match any pinned key")
+
+// ErrPinningUnsupported is returned for every request when pins are set but
+// the client's transport is not an *http.Transport, since the pins could not
+// be checked before the request is sent.
+var ErrPinningUnsupported = errors.New("client: certificate pinning needs an *http.Transport")
+
+// WithPinnedCerts rejects TLS connections whose leaf certificate public key
+// does not match one of pins, given as SHA-256 hashes of the certificate's
+// SubjectPublicKeyInfo (see SPKIPin). Pinning is installed as a
+// VerifyConnection callback on the client's *http.Transport, after any
+// existing callback in its TLS config, so it composes with custom TLS
+// settings including those supplied via WithHTTPClient. Other transports
+// cannot be hooked before the handshake, so requests fail closed with
+// ErrPinningUnsupported instead of reaching an unverified server.
+func WithPinnedCerts(pins [][]byte) Option { return func(c *Client) { c.pins = pins } }
+
+// SPKIPin returns the SHA-256 SubjectPublicKeyInfo pin for cert.
+func SPKIPin(cert *x509.Certificate) []byte {
+    sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+    return sum[:]
+}
+
+// installPins applies c.pins to a copy of the client's transport.
+func (c *Client) installPins() {
+    if len(c.pins) == 0 { return }
+    hc := *c.hc
+    if hc.Transport == nil { hc.Transport = http.DefaultTransport }
+    switch t := hc.Transport.(type) {
+    case *http.Transport:
+        t = t.Clone()
+        if t.TLSClientConfig == nil { t.TLSClientConfig = &tls.Config{} }
+        prev := t.TLSClientConfig.VerifyConnection
+        t.TLSClientConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+            if prev != nil {
+                if err := prev(cs); err != nil { return err }
+            }
+            return verifyPins(cs, c.pins)
+        }
+        hc.Transport = t
+    default:
+        hc.Transport = pinUnsupportedTransport{}
+    }
+    c.hc = &hc
+}
+
+func verifyPins(cs tls.ConnectionState, pins [][]byte) error {
+    if len(cs.PeerCertificates) == 0 { return ErrCertificatePin }
+    got := SPKIPin(cs.PeerCertificates[0])
+    for _, p := range pins {
+        if bytes.Equal(p, got) { return nil }
+    }
+    return ErrCertificatePin
+}
+
+// pinUnsupportedTransport refuses every request; see ErrPinningUnsupported.
+type pinUnsupportedTransport struct{}
+
+func (pinUnsupportedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    if req.Body != nil { req.Body.Close() }
+    return nil, ErrPinningUnsupported
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+func TestPinnedCerts(t *testing.T) {
+    srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(200) }))
+    defer srv.Close()
+
+    get := func(pin []byte) error {
+        c := New([]Endpoint{{BaseURL: srv.URL}}, WithHTTPClient(srv.Client()), WithPinnedCerts([][]byte{pin}))
+        c.retry.MaxAttempts = 1
+        req, _ := http.NewRequest(http.MethodGet, "/x", nil)
+        resp, err := c.Do(context.Background(), req)
+        if err == nil { resp.Body.Close() }
+        return err
+    }
+
+    if err := get(make([]byte, 32)); !errors.Is(err, ErrCertificatePin) {
+        t.Fatalf("expected pin mismatch error, got %v", err)
+    }
+    if err := get(SPKIPin(srv.Certificate())); err != nil {
+        t.Fatalf("expected matching pin to succeed, got %v", err)
+    }
+}
+
+func TestPinnedCertsFailClosed(t *testing.T) {
+    calls := 0
+    rt := rtFunc(func(*http.Request) (*http.Response, error) { calls++; return nil, errors.New("sent") })
+    c := New([]Endpoint{{BaseURL: "https://a"}}, WithHTTPClient(&http.Client{Transport: rt}), WithPinnedCerts([][]byte{make([]byte, 32)}))
+    c.retry.MaxAttempts = 1
+    req, _ := http.NewRequest(http.MethodGet, "/x", nil)
+    if _, err := c.Do(context.Background(), req); !errors.Is(err, ErrPinningUnsupported) || calls != 0 {
+        t.Fatalf("expected ErrPinningUnsupported without sending, got %v after %d calls", err, calls)
+    }
+}
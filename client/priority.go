@@ -0,0 +1,121 @@
+package client
+
+import (
+    "context"
+    "io"
+    "net/http"
+    "sync"
+)
+
+// Priority ranks requests competing for the client's concurrency limit.
+type Priority int
+
+const (
+    PriorityLow    Priority = -1 // batch and background work
+    PriorityNormal Priority = 0  // default
+    PriorityHigh   Priority = 1  // interactive, user-facing requests
+)
+
+type priorityKey struct{}
+
+// ContextWithPriority returns a context whose requests are admitted by the
+// concurrency limit (see WithMaxConcurrency) ahead of lower priorities.
+func ContextWithPriority(ctx context.Context, p Priority) context.Context {
+    return context.WithValue(ctx, priorityKey{}, p)
+}
+
+func priorityFor(ctx context.Context) Priority {
+    p, ok := ctx.Value(priorityKey{}).(Priority)
+    if !ok || p < PriorityLow || p > PriorityHigh { return PriorityNormal }
+    return p
+}
+
+// WithMaxConcurrency caps the number of attempts in flight at once; a slot is
+// held until the response body is closed. Waiting requests are admitted
+// highest priority first, and in arrival order within a priority, so a flood
+// of low-priority work cannot starve interactive requests.
+func WithMaxConcurrency(n int) Option {
+    return func(c *Client) {
+        if n > 0 { c.limiter = &priorityLimiter{max: n} } else { c.limiter = nil }
+    }
+}
+
+// priorityLimiter is a counting semaphore with one FIFO wait queue per
+// priority level.
+type priorityLimiter struct {
+    mu       sync.Mutex
+    max      int
+    inFlight int
+    queues   [3][]chan struct{} // indexed by Priority - PriorityLow
+}
+
+func (l *priorityLimiter) acquire(ctx context.Context, p Priority) error {
+    l.mu.Lock()
+    if l.inFlight < l.max && l.waiting() == 0 {
+        l.inFlight++
+        l.mu.Unlock()
+        return nil
+    }
+    ch := make(chan struct{})
+    q := &l.queues[p-PriorityLow]
+    *q = append(*q, ch)
+    l.mu.Unlock()
+
+    select {
+    case <-ch:
+        return nil
+    case <-ctx.Done():
+        l.mu.Lock()
+        for i, w := range *q {
+            if w == ch {
+                *q = append((*q)[:i], (*q)[i+1:]...)
+                l.mu.Unlock()
+                return ctx.Err()
+            }
+        }
+        l.mu.Unlock()
+        // The slot was handed over while we were giving up; pass it on.
+        l.release()
+        return ctx.Err()
+    }
+}
+
+// release frees a slot, handing it directly to the highest-priority waiter.
+func (l *priorityLimiter) release() {
+    l.mu.Lock(); defer l.mu.Unlock()
+    for i := len(l.queues) - 1; i >= 0; i-- {
+        if q := l.queues[i]; len(q) > 0 {
+            l.queues[i] = q[1:]
+            close(q[0])
+            return
+        }
+    }
+    l.inFlight--
+}
+
+func (l *priorityLimiter) waiting() int {
+    n := 0
+    for _, q := range l.queues { n += len(q) }
+    return n
+}
+
+// releaseOnClose runs release once, when the response body is closed.
+type releaseOnClose struct {
+    io.ReadCloser
+    once    sync.Once
+    release func()
+}
+
+func (b *releaseOnClose) Close() error {
+    err := b.ReadCloser.Close()
+    b.once.Do(b.release)
+    return err
+}
+
+// acquireSlot waits for a concurrency slot for req and returns its release
+// func, or a no-op when no limit is configured.
+func (c *Client) acquireSlot(req *http.Request) (func(), error) {
+    if c.limiter == nil { return func() {}, nil }
+    if err := c.limiter.acquire(req.Context(), priorityFor(req.Context())); err != nil { return nil, err }
+    return c.limiter.release, nil
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+    "context"
+    "net/http"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestHighPriorityJumpsLowPriorityQueue(t *testing.T) {
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithMaxConcurrency(1))
+    unblock := make(chan struct{})
+    var mu sync.Mutex
+    var order []string
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if r.URL.Path == "/block" { <-unblock }
+            mu.Lock(); order = append(order, r.URL.Path); mu.Unlock()
+        }),
+    }}
+    do := func(ctx context.Context, path string) {
+        req, _ := http.NewRequest(http.MethodGet, path, nil)
+        resp, err := c.Do(ctx, req)
+        if err != nil { t.Errorf("%s: %v", path, err); return }
+        resp.Body.Close()
+    }
+    queued := func(n int) {
+        for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+            c.limiter.mu.Lock(); got := c.limiter.waiting(); c.limiter.mu.Unlock()
+            if got == n { return }
+        }
+        t.Fatalf("expected %d queued requests", n)
+    }
+
+    var wg sync.WaitGroup
+    wg.Add(1)
+    go func() { defer wg.Done(); do(context.Background(), "/block") }()
+    for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(time.Millisecond) {
+        c.limiter.mu.Lock(); busy := c.limiter.inFlight == 1; c.limiter.mu.Unlock()
+        if busy { break }
+        if time.Now().After(deadline) { t.Fatal("blocking request never started") }
+    }
+
+    low := ContextWithPriority(context.Background(), PriorityLow)
+    for i := 0; i < 20; i++ {
+        wg.Add(1)
+        go func() { defer wg.Done(); do(low, "/low") }()
+    }
+    queued(20)
+    wg.Add(1)
+    go func() { defer wg.Done(); do(ContextWithPriority(context.Background(), PriorityHigh), "/high") }()
+    queued(21)
+
+    close(unblock)
+    wg.Wait()
+    if len(order) != 22 || order[0] != "/block" || order[1] != "/high" {
+        t.Fatalf("expected high priority request right after the blocker, got %v", order[:min(3, len(order))])
+    }
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+    "context"
+    "math/rand"
+    "time"
+)
+
+// Resolver discovers the current endpoint set, e.g. from DNS SRV records or
+// a service registry.
+type Resolver interface {
+    Resolve(ctx context.Context) ([]Endpoint, error)
+}
+
+// ResolverFunc adapts a function to a Resolver.
+type ResolverFunc func(ctx context.Context) ([]Endpoint, error)
+
+// Resolve implements Resolver.
+func (f ResolverFunc) Resolve(ctx context.Context) ([]Endpoint, error) { return f(ctx) }
+
+// WithResolver periodically re-resolves the endpoint set in the background,
+// starting right after New returns. Successful resolutions are applied with
+// SetEndpoints and re-resolved after about minInterval (jittered). When the
+// resolver errors or returns no endpoints, the client keeps serving the last
+// good set and backs off exponentially up to maxInterval rather than hammering
+// the resolver. Call Close to stop re-resolution.
+func WithResolver(r Resolver, minInterval, maxInterval time.Duration) Option {
+    return func(c *Client) {
+        if minInterval <= 0 { minInterval = 30 * time.Second }
+        if maxInterval < minInterval { maxInterval = minInterval }
+        c.resolver = r
+        c.resolveMin, c.resolveMax = minInterval, maxInterval
+    }
+}
+
+// SetEndpoints replaces the endpoint set used for balancing. Removed
+// endpoints drain gracefully: new requests go elsewhere while requests already
+// in flight to them finish. Use WaitDrained to wait for them.
+func (c *Client) SetEndpoints(eps []Endpoint) {
+    cp := make([]Endpoint, len(eps))
+    copy(cp, eps)
+    c.bal.setEndpoints(cp)
+    c.mu.Lock()
+    c.endpoints = cp
+    c.mu.Unlock()
+}
+
+// WaitDrained blocks until every endpoint removed by SetEndpoints has
+// finished its in-flight requests, or ctx is done.
+func (c *Client) WaitDrained(ctx context.Context) error {
+    select {
+    case <-c.bal.drainedChan():
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+// Close stops background work started by the client (such as re-resolution
+// and keep-alive pings).
+// It does not interrupt in-flight requests. Close is safe to call more than once.
+func (c *Client) Close() error {
+    c.closeOnce.Do(func() { close(c.done) })
+    c.wg.Wait()
+    return nil
+}
+
+// startBackground launches the goroutines requested by options.
+func (c *Client) startBackground() {
+    if c.resolver != nil {
+        c.wg.Add(1)
+        go c.resolveLoop()
+    }
+    if c.pingEvery > 0 {
+        c.wg.Add(1)
+        go c.pingLoop()
+    }
+}
+
+func (c *Client) resolveLoop() {
+    defer c.wg.Done()
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    go func() { <-c.done; cancel() }()
+
+    var wait, backoff time.Duration
+    for {
+        t := time.NewTimer(wait)
+        select {
+        case <-c.done:
+            t.Stop()
+            return
+        case <-t.C:
+        }
+        eps, err := c.resolver.Resolve(ctx)
+        if err == nil && len(eps) > 0 {
+            c.SetEndpoints(eps)
+            wait, backoff = jitter(c.resolveMin, 0.2), 0
+            continue
+        }
+        // Keep the last good set and back off.
+        backoff = c.resolveBackoff(backoff)
+        wait = backoff
+    }
+}
+
+// resolveBackoff returns the wait after a failed resolution given the
+// previous one, 0 if the last resolution succeeded: resolveMin, then
+// doubling up to resolveMax.
+func (c *Client) resolveBackoff(prev time.Duration) time.Duration {
+    if prev == 0 { return c.resolveMin }
+    if prev*2 > c.resolveMax { return c.resolveMax }
+    return prev * 2
+}
+
+// jitter spreads d by +/- frac.
+func jitter(d time.Duration, frac float64) time.Duration {
+    return time.Duration(float64(d) * (1 + (rand.Float64()*2-1)*frac))
+}
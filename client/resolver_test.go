@@ -0,0 +1,98 @@
+package client
+
+import (
+    "context"
+    "errors"
+    "io"
+    "net/http"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestResolverBacksOffAndAdoptsNewSet(t *testing.T) {
+    var resolves int32
+    res := ResolverFunc(func(context.Context) ([]Endpoint, error) {
+        if atomic.AddInt32(&resolves, 1) <= 3 { return nil, errors.New("registry unavailable") }
+        return []Endpoint{{BaseURL: "http://b"}}, nil
+    })
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithResolver(res, 5*time.Millisecond, 20*time.Millisecond))
+    defer c.Close()
+    var gotA, gotB int32
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { atomic.AddInt32(&gotA, 1) }),
+        "b": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { atomic.AddInt32(&gotB, 1) }),
+    }}
+
+    deadline := time.Now().Add(2 * time.Second)
+    for atomic.LoadInt32(&gotB) == 0 {
+        if time.Now().After(deadline) { t.Fatalf("new endpoint set never adopted (resolves=%d)", atomic.LoadInt32(&resolves)) }
+        req, _ := http.NewRequest(http.MethodGet, "/x", nil)
+        resp, err := c.Do(context.Background(), req)
+        if err != nil { t.Fatalf("request failed while resolver was erroring: %v", err) }
+        resp.Body.Close()
+        time.Sleep(2 * time.Millisecond)
+    }
+    if atomic.LoadInt32(&gotA) == 0 { t.Fatalf("expected last good set to serve requests during resolver errors") }
+    if n := atomic.LoadInt32(&resolves); n < 4 { t.Fatalf("expected retries of the resolver, got %d calls", n) }
+}
+
+func TestSetEndpointsDrainsRemovedEndpoint(t *testing.T) {
+    release := make(chan struct{})
+    started := make(chan struct{})
+    var gotB int32
+    c := New([]Endpoint{{BaseURL: "http://a"}})
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            close(started)
+            <-release
+            io.WriteString(w, "from a")
+        }),
+        "b": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { atomic.AddInt32(&gotB, 1) }),
+    }}
+
+    result := make(chan string, 1)
+    go func() {
+        req, _ := http.NewRequest(http.MethodGet, "/slow", nil)
+        resp, err := c.Do(context.Background(), req)
+        if err != nil { result <- err.Error(); return }
+        b, _ := io.ReadAll(resp.Body)
+        resp.Body.Close()
+        result <- string(b)
+    }()
+    <-started
+
+    c.SetEndpoints([]Endpoint{{BaseURL: "http://b"}})
+    if st := c.HostStats()["a"]; !st.Draining || st.InFlight != 1 { t.Fatalf("expected a to be draining with 1 in flight, got %+v", st) }
+
+    req, _ := http.NewRequest(http.MethodGet, "/x", nil)
+    resp, err := c.Do(context.Background(), req)
+    if err != nil { t.Fatalf("do: %v", err) }
+    resp.Body.Close()
+    if atomic.LoadInt32(&gotB) != 1 { t.Fatal("new request was not routed to b") }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+    defer cancel()
+    if err := c.WaitDrained(ctx); err == nil { t.Fatal("expected a to still be draining") }
+
+    close(release)
+    if got := <-result; got != "from a" { t.Fatalf("in-flight request to a did not complete: %q", got) }
+    if err := c.WaitDrained(context.Background()); err != nil { t.Fatalf("wait drained: %v", err) }
+    if _, ok := c.HostStats()["a"]; ok { t.Fatal("drained endpoint still reported") }
+}
+
+func TestResolveBackoffRestartsAfterSuccess(t *testing.T) {
+    c := &Client{resolveMin: time.Second, resolveMax: 5 * time.Second}
+    var got []time.Duration
+    backoff := time.Duration(0)
+    for i := 0; i < 4; i++ {
+        backoff = c.resolveBackoff(backoff)
+        got = append(got, backoff)
+    }
+    want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+    for i := range want {
+        if got[i] != want[i] { t.Fatalf("backoff %d: got %v, want %v", i, got[i], want[i]) }
+    }
+    // A success resets the backoff whatever the jittered wait was.
+    if d := c.resolveBackoff(0); d != time.Second { t.Fatalf("expected the first failure after a success to wait the base interval, got %v", d) }
+}
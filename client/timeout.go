@@ -0,0 +1,56 @@
+package client
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net"
+    "net/http"
+    "net/http/httptrace"
+    "sync/atomic"
+)
+
+// TimeoutPhase identifies how far a request got before it timed out.
+type TimeoutPhase string
+
+const (
+    // TimeoutConnect means the request was not fully written, so the server
+    // cannot have acted on it and it is safe to retry regardless of method.
+    TimeoutConnect TimeoutPhase = "connect"
+    // TimeoutResponse means the request was sent and the client timed out
+    // waiting for or reading the response; the server may have acted on it.
+    TimeoutResponse TimeoutPhase = "response"
+)
+
+// TimeoutError is returned by Do when an attempt times out.
+type TimeoutError struct {
+    Phase TimeoutPhase
+    Err   error
+}
+
+func (e *TimeoutError) Error() string   { return fmt.Sprintf("%s timeout: %v", e.Phase, e.Err) }
+func (e *TimeoutError) Unwrap() error   { return e.Err }
+func (e *TimeoutError) Timeout() bool   { return true }
+func (e *TimeoutError) Temporary() bool { return true }
+
+// phaseTracker records request progress via httptrace.
+type phaseTracker struct{ wrote atomic.Bool }
+
+// trace returns ctx with a ClientTrace that feeds the tracker, composed with
+// any trace already present.
+func (p *phaseTracker) trace(ctx context.Context) context.Context {
+    return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+        WroteRequest: func(info httptrace.WroteRequestInfo) { if info.Err == nil { p.wrote.Store(true) } },
+    })
+}
+
+// classify wraps timeout errors for req in a TimeoutError with the phase
+// reached. Errors caused by the caller's own context are returned unchanged.
+func (p *phaseTracker) classify(req *http.Request, err error) error {
+    if err == nil || req.Context().Err() != nil { return err }
+    var ne net.Error
+    if !errors.Is(err, context.DeadlineExceeded) && !(errors.As(err, &ne) && ne.Timeout()) { return err }
+    phase := TimeoutConnect
+    if p.wrote.Load() { phase = TimeoutResponse }
+    return &TimeoutError{Phase: phase, Err: err}
+}
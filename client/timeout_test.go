@@ -0,0 +1,50 @@
+package client
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "net/http/httptrace"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+type fakeTimeout struct{}
+
+func (fakeTimeout) Error() string   { return "i/o timeout" }
+func (fakeTimeout) Timeout() bool   { return true }
+func (fakeTimeout) Temporary() bool { return true }
+
+func TestTimeoutPhaseDrivesRetries(t *testing.T) {
+    var connectCalls, responseCalls int32
+    c := New([]Endpoint{{BaseURL: "http://a"}})
+    c.retry.InitialBackoff = time.Millisecond
+    c.hc.Transport = rtFunc(func(req *http.Request) (*http.Response, error) {
+        trace := httptrace.ContextClientTrace(req.Context())
+        switch req.URL.Path {
+        case "/connect":
+            // Time out before the request is written, then succeed.
+            if atomic.AddInt32(&connectCalls, 1) == 1 { return nil, fakeTimeout{} }
+            return (&memRW{header: http.Header{}}).Result(), nil
+        default:
+            atomic.AddInt32(&responseCalls, 1)
+            trace.WroteRequest(httptrace.WroteRequestInfo{})
+            return nil, fakeTimeout{}
+        }
+    })
+
+    req, _ := http.NewRequest(http.MethodPost, "/connect", nil)
+    resp, err := c.Do(context.Background(), req)
+    if err != nil { t.Fatalf("expected connect timeout to be retried, got %v", err) }
+    resp.Body.Close()
+    if connectCalls != 2 { t.Fatalf("expected 2 attempts, got %d", connectCalls) }
+
+    req, _ = http.NewRequest(http.MethodPost, "/response", nil)
+    _, err = c.Do(context.Background(), req)
+    var te *TimeoutError
+    if !errors.As(err, &te) || te.Phase != TimeoutResponse {
+        t.Fatalf("expected response-phase TimeoutError, got %v", err)
+    }
+    if responseCalls != 1 { t.Fatalf("non-idempotent request retried after response timeout: %d attempts", responseCalls) }
+}
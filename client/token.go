@@ -0,0 +1,113 @@
+package client
+
+import (
+    "context"
+    "net/http"
+    "sync"
+    "time"
+)
+
+// TokenSource supplies bearer tokens for WithTokenSource.
+type TokenSource interface {
+    // Token returns a valid token, fetching or refreshing it as needed.
+    Token(ctx context.Context) (string, error)
+    // Invalidate discards token after the server rejected it with 401, so
+    // the next Token call returns a fresh one.
+    Invalidate(token string)
+}
+
+// WithTokenSource sets "Authorization: Bearer <token>" on every attempt that
+// does not already carry an Authorization header. When the server answers
+// 401, the token is invalidated and the request is resent once with a fresh
+// token before the 401 is returned.
+func WithTokenSource(ts TokenSource) Option { return func(c *Client) { c.tokens = ts } }
+
+// applyToken sets the bearer token on req and returns it, or "" when the
+// client has no TokenSource or req already carries credentials.
+func (c *Client) applyToken(req *http.Request) (string, error) {
+    if c.tokens == nil || req.Header.Get("Authorization") != "" { return "", nil }
+    token, err := c.tokens.Token(req.Context())
+    if err != nil { return "", err }
+    req.Header.Set("Authorization", "Bearer "+token)
+    return token, nil
+}
+
+// CachingTokenSource returns a TokenSource that calls fetch for a token and
+// its expiry, and reuses it until shortly before it expires or is invalidated.
+// Concurrent callers share a single fetch without holding a lock across it:
+// each stops waiting when its own ctx ends, and the fetch's context is
+// cancelled once every caller waiting for it has given up.
+func CachingTokenSource(fetch func(ctx context.Context) (token string, expiry time.Time, err error)) TokenSource {
+    return &cachingTokenSource{fetch: fetch}
+}
+
+type cachingTokenSource struct {
+    fetch func(context.Context) (string, time.Time, error)
+
+    mu       sync.Mutex
+    token    string
+    expiry   time.Time
+    inflight *tokenFetch
+}
+
+// tokenFetch is a fetch shared by the callers waiting for it.
+type tokenFetch struct {
+    done    chan struct{}
+    cancel  context.CancelFunc
+    waiters int
+    token   string // token and err are set before done is closed
+    err     error
+}
+
+// tokenExpiryLeeway refreshes tokens this long before they expire.
+const tokenExpiryLeeway = 10 * time.Second
+
+func (s *cachingTokenSource) Token(ctx context.Context) (string, error) {
+    s.mu.Lock()
+    if s.token != "" && (s.expiry.IsZero() || time.Now().Add(tokenExpiryLeeway).Before(s.expiry)) {
+        token := s.token
+        s.mu.Unlock()
+        return token, nil
+    }
+    f := s.inflight
+    if f == nil {
+        fctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+        f = &tokenFetch{done: make(chan struct{}), cancel: cancel}
+        s.inflight = f
+        go s.run(fctx, f)
+    }
+    f.waiters++
+    s.mu.Unlock()
+
+    select {
+    case <-f.done:
+        return f.token, f.err
+    case <-ctx.Done():
+        s.mu.Lock()
+        if f.waiters--; f.waiters == 0 {
+            f.cancel()
+            if s.inflight == f { s.inflight = nil }
+        }
+        s.mu.Unlock()
+        return "", ctx.Err()
+    }
+}
+
+// run performs f and caches its token on success.
+func (s *cachingTokenSource) run(ctx context.Context, f *tokenFetch) {
+    token, expiry, err := s.fetch(ctx)
+    f.cancel()
+    s.mu.Lock()
+    f.token, f.err = token, err
+    if s.inflight == f {
+        s.inflight = nil
+        if err == nil { s.token, s.expiry = token, expiry }
+    }
+    s.mu.Unlock()
+    close(f.done)
+}
+
+func (s *cachingTokenSource) Invalidate(token string) {
+    s.mu.Lock(); defer s.mu.Unlock()
+    if s.token == token { s.token = "" }
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestTokenSourceRefreshesOn401(t *testing.T) {
+    fetches := 0
+    ts := CachingTokenSource(func(context.Context) (string, time.Time, error) {
+        fetches++
+        return fmt.Sprintf("tok-%d", fetches), time.Now().Add(time.Hour), nil
+    })
+    var seen []string
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithTokenSource(ts))
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            auth := r.Header.Get("Authorization")
+            seen = append(seen, auth)
+            // The server has revoked the first token.
+            if auth != "Bearer tok-2" { w.WriteHeader(http.StatusUnauthorized) }
+        }),
+    }}
+
+    req, _ := http.NewRequest(http.MethodPost, "/orders", nil)
+    resp, err := c.Do(context.Background(), req)
+    if err != nil { t.Fatalf("do: %v", err) }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusOK { t.Fatalf("expected 200 after refresh, got %d", resp.StatusCode) }
+    if len(seen) != 2 || seen[0] != "Bearer tok-1" || seen[1] != "Bearer tok-2" { t.Fatalf("unexpected Authorization headers %q", seen) }
+
+    // The refreshed token is cached for later requests.
+    req, _ = http.NewRequest(http.MethodGet, "/orders", nil)
+    resp, err = c.Do(context.Background(), req)
+    if err != nil { t.Fatalf("do: %v", err) }
+    resp.Body.Close()
+    if fetches != 2 || seen[2] != "Bearer tok-2" { t.Fatalf("expected cached token reuse, fetches=%d seen=%q", fetches, seen) }
+}
+
+func TestTokenSourceGivesUpAfterOneRefresh(t *testing.T) {
+    calls := 0
+    ts := CachingTokenSource(func(context.Context) (string, time.Time, error) { return "bad", time.Time{}, nil })
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithTokenSource(ts))
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { calls++; w.WriteHeader(http.StatusUnauthorized) }),
+    }}
+    req, _ := http.NewRequest(http.MethodGet, "/x", nil)
+    resp, err := c.Do(context.Background(), req)
+    if err != nil { t.Fatalf("do: %v", err) }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusUnauthorized || calls != 2 { t.Fatalf("expected 401 after one refresh, got %d after %d calls", resp.StatusCode, calls) }
+}
+
+func TestCachingTokenSourceWaitersRespectContext(t *testing.T) {
+    var fetches atomic.Int32
+    release := make(chan struct{})
+    ts := CachingTokenSource(func(ctx context.Context) (string, time.Time, error) {
+        fetches.Add(1)
+        select {
+        case <-release:
+            return "tok", time.Now().Add(time.Hour), nil
+        case <-ctx.Done():
+            return "", time.Time{}, ctx.Err()
+        }
+    })
+
+    got := make(chan string, 1)
+    go func() {
+        token, _ := ts.Token(context.Background())
+        got <- token
+    }()
+    for fetches.Load() == 0 { time.Sleep(time.Millisecond) }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+    defer cancel()
+    start := time.Now()
+    if _, err := ts.Token(ctx); !errors.Is(err, context.DeadlineExceeded) { t.Fatalf("expected the waiter's deadline, got %v", err) }
+    if time.Since(start) > time.Second { t.Fatal("waiter blocked past its deadline") }
+
+    close(release)
+    if token := <-got; token != "tok" { t.Fatalf("expected the shared fetch's token, got %q", token) }
+    if token, err := ts.Token(context.Background()); err != nil || token != "tok" || fetches.Load() != 1 {
+        t.Fatalf("expected one cached fetch, got %q %v after %d fetches", token, err, fetches.Load())
+    }
+}
+
+func TestCachingTokenSourceCancelsAbandonedFetch(t *testing.T) {
+    cancelled := make(chan struct{})
+    ts := CachingTokenSource(func(ctx context.Context) (string, time.Time, error) {
+        <-ctx.Done()
+        close(cancelled)
+        return "", time.Time{}, ctx.Err()
+    })
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+    defer cancel()
+    ts.Token(ctx)
+    select {
+    case <-cancelled:
+    case <-time.After(time.Second):
+        t.Fatal("fetch was not cancelled after its only caller gave up")
+    }
+}
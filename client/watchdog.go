@@ -0,0 +1,38 @@
+package client
+
+import (
+    "errors"
+    "io"
+    "sync/atomic"
+    "time"
+)
+
+// ErrBodyReadTimeout is returned by a response body read that waited longer
+// than the WithBodyReadTimeout idle limit for data.
+var ErrBodyReadTimeout = errors.New("client: response body read timed out")
+
+// WithBodyReadTimeout aborts a request when a single read of its response
+// body waits longer than perRead for data, so a server that trickles bytes
+// or stalls mid-body cannot hold the caller indefinitely. Time spent by the
+// caller between reads does not count.
+func WithBodyReadTimeout(perRead time.Duration) Option { return func(c *Client) { c.bodyIdle = perRead } }
+
+// watchdogBody cancels the request via abort when a Read stalls past idle.
+type watchdogBody struct {
+    io.ReadCloser
+    idle    time.Duration
+    abort   func()
+    stalled atomic.Bool
+}
+
+func (b *watchdogBody) Read(p []byte) (int, error) {
+    if b.stalled.Load() { return 0, ErrBodyReadTimeout }
+    t := time.AfterFunc(b.idle, func() {
+        b.stalled.Store(true)
+        b.abort()
+    })
+    n, err := b.ReadCloser.Read(p)
+    t.Stop()
+    if err != nil && b.stalled.Load() { err = ErrBodyReadTimeout }
+    return n, err
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+    "context"
+    "errors"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+func TestBodyReadTimeoutAbortsStalledBody(t *testing.T) {
+    release := make(chan struct{})
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Write([]byte("x"))
+        w.(http.Flusher).Flush()
+        select {
+        case <-release:
+        case <-r.Context().Done():
+        }
+    }))
+    defer srv.Close()
+    defer close(release)
+
+    c := New([]Endpoint{{BaseURL: srv.URL}}, WithBodyReadTimeout(100*time.Millisecond))
+    req, _ := http.NewRequest(http.MethodGet, "/drip", nil)
+    resp, err := c.Do(context.Background(), req)
+    if err != nil { t.Fatalf("do: %v", err) }
+    defer resp.Body.Close()
+
+    start := time.Now()
+    b, err := io.ReadAll(resp.Body)
+    if !errors.Is(err, ErrBodyReadTimeout) { t.Fatalf("expected ErrBodyReadTimeout, got %v", err) }
+    if string(b) != "x" { t.Fatalf("expected first byte before stall, got %q", b) }
+    if d := time.Since(start); d > time.Second { t.Fatalf("read took %s to abort", d) }
+}
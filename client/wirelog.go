@@ -0,0 +1,107 @@
+package client
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "net/http"
+    "net/http/httputil"
+    "sync"
+)
+
+// WithWireLogging dumps the start line and headers of every outgoing request
+// and incoming response to w, for deep debugging. Values of the headers named
+// in redactHeaders are replaced with "[REDACTED]". Bodies are only included
+// when enabled with WithWireLogBodies.
+func WithWireLogging(w io.Writer, redactHeaders []string) Option {
+    return func(c *Client) {
+        wl := c.wireLog()
+        wl.w = w
+        for _, h := range redactHeaders { wl.redact[http.CanonicalHeaderKey(h)] = true }
+    }
+}
+
+// WithWireLogBodies includes request and response bodies in the wire log,
+// truncated to limit bytes. Only the logged bytes are read ahead; the rest of
+// each body still streams to the transport or the caller.
+func WithWireLogBodies(limit int) Option { return func(c *Client) { c.wireLog().bodyLimit = limit } }
+
+func (c *Client) wireLog() *wireLogger {
+    if c.wire == nil { c.wire = &wireLogger{redact: map[string]bool{}} }
+    return c.wire
+}
+
+type wireLogger struct {
+    mu        sync.Mutex
+    w         io.Writer
+    redact    map[string]bool
+    bodyLimit int
+}
+
+func (l *wireLogger) enabled() bool { return l != nil && l.w != nil }
+
+// logRequest writes req to the log. If bodies are enabled, req.Body is
+// replaced by a reader that replays the logged head before the rest.
+func (l *wireLogger) logRequest(req *http.Request) {
+    r2 := req.Clone(req.Context())
+    r2.Header = l.redacted(req.Header)
+    dump, err := httputil.DumpRequestOut(r2, false)
+    if err != nil { dump = []byte(fmt.Sprintf("%s %s (dump failed: %v)\r\n", req.Method, req.URL, err)) }
+    var body []byte
+    if l.bodyLimit > 0 && req.Body != nil && req.Body != http.NoBody {
+        body, req.Body = peekBody(req.Body, l.bodyLimit)
+    }
+    l.write("> ", dump, body)
+}
+
+// logResponse writes resp to the log. If bodies are enabled, resp.Body is
+// replaced by a reader that replays the logged head before the rest.
+func (l *wireLogger) logResponse(resp *http.Response, err error) {
+    if err != nil {
+        l.write("< ", []byte(fmt.Sprintf("error: %v\r\n", err)), nil)
+        return
+    }
+    r2 := *resp
+    r2.Header = l.redacted(resp.Header)
+    dump, derr := httputil.DumpResponse(&r2, false)
+    if derr != nil { dump = []byte(fmt.Sprintf("%s (dump failed: %v)\r\n", resp.Status, derr)) }
+    var body []byte
+    if l.bodyLimit > 0 && resp.Body != nil {
+        body, resp.Body = peekBody(resp.Body, l.bodyLimit)
+    }
+    l.write("< ", dump, body)
+}
+
+func (l *wireLogger) redacted(h http.Header) http.Header {
+    out := h.Clone()
+    for k := range out {
+        if l.redact[k] { out[k] = []string{"[REDACTED]"} }
+    }
+    return out
+}
+
+func (l *wireLogger) write(prefix string, head, body []byte) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    for _, line := range bytes.Split(bytes.TrimRight(head, "\r\n"), []byte("\r\n")) {
+        fmt.Fprintf(l.w, "%s%s\n", prefix, line)
+    }
+    if len(body) > 0 {
+        if len(body) > l.bodyLimit {
+            fmt.Fprintf(l.w, "%s\n%s[truncated]\n", body[:l.bodyLimit], prefix)
+        } else {
+            fmt.Fprintf(l.w, "%s\n", body)
+        }
+    }
+}
+
+// peekBody reads up to limit+1 bytes of rc, one more than is logged so
+// truncation shows, and returns them with a body that yields them again
+// followed by the rest of rc. Closing the body closes rc.
+func peekBody(rc io.ReadCloser, limit int) ([]byte, io.ReadCloser) {
+    head, _ := io.ReadAll(io.LimitReader(rc, int64(limit)+1))
+    return head, struct {
+        io.Reader
+        io.Closer
+    }{io.MultiReader(bytes.NewReader(head), rc), rc}
+}
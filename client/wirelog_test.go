@@ -0,0 +1,64 @@
+package client
+
+import (
+    "bytes"
+    "context"
+    "io"
+    "net/http"
+    "strings"
+    "testing"
+)
+
+func TestWireLoggingRedactsHeaders(t *testing.T) {
+    var log bytes.Buffer
+    var gotBody string
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithWireLogging(&log, []string{"Authorization"}), WithWireLogBodies(64))
+    c.hc.Transport = &fakeRT{handlers: map[string]http.Handler{
+        "a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            b, _ := io.ReadAll(r.Body)
+            gotBody = string(b)
+            io.WriteString(w, "pong")
+        }),
+    }}
+
+    req, _ := http.NewRequest(http.MethodPost, "/v1/ping", strings.NewReader("ping"))
+    req.Header.Set("Authorization", "Bearer secret-token")
+    resp, err := c.Do(context.Background(), req)
+    if err != nil { t.Fatalf("do: %v", err) }
+    body, _ := io.ReadAll(resp.Body)
+    resp.Body.Close()
+
+    if gotBody != "ping" || string(body) != "pong" {
+        t.Fatalf("bodies consumed by logging: sent=%q received=%q", gotBody, body)
+    }
+    out := log.String()
+    if !strings.Contains(out, "POST /v1/ping") {
+        t.Fatalf("dump missing request line: %q", out)
+    }
+    if strings.Contains(out, "secret-token") || !strings.Contains(out, "Authorization: [REDACTED]") {
+        t.Fatalf("authorization not redacted: %q", out)
+    }
+    if !strings.Contains(out, "pong") {
+        t.Fatalf("dump missing response body: %q", out)
+    }
+}
+
+func TestWireLoggingPeeksOnlyLimit(t *testing.T) {
+    var log bytes.Buffer
+    payload := strings.Repeat("x", 4096)
+    src := &countingReader{ReadCloser: io.NopCloser(strings.NewReader(payload))}
+    c := New([]Endpoint{{BaseURL: "http://a"}}, WithWireLogging(&log, nil), WithWireLogBodies(16))
+    c.hc.Transport = rtFunc(func(r *http.Request) (*http.Response, error) {
+        return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: src, Request: r}, nil
+    })
+
+    req, _ := http.NewRequest(http.MethodGet, "/download", nil)
+    resp, err := c.Do(context.Background(), req)
+    if err != nil { t.Fatalf("do: %v", err) }
+    if n := src.n.Load(); n > 17 { t.Fatalf("logging read %d bytes ahead, want at most 17", n) }
+    body, _ := io.ReadAll(resp.Body)
+    resp.Body.Close()
+
+    if string(body) != payload { t.Fatalf("caller got %d bytes, want %d", len(body), len(payload)) }
+    if !strings.Contains(log.String(), "[truncated]") { t.Fatalf("dump not truncated: %q", log.String()) }
+}
@@ -1,3 +1,3 @@
 module github.com/shkmv/httplib
 
-go 1.21
+go 1.22
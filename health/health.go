@@ -0,0 +1,241 @@
+// Package health serves liveness and readiness endpoints backed by
+// registered checks. Responses carry the overall status unless WithDetails
+// is set; per-check results are always logged on failure and available from
+// Health.Live and Health.Ready.
+package health
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "sync"
+    "time"
+
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+// Defaults for New.
+const (
+    DefaultCacheTTL = time.Second
+    DefaultTimeout  = 2 * time.Second
+)
+
+// Checker reports whether a dependency is healthy.
+type Checker interface {
+    Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to Checker.
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls f.
+func (f CheckerFunc) Check(ctx context.Context) error { return f(ctx) }
+
+// Pinger is implemented by *sql.DB and most database clients.
+type Pinger interface {
+    PingContext(ctx context.Context) error
+}
+
+// Ping checks a database (or anything with PingContext).
+func Ping(p Pinger) Checker { return CheckerFunc(p.PingContext) }
+
+// URL checks that a GET to url answers with a 2xx status. A nil client uses
+// http.DefaultClient.
+func URL(client *http.Client, url string) Checker {
+    if client == nil { client = http.DefaultClient }
+    return CheckerFunc(func(ctx context.Context) error {
+        req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+        if err != nil { return err }
+        resp, err := client.Do(req)
+        if err != nil { return err }
+        resp.Body.Close()
+        if resp.StatusCode < 200 || resp.StatusCode > 299 { return fmt.Errorf("health: %s returned %d", url, resp.StatusCode) }
+        return nil
+    })
+}
+
+// Option configures a Health.
+type Option func(*Health)
+
+// WithCacheTTL sets how long a check result is reused before the check runs
+// again, so frequent probes don't hammer dependencies. Zero disables caching.
+func WithCacheTTL(d time.Duration) Option { return func(h *Health) { h.ttl = d } }
+
+// WithTimeout bounds each check run.
+func WithTimeout(d time.Duration) Option { return func(h *Health) { h.timeout = d } }
+
+// WithDetails adds per-check status, latency and error to response bodies.
+// Only enable it when the endpoint is not reachable by untrusted clients,
+// since check names and errors can reveal internal topology.
+func WithDetails() Option { return func(h *Health) { h.details = true } }
+
+// Health is an http.Handler serving the results of its checks. Mounted at
+// /healthz it answers:
+//  /healthz/live   liveness checks only: is the process working at all
+//  /healthz/ready  liveness and readiness checks: can it take traffic
+//  /healthz        same as /ready
+// Responses are 200 when every check passes and 503 otherwise, with a body of
+// {"status":"ok"} or {"status":"fail"}. Check names and errors are only sent
+// with WithDetails, since probes are often reachable by untrusted clients;
+// failing checks are logged with ctxutil.Logger(r.Context()) either way.
+type Health struct {
+    ttl     time.Duration
+    timeout time.Duration
+    details bool
+
+    mu        sync.RWMutex
+    liveness  []*check
+    readiness []*check
+}
+
+type check struct {
+    name    string
+    checker Checker
+
+    mu     sync.Mutex
+    result Result
+    at     time.Time
+}
+
+// Result is the outcome of one check, as returned by Health.Live and
+// Health.Ready and sent in responses with WithDetails.
+type Result struct {
+    Status    string  `json:"status"`
+    LatencyMS float64 `json:"latency_ms"`
+    Error     string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of a set of checks. ServeHTTP sends only Status
+// unless WithDetails is set.
+type Report struct {
+    Status string            `json:"status"`
+    Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Status values.
+const (
+    StatusOK   = "ok"
+    StatusFail = "fail"
+)
+
+// New creates a Health with no checks, which always reports ok.
+func New(opts ...Option) *Health {
+    h := &Health{ttl: DefaultCacheTTL, timeout: DefaultTimeout}
+    for _, opt := range opts { opt(h) }
+    return h
+}
+
+// AddLiveness registers a check that failing means the process should be
+// restarted, e.g. a deadlocked worker. Keep these free of external
+// dependencies.
+func (h *Health) AddLiveness(name string, c Checker) {
+    h.mu.Lock(); defer h.mu.Unlock()
+    h.liveness = append(h.liveness, &check{name: name, checker: c})
+}
+
+// AddReadiness registers a check that failing means the process should stop
+// receiving traffic for now, e.g. a database ping.
+func (h *Health) AddReadiness(name string, c Checker) {
+    h.mu.Lock(); defer h.mu.Unlock()
+    h.readiness = append(h.readiness, &check{name: name, checker: c})
+}
+
+// Live runs the liveness checks.
+func (h *Health) Live(ctx context.Context) Report {
+    h.mu.RLock()
+    checks := append([]*check{}, h.liveness...)
+    h.mu.RUnlock()
+    return h.run(ctx, checks)
+}
+
+// Ready runs the liveness and readiness checks.
+func (h *Health) Ready(ctx context.Context) Report {
+    h.mu.RLock()
+    checks := append(append([]*check{}, h.liveness...), h.readiness...)
+    h.mu.RUnlock()
+    return h.run(ctx, checks)
+}
+
+// ServeHTTP serves the report for the request path; see Health.
+func (h *Health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        w.Header().Set("Allow", "GET, HEAD")
+        http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+        return
+    }
+    var rep Report
+    switch r.URL.Path {
+    case "/live":
+        rep = h.Live(r.Context())
+    case "", "/", "/ready":
+        rep = h.Ready(r.Context())
+    default:
+        http.NotFound(w, r)
+        return
+    }
+    status := http.StatusOK
+    if rep.Status != StatusOK {
+        status = http.StatusServiceUnavailable
+        l := ctxutil.Logger(r.Context())
+        for name, res := range rep.Checks {
+            if res.Status == StatusOK { continue }
+            l.WarnContext(r.Context(), "health check failed", slog.String("check", name), slog.String("error", res.Error), slog.Float64("latency_ms", res.LatencyMS))
+        }
+    }
+    w.Header().Set("Content-Type", "application/json; charset=utf-8")
+    w.Header().Set("Cache-Control", "no-store")
+    w.WriteHeader(status)
+    if !h.details { rep = Report{Status: rep.Status} }
+    if r.Method == http.MethodGet { _ = json.NewEncoder(w).Encode(rep) }
+}
+
+// run executes checks concurrently and aggregates their results.
+func (h *Health) run(ctx context.Context, checks []*check) Report {
+    results := make([]Result, len(checks))
+    var wg sync.WaitGroup
+    for i, c := range checks {
+        wg.Add(1)
+        go func(i int, c *check) {
+            defer wg.Done()
+            results[i] = h.result(ctx, c)
+        }(i, c)
+    }
+    wg.Wait()
+
+    rep := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+    for i, c := range checks {
+        rep.Checks[c.name] = results[i]
+        if results[i].Status != StatusOK { rep.Status = StatusFail }
+    }
+    return rep
+}
+
+// result returns c's cached result or runs it. Concurrent callers wait for
+// one run instead of each hitting the dependency.
+func (h *Health) result(ctx context.Context, c *check) Result {
+    c.mu.Lock(); defer c.mu.Unlock()
+    if !c.at.IsZero() && time.Since(c.at) < h.ttl { return c.result }
+
+    cctx := ctx
+    if h.timeout > 0 {
+        var cancel context.CancelFunc
+        cctx, cancel = context.WithTimeout(ctx, h.timeout)
+        defer cancel()
+    }
+    start := time.Now()
+    err := c.checker.Check(cctx)
+    res := Result{Status: StatusOK, LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+    if err != nil {
+        res.Status, res.Error = StatusFail, err.Error()
+        if errors.Is(err, context.DeadlineExceeded) { res.Error = "timeout: " + res.Error }
+    }
+    // Don't cache a failure caused by the prober going away: a canceled or
+    // expired request context says nothing about the dependency, and the
+    // next probe should run the check again.
+    if err != nil && (ctx.Err() != nil || errors.Is(err, context.Canceled)) { return res }
+    c.result, c.at = res, time.Now()
+    return res
+}
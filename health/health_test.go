@@ -0,0 +1,118 @@
+package health
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "log/slog"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+func serve(t *testing.T, h http.Handler, path string) (int, Report) {
+    t.Helper()
+    rr := httptest.NewRecorder()
+    h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+    var rep Report
+    if err := json.Unmarshal(rr.Body.Bytes(), &rep); err != nil { t.Fatalf("%s: bad body %q: %v", path, rr.Body.String(), err) }
+    return rr.Code, rep
+}
+
+func TestLivenessAndReadiness(t *testing.T) {
+    var dbDown atomic.Bool
+    h := New(WithCacheTTL(0))
+    h.AddLiveness("loop", CheckerFunc(func(context.Context) error { return nil }))
+    h.AddReadiness("db", CheckerFunc(func(context.Context) error {
+        if dbDown.Load() { return errors.New("connection refused") }
+        return nil
+    }))
+
+    code, rep := serve(t, h, "/ready")
+    if code != http.StatusOK || rep.Status != StatusOK || len(rep.Checks) != 0 { t.Fatalf("expected a bare healthy status, got %d %+v", code, rep) }
+    if rep := h.Ready(context.Background()); len(rep.Checks) != 2 { t.Fatalf("expected both checks in the ready report, got %+v", rep) }
+
+    dbDown.Store(true)
+    var logs bytes.Buffer
+    rr := httptest.NewRecorder()
+    req := httptest.NewRequest(http.MethodGet, "/", nil)
+    h.ServeHTTP(rr, req.WithContext(ctxutil.WithLogger(req.Context(), slog.New(slog.NewTextHandler(&logs, nil)))))
+    if rr.Code != http.StatusServiceUnavailable || rr.Body.String() != `{"status":"fail"}`+"\n" { t.Fatalf("expected a bare 503 status, got %d %q", rr.Code, rr.Body.String()) }
+    if out := logs.String(); !strings.Contains(out, "check=db") || !strings.Contains(out, `error="connection refused"`) || strings.Contains(out, "check=loop") {
+        t.Fatalf("expected the failing check to be logged, got %q", out)
+    }
+    rep = h.Ready(context.Background())
+    if db := rep.Checks["db"]; db.Status != StatusFail || db.Error != "connection refused" { t.Fatalf("unexpected db result %+v", db) }
+    if rep.Checks["loop"].Status != StatusOK { t.Fatalf("unexpected loop result %+v", rep.Checks["loop"]) }
+
+    code, _ = serve(t, h, "/live")
+    if rep := h.Live(context.Background()); code != http.StatusOK || len(rep.Checks) != 1 { t.Fatalf("liveness should ignore readiness checks, got %d %+v", code, rep) }
+}
+
+func TestResultsAreCached(t *testing.T) {
+    var calls atomic.Int32
+    h := New(WithCacheTTL(time.Hour))
+    h.AddReadiness("dep", CheckerFunc(func(context.Context) error {
+        calls.Add(1)
+        return nil
+    }))
+    for i := 0; i < 3; i++ { serve(t, h, "/ready") }
+    if n := calls.Load(); n != 1 { t.Fatalf("expected one check run, got %d", n) }
+}
+
+func TestCheckTimeout(t *testing.T) {
+    h := New(WithTimeout(10 * time.Millisecond))
+    h.AddReadiness("slow", CheckerFunc(func(ctx context.Context) error {
+        <-ctx.Done()
+        return ctx.Err()
+    }))
+    code, _ := serve(t, h, "/ready")
+    if rep := h.Ready(context.Background()); code != http.StatusServiceUnavailable || rep.Checks["slow"].Error == "" { t.Fatalf("expected timeout failure, got %d %+v", code, rep) }
+}
+
+func TestURLChecker(t *testing.T) {
+    up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+    defer up.Close()
+    down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusBadGateway) }))
+    defer down.Close()
+
+    if err := URL(nil, up.URL).Check(context.Background()); err != nil { t.Fatalf("expected healthy dependency, got %v", err) }
+    if err := URL(nil, down.URL).Check(context.Background()); err == nil { t.Fatal("expected error for 502 dependency") }
+}
+
+func TestWithDetails(t *testing.T) {
+    h := New(WithCacheTTL(0), WithDetails())
+    h.AddReadiness("db", CheckerFunc(func(context.Context) error { return errors.New("connection refused") }))
+    code, rep := serve(t, h, "/ready")
+    if code != http.StatusServiceUnavailable || rep.Checks["db"].Error != "connection refused" { t.Fatalf("expected per-check details, got %d %+v", code, rep) }
+}
+
+func TestProbeCancellationNotCached(t *testing.T) {
+    var calls atomic.Int32
+    h := New(WithCacheTTL(time.Hour))
+    h.AddReadiness("dep", CheckerFunc(func(ctx context.Context) error {
+        calls.Add(1)
+        return ctx.Err()
+    }))
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    if rep := h.Ready(ctx); rep.Status != StatusFail { t.Fatalf("expected a failure for a canceled probe, got %+v", rep) }
+    if rep := h.Ready(context.Background()); rep.Status != StatusOK { t.Fatalf("canceled probe result was cached: %+v", rep) }
+    if n := calls.Load(); n != 2 { t.Fatalf("expected the check to run again, got %d runs", n) }
+
+    calls.Store(0)
+    h2 := New(WithCacheTTL(time.Hour))
+    h2.AddReadiness("dep", CheckerFunc(func(context.Context) error {
+        calls.Add(1)
+        return context.Canceled
+    }))
+    h2.Ready(context.Background())
+    h2.Ready(context.Background())
+    if n := calls.Load(); n != 2 { t.Fatalf("expected a context.Canceled failure not to be cached, got %d runs", n) }
+}
@@ -2,13 +2,24 @@ package ctxutil
 
 import (
     "context"
+    "log/slog"
+    "time"
 )
 
 type contextKey string
 
 const (
-    keyReqID  contextKey = "router_req_id"
-    keyRealIP contextKey = "router_real_ip"
+    keyReqID    contextKey = "router_req_id"
+    keyRealIP   contextKey = "router_real_ip"
+    keyClientCN contextKey = "router_client_cn"
+    keyPattern  contextKey = "router_route_pattern"
+    keyCSPNonce contextKey = "router_csp_nonce"
+    keyTenant   contextKey = "router_tenant"
+    keyCursor   contextKey = "router_cursor"
+    keyTimeout  contextKey = "router_route_timeout"
+    keyClaims   contextKey = "router_claims"
+    keyBaggage  contextKey = "router_baggage"
+    keyLogger   contextKey = "router_logger"
 )
 
 // WithReqID stores a request ID in the context.
@@ -21,6 +32,51 @@ func WithRealIP(ctx context.Context, ip string) context.Context {
     return context.WithValue(ctx, keyRealIP, ip)
 }
 
+// WithClientCN stores the verified client certificate subject CN in the context.
+func WithClientCN(ctx context.Context, cn string) context.Context {
+    return context.WithValue(ctx, keyClientCN, cn)
+}
+
+// WithRoutePattern stores the matched route pattern in the context.
+func WithRoutePattern(ctx context.Context, pattern string) context.Context {
+    return context.WithValue(ctx, keyPattern, pattern)
+}
+
+// WithCSPNonce stores the per-request Content-Security-Policy nonce in the context.
+func WithCSPNonce(ctx context.Context, nonce string) context.Context {
+    return context.WithValue(ctx, keyCSPNonce, nonce)
+}
+
+// WithTenant stores the resolved tenant ID in the context.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+    return context.WithValue(ctx, keyTenant, tenant)
+}
+
+// WithCursor stores a verified pagination cursor payload in the context.
+func WithCursor(ctx context.Context, cursor string) context.Context {
+    return context.WithValue(ctx, keyCursor, cursor)
+}
+
+// WithRouteTimeout stores the timeout a route group set with router.WithTimeout.
+func WithRouteTimeout(ctx context.Context, d time.Duration) context.Context {
+    return context.WithValue(ctx, keyTimeout, d)
+}
+
+// WithClaims stores verified token claims in the context.
+func WithClaims(ctx context.Context, claims map[string]any) context.Context {
+    return context.WithValue(ctx, keyClaims, claims)
+}
+
+// WithBaggage stores headers to propagate to downstream requests, keyed by canonical header name.
+func WithBaggage(ctx context.Context, baggage map[string]string) context.Context {
+    return context.WithValue(ctx, keyBaggage, baggage)
+}
+
+// WithLogger stores a request-scoped logger in the context.
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+    return context.WithValue(ctx, keyLogger, l)
+}
+
 // GetReqID retrieves a request ID from the context, if set.
 func GetReqID(ctx context.Context) string {
     if v := ctx.Value(keyReqID); v != nil {
@@ -41,3 +97,95 @@ func GetRealIP(ctx context.Context) string {
     return ""
 }
 
+
+// GetClientCN retrieves the verified client certificate subject CN from the context, if set.
+func GetClientCN(ctx context.Context) string {
+    if v := ctx.Value(keyClientCN); v != nil {
+        if s, ok := v.(string); ok {
+            return s
+        }
+    }
+    return ""
+}
+
+// GetRoutePattern retrieves the matched route pattern (e.g. "/api/users/{id}") from the context, if set.
+// For routers mounted with Mount the pattern includes the mount prefix, so it
+// is a low-cardinality label for metrics and logs.
+func GetRoutePattern(ctx context.Context) string {
+    if v := ctx.Value(keyPattern); v != nil {
+        if s, ok := v.(string); ok {
+            return s
+        }
+    }
+    return ""
+}
+
+// GetCSPNonce retrieves the Content-Security-Policy nonce for inline scripts from the context, if set.
+func GetCSPNonce(ctx context.Context) string {
+    if v := ctx.Value(keyCSPNonce); v != nil {
+        if s, ok := v.(string); ok {
+            return s
+        }
+    }
+    return ""
+}
+
+// GetTenant retrieves the tenant ID resolved by the Tenant middleware from the context, if set.
+func GetTenant(ctx context.Context) string {
+    if v := ctx.Value(keyTenant); v != nil {
+        if s, ok := v.(string); ok {
+            return s
+        }
+    }
+    return ""
+}
+
+// GetCursor retrieves the verified pagination cursor payload from the context, or "" on the first page.
+func GetCursor(ctx context.Context) string {
+    if v := ctx.Value(keyCursor); v != nil {
+        if s, ok := v.(string); ok {
+            return s
+        }
+    }
+    return ""
+}
+
+// GetRouteTimeout retrieves the matched route's own timeout, or 0 if its group set none.
+func GetRouteTimeout(ctx context.Context) time.Duration {
+    if v := ctx.Value(keyTimeout); v != nil {
+        if d, ok := v.(time.Duration); ok {
+            return d
+        }
+    }
+    return 0
+}
+
+// GetClaims retrieves the claims of the token verified by the JWT middleware from the context, if set.
+func GetClaims(ctx context.Context) map[string]any {
+    if v := ctx.Value(keyClaims); v != nil {
+        if c, ok := v.(map[string]any); ok {
+            return c
+        }
+    }
+    return nil
+}
+
+// GetBaggage retrieves the headers to propagate downstream from the context, if set. Do not modify the map.
+func GetBaggage(ctx context.Context) map[string]string {
+    if v := ctx.Value(keyBaggage); v != nil {
+        if b, ok := v.(map[string]string); ok {
+            return b
+        }
+    }
+    return nil
+}
+
+// Logger retrieves the request-scoped logger set by the ContextLogger middleware, or slog.Default() if none is set.
+func Logger(ctx context.Context) *slog.Logger {
+    if v := ctx.Value(keyLogger); v != nil {
+        if l, ok := v.(*slog.Logger); ok {
+            return l
+        }
+    }
+    return slog.Default()
+}
@@ -0,0 +1,73 @@
+package router
+
+import (
+    "encoding/json"
+    "encoding/xml"
+    "errors"
+    "fmt"
+    "io"
+    "mime"
+    "net/http"
+    "strings"
+)
+
+// DefaultMaxBodyBytes is the body size limit applied by Decode.
+const DefaultMaxBodyBytes = 1 << 20
+
+var (
+    // ErrUnsupportedMediaType is returned by Decode for a Content-Type it
+    // has no decoder for.
+    ErrUnsupportedMediaType = errors.New("router: unsupported media type")
+    // ErrBodyTooLarge is returned by Decode when the body exceeds the limit.
+    ErrBodyTooLarge = errors.New("router: request body too large")
+)
+
+// Decode decodes the request body into dst, choosing the decoder from the
+// Content-Type: JSON (application/json or any +json type) or XML
+// (application/xml, text/xml or any +xml type). JSON bodies with unknown
+// fields or trailing data are rejected. Bodies over DefaultMaxBodyBytes
+// return ErrBodyTooLarge.
+func Decode(r *http.Request, dst any) error { return DecodeLimit(r, dst, DefaultMaxBodyBytes) }
+
+// DecodeLimit is Decode with an explicit body size limit in bytes.
+func DecodeLimit(r *http.Request, dst any, limit int64) error {
+    mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+    if err != nil { return fmt.Errorf("%w: %q", ErrUnsupportedMediaType, r.Header.Get("Content-Type")) }
+    body := &limitedBody{r: r.Body, n: limit}
+    switch {
+    case mt == "application/json" || strings.HasSuffix(mt, "+json"):
+        dec := json.NewDecoder(body)
+        dec.DisallowUnknownFields()
+        if err := dec.Decode(dst); err != nil { return body.wrap(err) }
+        if _, err := dec.Token(); err != io.EOF { return body.wrap(errors.New("router: unexpected data after JSON body")) }
+        return nil
+    case mt == "application/xml" || mt == "text/xml" || strings.HasSuffix(mt, "+xml"):
+        return body.wrap(xml.NewDecoder(body).Decode(dst))
+    }
+    return fmt.Errorf("%w: %q", ErrUnsupportedMediaType, mt)
+}
+
+// limitedBody reads at most n bytes and remembers whether the limit was hit.
+type limitedBody struct {
+    r        io.Reader
+    n        int64
+    exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+    if b.n <= 0 {
+        b.exceeded = true
+        return 0, ErrBodyTooLarge
+    }
+    if int64(len(p)) > b.n { p = p[:b.n] }
+    n, err := b.r.Read(p)
+    b.n -= int64(n)
+    return n, err
+}
+
+// wrap reports ErrBodyTooLarge in place of the decoder's error when the
+// limit was reached.
+func (b *limitedBody) wrap(err error) error {
+    if err != nil && b.exceeded { return ErrBodyTooLarge }
+    return err
+}
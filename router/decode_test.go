@@ -0,0 +1,47 @@
+package router_test
+
+import (
+    "errors"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "github.com/shkmv/httplib/router"
+)
+
+type order struct {
+    ID  string `json:"id" xml:"id"`
+    Qty int    `json:"qty" xml:"qty"`
+}
+
+func TestDecodeByContentType(t *testing.T) {
+    cases := []struct{ ctype, body string }{
+        {"application/json; charset=utf-8", `{"id":"a1","qty":3}`},
+        {"application/xml", `<order><id>a1</id><qty>3</qty></order>`},
+    }
+    for _, tc := range cases {
+        req := httptest.NewRequest("POST", "/orders", strings.NewReader(tc.body))
+        req.Header.Set("Content-Type", tc.ctype)
+        var o order
+        if err := router.Decode(req, &o); err != nil { t.Fatalf("%s: %v", tc.ctype, err) }
+        if o.ID != "a1" || o.Qty != 3 { t.Fatalf("%s: unexpected result %+v", tc.ctype, o) }
+    }
+}
+
+func TestDecodeRejections(t *testing.T) {
+    decode := func(ctype, body string, limit int64) error {
+        req := httptest.NewRequest("POST", "/orders", strings.NewReader(body))
+        req.Header.Set("Content-Type", ctype)
+        var o order
+        return router.DecodeLimit(req, &o, limit)
+    }
+    if err := decode("application/json", `{"id":"a1","extra":true}`, 1024); err == nil || !strings.Contains(err.Error(), "unknown field") {
+        t.Fatalf("expected unknown field error, got %v", err)
+    }
+    if err := decode("application/json", `{"id":"`+strings.Repeat("x", 100)+`"}`, 32); !errors.Is(err, router.ErrBodyTooLarge) {
+        t.Fatalf("expected ErrBodyTooLarge, got %v", err)
+    }
+    if err := decode("text/plain", "id=a1", 1024); !errors.Is(err, router.ErrUnsupportedMediaType) {
+        t.Fatalf("expected ErrUnsupportedMediaType, got %v", err)
+    }
+}
@@ -0,0 +1,132 @@
+package router
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+)
+
+// HTTPError is an error carrying the response it should produce. Handlers
+// registered with the E variants (GetE, HandleE, ...) can return one to pick
+// the status and error envelope:
+//  return &router.HTTPError{Status: http.StatusNotFound, Code: "user_not_found", Message: "no such user"}
+type HTTPError struct {
+    Status  int
+    Code    string
+    Message string
+    Details any
+    // Err is the underlying cause, if any, reachable via errors.Is/As.
+    Err error
+}
+
+func (e *HTTPError) Error() string {
+    if e.Message == "" { return e.Code }
+    return e.Code + ": " + e.Message
+}
+
+func (e *HTTPError) Unwrap() error { return e.Err }
+
+// Errorf returns an *HTTPError with the formatted message. A %w verb in
+// format records the wrapped error as Err but is left out of Message, along
+// with a trailing separator, so the cause's text is not sent to clients:
+//  return router.Errorf(http.StatusConflict, "email_taken", "email %q is in use: %w", email, err)
+// renders the message `email "a@b.c" is in use`.
+func Errorf(status int, code, format string, args ...any) *HTTPError {
+    err := fmt.Errorf(format, args...)
+    msg := strings.TrimRight(fmt.Sprintf(withoutWrapVerbs(format), args...), " :;,")
+    return &HTTPError{Status: status, Code: code, Message: msg, Err: errors.Unwrap(err)}
+}
+
+// withoutWrapVerbs rewrites each %w in format to %.0v, which consumes its
+// argument but prints nothing.
+func withoutWrapVerbs(format string) string {
+    var b strings.Builder
+    for i := 0; i < len(format); i++ {
+        c := format[i]
+        if c != '%' || i+1 == len(format) {
+            b.WriteByte(c)
+            continue
+        }
+        if format[i+1] == 'w' {
+            b.WriteString("%.0v")
+        } else {
+            b.WriteByte(c)
+            b.WriteByte(format[i+1])
+        }
+        i++
+    }
+    return b.String()
+}
+
+// RenderFromError renders err as an error envelope, choosing the status by
+// inspecting its errors.As chain:
+//   - *HTTPError: its status, code, message and details
+//   - validation errors understood by RenderValidation: 422 "validation_failed"
+//   - ErrBodyTooLarge: 413 "body_too_large"
+//   - ErrUnsupportedMediaType: 415 "unsupported_media_type"
+//   - context.DeadlineExceeded: 504 "timeout"
+// Anything else is a 500 "internal_error" that does not expose err's text.
+func RenderFromError(w http.ResponseWriter, r *http.Request, err error) {
+    var he *HTTPError
+    if errors.As(err, &he) {
+        status := he.Status
+        if status == 0 { status = http.StatusInternalServerError }
+        RenderError(w, r, status, he.Code, he.Message, he.Details)
+        return
+    }
+    if violations, ok := fieldViolations(err); ok {
+        UnprocessableEntity(w, r, "validation_failed", "request validation failed", violations)
+        return
+    }
+    switch {
+    case errors.Is(err, ErrBodyTooLarge):
+        RenderError(w, r, http.StatusRequestEntityTooLarge, "body_too_large", err.Error(), nil)
+    case errors.Is(err, ErrUnsupportedMediaType):
+        RenderError(w, r, http.StatusUnsupportedMediaType, "unsupported_media_type", err.Error(), nil)
+    case errors.Is(err, context.DeadlineExceeded):
+        RenderError(w, r, http.StatusGatewayTimeout, "timeout", "request timed out", nil)
+    default:
+        InternalError(w, r, "internal_error", http.StatusText(http.StatusInternalServerError))
+    }
+}
+
+// ErrorHandlerFunc renders an error returned by a handler.
+type ErrorHandlerFunc func(w http.ResponseWriter, r *http.Request, err error)
+
+// HandlerFuncE is a handler that returns an error instead of rendering it.
+// The error is passed to the router's ErrorHandler; return it before writing
+// any of the response.
+type HandlerFuncE func(w http.ResponseWriter, r *http.Request) error
+
+// ErrorHandler sets how errors returned by E handlers are rendered, for this
+// router and every router sharing its routes. The default is RenderFromError.
+func (r *Router) ErrorHandler(h ErrorHandlerFunc) {
+    r.routes.mu.Lock(); defer r.routes.mu.Unlock()
+    r.routes.onError = h
+}
+
+// E adapts fn to an http.Handler that renders its error with the router's
+// ErrorHandler.
+func (r *Router) E(fn HandlerFuncE) http.Handler {
+    routes := r.routes
+    return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        err := fn(w, req)
+        if err == nil { return }
+        routes.mu.RLock()
+        onError := routes.onError
+        routes.mu.RUnlock()
+        if onError == nil { onError = RenderFromError }
+        onError(w, req, err)
+    })
+}
+
+// Error-returning variants of the registration helpers.
+func (r *Router) HandleE(pattern string, fn HandlerFuncE)              { r.Handle(pattern, r.E(fn)) }
+func (r *Router) MethodE(method, pattern string, fn HandlerFuncE)      { r.Method(method, pattern, r.E(fn)) }
+func (r *Router) GetE(pattern string, fn HandlerFuncE)                 { r.Get(pattern, r.E(fn)) }
+func (r *Router) PostE(pattern string, fn HandlerFuncE)                { r.Post(pattern, r.E(fn)) }
+func (r *Router) PutE(pattern string, fn HandlerFuncE)                 { r.Put(pattern, r.E(fn)) }
+func (r *Router) PatchE(pattern string, fn HandlerFuncE)               { r.Patch(pattern, r.E(fn)) }
+func (r *Router) DeleteE(pattern string, fn HandlerFuncE)              { r.Delete(pattern, r.E(fn)) }
@@ -0,0 +1,97 @@
+package router
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+func TestErrorReturningHandlers(t *testing.T) {
+    r := New()
+    r.GetE("/users/{id}", func(w http.ResponseWriter, req *http.Request) error {
+        switch req.PathValue("id") {
+        case "1":
+            RenderOK(w, req, "alice")
+            return nil
+        case "2":
+            return fmt.Errorf("lookup: %w", &HTTPError{Status: http.StatusNotFound, Code: "user_not_found", Message: "no such user"})
+        }
+        return errors.New("db: connection reset")
+    })
+
+    cases := []struct {
+        path   string
+        status int
+        code   string
+        msg    string
+    }{
+        {"/users/2", http.StatusNotFound, "user_not_found", "no such user"},
+        {"/users/3", http.StatusInternalServerError, "internal_error", "Internal Server Error"},
+    }
+    for _, c := range cases {
+        rr := httptest.NewRecorder()
+        r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, c.path, nil))
+        var env ErrorEnvelope
+        json.Unmarshal(rr.Body.Bytes(), &env)
+        if rr.Code != c.status || env.Error != c.code || env.Message != c.msg {
+            t.Fatalf("GET %s: expected %d %s %q, got %d %+v", c.path, c.status, c.code, c.msg, rr.Code, env)
+        }
+    }
+
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+    if rr.Code != http.StatusOK { t.Fatalf("expected 200, got %d", rr.Code) }
+
+    var seen error
+    r.ErrorHandler(func(w http.ResponseWriter, req *http.Request, err error) {
+        seen = err
+        RenderError(w, req, http.StatusBadGateway, "upstream", err.Error(), nil)
+    })
+    rr = httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users/3", nil))
+    if rr.Code != http.StatusBadGateway || seen == nil || seen.Error() != "db: connection reset" {
+        t.Fatalf("expected custom error handler, got %d %v", rr.Code, seen)
+    }
+}
+
+func TestRenderFromError(t *testing.T) {
+    cause := errors.New("unique violation")
+    cases := []struct {
+        err    error
+        status int
+        code   string
+    }{
+        {Errorf(http.StatusConflict, "email_taken", "email %q is in use: %w", "a@b.c", cause), http.StatusConflict, "email_taken"},
+        {fmt.Errorf("decode: %w", ErrBodyTooLarge), http.StatusRequestEntityTooLarge, "body_too_large"},
+        {fmt.Errorf("decode: %w", ErrUnsupportedMediaType), http.StatusUnsupportedMediaType, "unsupported_media_type"},
+        {fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, "timeout"},
+        {mockViolations{}, http.StatusUnprocessableEntity, "validation_failed"},
+        {errors.New("boom"), http.StatusInternalServerError, "internal_error"},
+    }
+    for _, c := range cases {
+        rr := httptest.NewRecorder()
+        RenderFromError(rr, httptest.NewRequest(http.MethodGet, "/", nil), c.err)
+        var env ErrorEnvelope
+        json.Unmarshal(rr.Body.Bytes(), &env)
+        if rr.Code != c.status || env.Error != c.code { t.Fatalf("%v: expected %d %s, got %d %+v", c.err, c.status, c.code, rr.Code, env) }
+    }
+
+    err := Errorf(http.StatusConflict, "email_taken", "email %q is in use: %w", "a@b.c", cause)
+    if !errors.Is(err, cause) || err.Message != `email "a@b.c" is in use` { t.Fatalf("unexpected Errorf result %+v", err) }
+    rr := httptest.NewRecorder()
+    RenderFromError(rr, httptest.NewRequest(http.MethodGet, "/", nil), err)
+    if strings.Contains(rr.Body.String(), "unique violation") { t.Fatalf("cause leaked to the client: %s", rr.Body.String()) }
+    if m := Errorf(http.StatusBadRequest, "bad", "100%% %s, %w", "sure", cause).Message; m != "100% sure" { t.Fatalf("unexpected message %q", m) }
+}
+
+type mockViolations struct{}
+
+func (mockViolations) Error() string { return "invalid" }
+func (mockViolations) FieldViolations() []FieldViolation {
+    return []FieldViolation{{Field: "email", Message: "required"}}
+}
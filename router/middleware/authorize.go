@@ -0,0 +1,26 @@
+package middleware
+
+import (
+    "context"
+    "net/http"
+
+    "github.com/shkmv/httplib/router"
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+// Authorize consults policy with the matched route pattern (not the raw path)
+// and the request method, responding 403 when it denies the request. Routes
+// registered through router.Router record their pattern before middlewares
+// run, so this can be installed once with Use for centralized RBAC.
+func Authorize(policy func(ctx context.Context, routePattern, method string) bool) router.Middleware {
+    return router.Named("Authorize", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            ctx := r.Context()
+            if !policy(ctx, ctxutil.GetRoutePattern(ctx), r.Method) {
+                router.Forbidden(w, r, "forbidden", "access to this route is not allowed")
+                return
+            }
+            next.ServeHTTP(w, r)
+        })
+    })
+}
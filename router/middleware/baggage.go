@@ -0,0 +1,51 @@
+package middleware
+
+import (
+    "net/http"
+    "strings"
+
+    "github.com/shkmv/httplib/router"
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+// DefaultBaggageHeaders are the headers Baggage propagates by default.
+var DefaultBaggageHeaders = []string{"X-Request-ID", "Traceparent", "Tracestate", "X-B3-*", "B3", "X-Tenant-ID"}
+
+// Baggage collects correlation headers from the request into the context
+// (read them with ctxutil.GetBaggage) so they can be forwarded on outgoing
+// calls; client.WithBaggage does that for client.Client. A name ending in
+// "*" matches every header with that prefix, e.g. "X-B3-*". Without headers,
+// DefaultBaggageHeaders are used. A request ID generated by RequestID
+// earlier in the chain is carried as X-Request-ID when the request had none.
+func Baggage(headers ...string) router.Middleware {
+    if len(headers) == 0 { headers = DefaultBaggageHeaders }
+    exact := map[string]bool{}
+    var prefixes []string
+    for _, h := range headers {
+        if p, ok := strings.CutSuffix(h, "*"); ok {
+            prefixes = append(prefixes, http.CanonicalHeaderKey(p))
+        } else {
+            exact[http.CanonicalHeaderKey(h)] = true
+        }
+    }
+    return router.Named("Baggage", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            baggage := map[string]string{}
+            for k, v := range r.Header {
+                if len(v) == 0 || v[0] == "" { continue }
+                if exact[k] {
+                    baggage[k] = strings.Join(v, ",")
+                    continue
+                }
+                for _, p := range prefixes {
+                    if strings.HasPrefix(k, p) {
+                        baggage[k] = strings.Join(v, ",")
+                        break
+                    }
+                }
+            }
+            if id := ctxutil.GetReqID(r.Context()); id != "" && exact["X-Request-Id"] && baggage["X-Request-Id"] == "" { baggage["X-Request-Id"] = id }
+            next.ServeHTTP(w, r.WithContext(ctxutil.WithBaggage(r.Context(), baggage)))
+        })
+    })
+}
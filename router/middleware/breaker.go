@@ -0,0 +1,176 @@
+package middleware
+
+import (
+    "fmt"
+    "io"
+    "net/http"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/shkmv/httplib/router"
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+    BreakerClosed   BreakerState = iota // requests flow; outcomes are counted
+    BreakerOpen                         // requests fail fast
+    BreakerHalfOpen                     // a few probe requests test recovery
+)
+
+func (s BreakerState) String() string {
+    switch s {
+    case BreakerOpen:
+        return "open"
+    case BreakerHalfOpen:
+        return "half-open"
+    }
+    return "closed"
+}
+
+// CircuitBreakerConfig configures CircuitBreaker.
+type CircuitBreakerConfig struct {
+    Window        time.Duration              // outcomes are counted per window of this length; default 10s
+    MinRequests   int                        // requests a window needs before it can trip; default 20
+    ErrorRate     float64                    // failing share of a window that trips; default 0.5
+    SlowThreshold time.Duration              // requests at least this slow count as failures; 0 disables
+    OpenFor       time.Duration              // how long to fail fast before probing; default 30s
+    Probes        int                        // successful probes needed to close again; default 3
+    IsFailure     func(status int) bool      // default: status >= 500
+    Key           func(*http.Request) string // breaker per key; default the route pattern
+    Registry      *MetricsRegistry           // exposes breaker state and rejections; nil disables
+}
+
+// CircuitBreaker protects struggling routes and their downstreams by failing
+// fast. Each route (or cfg.Key) has its own breaker, which opens when at
+// least ErrorRate of a window's requests fail or are slower than
+// SlowThreshold. While open it answers 503 "circuit_open" with Retry-After
+// without calling the handler; after OpenFor it lets up to Probes requests
+// through at a time, closing after Probes successes and reopening on any
+// failure. A panicking handler counts as a failure.
+//
+// With a Registry the gauge http_circuit_breaker_state (0 closed, 1 open,
+// 2 half-open) and the counter http_circuit_breaker_rejected_total are
+// exposed per key.
+func CircuitBreaker(cfg CircuitBreakerConfig) router.Middleware {
+    if cfg.Window <= 0 { cfg.Window = 10 * time.Second }
+    if cfg.MinRequests <= 0 { cfg.MinRequests = 20 }
+    if cfg.ErrorRate <= 0 { cfg.ErrorRate = 0.5 }
+    if cfg.OpenFor <= 0 { cfg.OpenFor = 30 * time.Second }
+    if cfg.Probes <= 0 { cfg.Probes = 3 }
+    if cfg.IsFailure == nil { cfg.IsFailure = func(status int) bool { return status >= 500 } }
+    if cfg.Key == nil {
+        cfg.Key = func(r *http.Request) string {
+            if p := ctxutil.GetRoutePattern(r.Context()); p != "" { return p }
+            return "unmatched"
+        }
+    }
+    cb := &circuitBreakers{cfg: cfg, breakers: map[string]*breaker{}}
+    if cfg.Registry != nil { cfg.Registry.Register(cb) }
+    return router.Named("CircuitBreaker", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            key := cfg.Key(r)
+            wait, ok := cb.allow(key)
+            if !ok {
+                w.Header().Set("Retry-After", ceilSeconds(wait))
+                router.RenderError(w, r, http.StatusServiceUnavailable, "circuit_open", "service temporarily unavailable", nil)
+                return
+            }
+            start := time.Now()
+            srw := &statusResponseWriter{ResponseWriter: w}
+            failed := true
+            defer func() { cb.record(key, failed) }()
+            next.ServeHTTP(srw, r)
+            status := srw.status
+            if status == 0 { status = http.StatusOK }
+            failed = cfg.IsFailure(status) || (cfg.SlowThreshold > 0 && time.Since(start) >= cfg.SlowThreshold)
+        })
+    })
+}
+
+type circuitBreakers struct {
+    cfg      CircuitBreakerConfig
+    mu       sync.Mutex
+    breakers map[string]*breaker
+}
+
+type breaker struct {
+    state              BreakerState
+    windowStart        time.Time
+    total, failures    int
+    openedAt           time.Time
+    probing, successes int // half-open probes in flight and succeeded
+    rejected           uint64
+}
+
+// allow reports whether a request for key may proceed, or how long the
+// breaker stays open.
+func (c *circuitBreakers) allow(key string) (time.Duration, bool) {
+    c.mu.Lock(); defer c.mu.Unlock()
+    b := c.breakers[key]
+    if b == nil {
+        b = &breaker{windowStart: time.Now()}
+        c.breakers[key] = b
+    }
+    if b.state == BreakerOpen {
+        if wait := c.cfg.OpenFor - time.Since(b.openedAt); wait > 0 {
+            b.rejected++
+            return wait, false
+        }
+        b.state, b.probing, b.successes = BreakerHalfOpen, 0, 0
+    }
+    if b.state == BreakerHalfOpen {
+        if b.probing+b.successes >= c.cfg.Probes {
+            b.rejected++
+            return time.Second, false
+        }
+        b.probing++
+    }
+    return 0, true
+}
+
+func (c *circuitBreakers) record(key string, failed bool) {
+    c.mu.Lock(); defer c.mu.Unlock()
+    b := c.breakers[key]
+    now := time.Now()
+    switch b.state {
+    case BreakerHalfOpen:
+        if b.probing > 0 { b.probing-- }
+        if failed {
+            b.state, b.openedAt = BreakerOpen, now
+        } else if b.successes++; b.successes >= c.cfg.Probes {
+            b.state, b.windowStart, b.total, b.failures = BreakerClosed, now, 0, 0
+        }
+    case BreakerClosed:
+        if now.Sub(b.windowStart) >= c.cfg.Window { b.windowStart, b.total, b.failures = now, 0, 0 }
+        b.total++
+        if failed { b.failures++ }
+        if b.total >= c.cfg.MinRequests && float64(b.failures) >= c.cfg.ErrorRate*float64(b.total) {
+            b.state, b.openedAt = BreakerOpen, now
+        }
+    }
+}
+
+// WriteMetrics implements MetricsCollector.
+func (c *circuitBreakers) WriteMetrics(w io.Writer) error {
+    c.mu.Lock()
+    keys := make([]string, 0, len(c.breakers))
+    for k := range c.breakers { keys = append(keys, k) }
+    sort.Strings(keys)
+    var state, rejected strings.Builder
+    for _, k := range keys {
+        b := c.breakers[k]
+        st := b.state
+        if st == BreakerOpen && time.Since(b.openedAt) >= c.cfg.OpenFor { st = BreakerHalfOpen }
+        fmt.Fprintf(&state, "http_circuit_breaker_state{key=\"%s\"} %d\n", escapeLabel(k), st)
+        fmt.Fprintf(&rejected, "http_circuit_breaker_rejected_total{key=\"%s\"} %d\n", escapeLabel(k), b.rejected)
+    }
+    c.mu.Unlock()
+    _, err := fmt.Fprintf(w, "# HELP http_circuit_breaker_state Circuit breaker state: 0 closed, 1 open, 2 half-open.\n# TYPE http_circuit_breaker_state gauge\n%s"+
+        "# HELP http_circuit_breaker_rejected_total Requests rejected by an open circuit breaker.\n# TYPE http_circuit_breaker_rejected_total counter\n%s", state.String(), rejected.String())
+    return err
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+    "bytes"
+    "net/http"
+)
+
+// bufferedResponseWriter holds back the status and body written by a handler
+// so a middleware can inspect them before anything reaches the client.
+// Headers are written straight through to the underlying ResponseWriter.
+type bufferedResponseWriter struct {
+    http.ResponseWriter
+    status int
+    buf    bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) WriteHeader(code int) {
+    // Informational responses (e.g. 100 Continue) cannot be held back.
+    if code < 200 && code != http.StatusSwitchingProtocols {
+        w.ResponseWriter.WriteHeader(code)
+        return
+    }
+    if w.status == 0 { w.status = code }
+}
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+    if w.status == 0 { w.status = http.StatusOK }
+    return w.buf.Write(b)
+}
+
+// code returns the buffered status, defaulting to 200 when nothing was written.
+func (w *bufferedResponseWriter) code() int {
+    if w.status == 0 { return http.StatusOK }
+    return w.status
+}
+
+// flush sends the buffered status and body to the underlying writer.
+func (w *bufferedResponseWriter) flush() {
+    w.ResponseWriter.WriteHeader(w.code())
+    _, _ = w.ResponseWriter.Write(w.buf.Bytes())
+}
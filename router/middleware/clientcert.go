@@ -0,0 +1,37 @@
+package middleware
+
+import (
+    "crypto/x509"
+    "net/http"
+
+    "github.com/shkmv/httplib/router"
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+// RequireClientCert rejects requests that did not present a TLS client
+// certificate verified by the TLS layer, or whose verified leaf certificate
+// fails verify, with 403. Certificates accepted without verification, as under
+// tls.RequestClientCert or tls.RequireAnyClientCert, are rejected. On success
+// the certificate subject CN is stored in context (see ctxutil.GetClientCN).
+// A nil verify accepts any verified certificate.
+func RequireClientCert(verify func(*x509.Certificate) bool) router.Middleware {
+    return router.Named("RequireClientCert", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+                router.Forbidden(w, r, "client_cert_required", "a client certificate is required")
+                return
+            }
+            if len(r.TLS.VerifiedChains) == 0 {
+                router.Forbidden(w, r, "client_cert_rejected", "client certificate not accepted")
+                return
+            }
+            leaf := r.TLS.VerifiedChains[0][0]
+            if verify != nil && !verify(leaf) {
+                router.Forbidden(w, r, "client_cert_rejected", "client certificate not accepted")
+                return
+            }
+            r = r.WithContext(ctxutil.WithClientCN(r.Context(), leaf.Subject.CommonName))
+            next.ServeHTTP(w, r)
+        })
+    })
+}
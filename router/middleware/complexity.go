@@ -0,0 +1,44 @@
+package middleware
+
+import (
+    "net/http"
+    "strings"
+
+    "github.com/shkmv/httplib/router"
+)
+
+// LimitRequestComplexity rejects with 400 requests carrying more than
+// maxParams query parameters or more than maxHeaders header values, before
+// the query is parsed into a map. A limit of 0 or less disables that check.
+func LimitRequestComplexity(maxParams, maxHeaders int) router.Middleware {
+    return router.Named("LimitRequestComplexity", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if maxParams > 0 && countQueryParams(r.URL.RawQuery) > maxParams {
+                router.BadRequest(w, r, "too_many_params", "request has too many query parameters", map[string]any{"max": maxParams})
+                return
+            }
+            if maxHeaders > 0 && countHeaderValues(r.Header) > maxHeaders {
+                router.BadRequest(w, r, "too_many_headers", "request has too many headers", map[string]any{"max": maxHeaders})
+                return
+            }
+            next.ServeHTTP(w, r)
+        })
+    })
+}
+
+// countQueryParams counts the non-empty key/value pairs in a raw query.
+func countQueryParams(raw string) int {
+    n := 0
+    for raw != "" {
+        var pair string
+        pair, raw, _ = strings.Cut(raw, "&")
+        if pair != "" { n++ }
+    }
+    return n
+}
+
+func countHeaderValues(h http.Header) int {
+    n := 0
+    for _, vs := range h { n += len(vs) }
+    return n
+}
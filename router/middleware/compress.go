@@ -0,0 +1,230 @@
+package middleware
+
+import (
+    "compress/flate"
+    "compress/gzip"
+    "compress/zlib"
+    "fmt"
+    "io"
+    "mime"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+
+    "github.com/shkmv/httplib/router"
+)
+
+// DefaultCompressTypes are the content types Compress handles when none are
+// given. Images, archives and other already-compressed types are left alone.
+var DefaultCompressTypes = []string{
+    "text/*",
+    "application/json",
+    "application/javascript",
+    "application/xml",
+    "application/problem+json",
+    "image/svg+xml",
+}
+
+// Compressor creates an encoder writing to w at the given level.
+type Compressor func(w io.Writer, level int) io.WriteCloser
+
+// CompressEncoder is an extra Content-Encoding for CompressWithConfig.
+type CompressEncoder struct {
+    Encoding string
+    New      Compressor
+}
+
+// CompressConfig configures CompressWithConfig.
+type CompressConfig struct {
+    Level    int               // compress/flate level; 0 means gzip.DefaultCompression
+    Types    []string          // default DefaultCompressTypes
+    Encoders []CompressEncoder // preferred over gzip and deflate when the client rates them equally
+}
+
+type namedCompressor struct {
+    encoding string
+    new      Compressor
+}
+
+// Compress compresses responses whose Content-Type matches types (default
+// DefaultCompressTypes; "text/*" style wildcards allowed) with the best
+// encoding the client accepts, gzip or deflate (zlib format). level is a
+// compress/flate level such as gzip.DefaultCompression. Responses that
+// already have a Content-Encoding, have no body (204, 304), are partial (206
+// or with a Content-Range), or don't match are sent as is. Content-Length is
+// dropped from compressed responses and a strong ETag is weakened, since the
+// bytes differ from the identity representation. HEAD responses get the same
+// headers as the matching GET without running an encoder. Place Logger before
+// Compress to log the number of bytes actually sent.
+func Compress(level int, types ...string) router.Middleware {
+    return compress(level, types, nil)
+}
+
+// CompressWithConfig is Compress with extra encodings, tried before gzip and
+// deflate. The standard library has no brotli encoder, so plug one in here:
+//  middleware.CompressWithConfig(middleware.CompressConfig{Encoders: []middleware.CompressEncoder{{
+//      Encoding: "br",
+//      New:      func(w io.Writer, level int) io.WriteCloser { return brotli.NewWriterLevel(w, level) },
+//  }}})
+func CompressWithConfig(cfg CompressConfig) router.Middleware {
+    if cfg.Level == 0 { cfg.Level = gzip.DefaultCompression }
+    return compress(cfg.Level, cfg.Types, cfg.Encoders)
+}
+
+func compress(level int, types []string, extra []CompressEncoder) router.Middleware {
+    if level < flate.HuffmanOnly || level > flate.BestCompression { panic(fmt.Sprintf("middleware: invalid compression level %d", level)) }
+    if len(types) == 0 { types = DefaultCompressTypes }
+    gzipPool := sync.Pool{New: func() any { zw, _ := gzip.NewWriterLevel(io.Discard, level); return zw }}
+    zlibPool := sync.Pool{New: func() any { zw, _ := zlib.NewWriterLevel(io.Discard, level); return zw }}
+
+    encoders := make([]namedCompressor, 0, len(extra)+2)
+    for _, e := range extra {
+        if e.Encoding == "" || e.New == nil { panic("middleware: CompressEncoder needs an Encoding and New") }
+        encoders = append(encoders, namedCompressor{strings.ToLower(e.Encoding), e.New})
+    }
+    encoders = append(encoders,
+        namedCompressor{"gzip", func(w io.Writer, _ int) io.WriteCloser {
+            zw := gzipPool.Get().(*gzip.Writer)
+            zw.Reset(w)
+            return &pooledEncoder{WriteCloser: zw, release: func() { gzipPool.Put(zw) }}
+        }},
+        // HTTP "deflate" is the zlib format (RFC 9110 section 8.4.1.2), not raw deflate.
+        namedCompressor{"deflate", func(w io.Writer, _ int) io.WriteCloser {
+            zw := zlibPool.Get().(*zlib.Writer)
+            zw.Reset(w)
+            return &pooledEncoder{WriteCloser: zw, release: func() { zlibPool.Put(zw) }}
+        }},
+    )
+
+    return router.Named("Compress", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            w.Header().Add("Vary", "Accept-Encoding")
+            enc, ok := negotiateEncoding(r.Header.Get("Accept-Encoding"), encoders)
+            if !ok {
+                next.ServeHTTP(w, r)
+                return
+            }
+            cw := &compressWriter{ResponseWriter: w, encoder: enc, level: level, types: types, head: r.Method == http.MethodHead}
+            defer cw.close()
+            next.ServeHTTP(cw, r)
+        })
+    })
+}
+
+// negotiateEncoding picks the encoder with the highest q-value in an
+// Accept-Encoding header, breaking ties by the order of encoders.
+func negotiateEncoding(header string, encoders []namedCompressor) (namedCompressor, bool) {
+    if header == "" { return namedCompressor{}, false }
+    q := map[string]float64{}
+    for _, part := range strings.Split(header, ",") {
+        name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+        weight := 1.0
+        if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+            if f, err := strconv.ParseFloat(v, 64); err == nil { weight = f }
+        }
+        q[strings.ToLower(strings.TrimSpace(name))] = weight
+    }
+    var best namedCompressor
+    bestQ := 0.0
+    for _, e := range encoders {
+        weight, ok := q[e.encoding]
+        if !ok { weight, ok = q["*"] }
+        if ok && weight > bestQ { best, bestQ = e, weight }
+    }
+    return best, bestQ > 0
+}
+
+// compressWriter decides on the first WriteHeader or Write whether to
+// compress, based on the status and Content-Type. For HEAD it sets the
+// headers a compressed GET would carry and discards the body.
+type compressWriter struct {
+    http.ResponseWriter
+    encoder namedCompressor
+    level   int
+    types   []string
+    head    bool
+    enc     io.WriteCloser
+    decided bool
+    encoded bool // Content-Encoding set by decide
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+    // Informational responses (e.g. 100 Continue) are not the final status.
+    if code < 200 && code != http.StatusSwitchingProtocols {
+        w.ResponseWriter.WriteHeader(code)
+        return
+    }
+    if !w.decided { w.decide(code, nil) }
+    w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+    if !w.decided { w.decide(http.StatusOK, b) }
+    if w.enc != nil { return w.enc.Write(b) }
+    // Identity bytes would let net/http derive a Content-Length that doesn't
+    // match the encoded GET.
+    if w.encoded { return len(b), nil }
+    return w.ResponseWriter.Write(b)
+}
+
+func (w *compressWriter) decide(code int, body []byte) {
+    w.decided = true
+    h := w.Header()
+    if code < 200 || code == http.StatusNoContent || code == http.StatusNotModified || code == http.StatusPartialContent { return }
+    if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" { return }
+    ct := h.Get("Content-Type")
+    if ct == "" && body != nil {
+        ct = http.DetectContentType(body)
+        h.Set("Content-Type", ct)
+    }
+    if !compressible(ct, w.types) { return }
+    h.Set("Content-Encoding", w.encoder.encoding)
+    if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") { h.Set("ETag", "W/"+etag) }
+    h.Del("Content-Length")
+    h.Del("Accept-Ranges")
+    w.encoded = true
+    if !w.head { w.enc = w.encoder.new(w.ResponseWriter, w.level) }
+}
+
+// Flush sends any buffered compressed data to the client.
+func (w *compressWriter) Flush() {
+    if f, ok := w.enc.(interface{ Flush() error }); ok { _ = f.Flush() }
+    if f, ok := w.ResponseWriter.(http.Flusher); ok { f.Flush() }
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *compressWriter) close() {
+    if w.enc != nil { _ = w.enc.Close() }
+}
+
+func compressible(contentType string, types []string) bool {
+    mt, _, err := mime.ParseMediaType(contentType)
+    if err != nil { return false }
+    for _, t := range types {
+        if prefix, ok := strings.CutSuffix(t, "/*"); ok {
+            if strings.HasPrefix(mt, prefix+"/") { return true }
+        } else if mt == t {
+            return true
+        }
+    }
+    return false
+}
+
+// pooledEncoder returns its encoder to a pool once closed.
+type pooledEncoder struct {
+    io.WriteCloser
+    release func()
+}
+
+func (e *pooledEncoder) Flush() error {
+    if f, ok := e.WriteCloser.(interface{ Flush() error }); ok { return f.Flush() }
+    return nil
+}
+
+func (e *pooledEncoder) Close() error {
+    err := e.WriteCloser.Close()
+    e.release()
+    return err
+}
@@ -48,7 +48,7 @@ func CORS(cfgs ...CORSConfig) router.Middleware {
     allowedHeaders := strings.Join(cfg.AllowedHeaders, ", ")
     exposedHeaders := strings.Join(cfg.ExposedHeaders, ", ")
 
-    return func(next http.Handler) http.Handler {
+    return router.Named("CORS", func(next http.Handler) http.Handler {
         return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
             origin := r.Header.Get("Origin")
             if origin == "" {
@@ -100,7 +100,7 @@ func CORS(cfgs ...CORSConfig) router.Middleware {
             }
             next.ServeHTTP(w, r)
         })
-    }
+    })
 }
 
 func isOriginAllowed(origin string, cfg CORSConfig) bool {
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+    "log/slog"
+    "net/http"
+
+    "github.com/shkmv/httplib/router"
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+// ContextLogger stores a logger derived from base (slog.Default() if nil) in
+// the request context, carrying request_id, ip and route attributes, so handlers
+// can log with ctxutil.Logger(r.Context()).Info(...) instead of formatting
+// request IDs themselves. Use it after RequestID and RealIP; attributes they
+// did not set are left out.
+func ContextLogger(base *slog.Logger) router.Middleware {
+    return router.Named("ContextLogger", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            l := base
+            if l == nil { l = slog.Default() }
+            ctx := r.Context()
+            attrs := make([]any, 0, 3)
+            if id := ctxutil.GetReqID(ctx); id != "" { attrs = append(attrs, slog.String("request_id", id)) }
+            attrs = append(attrs, slog.String("ip", clientIP(r)))
+            if p := ctxutil.GetRoutePattern(ctx); p != "" { attrs = append(attrs, slog.String("route", p)) }
+            next.ServeHTTP(w, r.WithContext(ctxutil.WithLogger(ctx, l.With(attrs...))))
+        })
+    })
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/base64"
+    "net/http"
+    "strings"
+
+    "github.com/shkmv/httplib/router"
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+// Cursor verifies the signed, opaque pagination cursor in the "cursor" query
+// parameter and stores its payload in the context (ctxutil.GetCursor).
+// Requests without a cursor pass through as the first page; tampered or
+// malformed cursors get 400. Mint cursors with EncodeCursor and the same secret.
+func Cursor(secret []byte) router.Middleware {
+    return router.Named("Cursor", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            raw := r.URL.Query().Get("cursor")
+            if raw == "" {
+                next.ServeHTTP(w, r)
+                return
+            }
+            payload, ok := decodeCursor(secret, raw)
+            if !ok {
+                router.BadRequest(w, r, "invalid_cursor", "pagination cursor is invalid", nil)
+                return
+            }
+            next.ServeHTTP(w, r.WithContext(ctxutil.WithCursor(r.Context(), payload)))
+        })
+    })
+}
+
+// EncodeCursor returns an opaque, URL-safe cursor carrying payload (such as
+// the last seen ID), signed with secret so clients cannot forge or alter it.
+func EncodeCursor(secret []byte, payload string) string {
+    enc := base64.RawURLEncoding
+    return enc.EncodeToString([]byte(payload)) + "." + enc.EncodeToString(cursorMAC(secret, payload))
+}
+
+func decodeCursor(secret []byte, raw string) (string, bool) {
+    enc := base64.RawURLEncoding
+    data, sig, ok := strings.Cut(raw, ".")
+    if !ok { return "", false }
+    payload, err := enc.DecodeString(data)
+    if err != nil { return "", false }
+    mac, err := enc.DecodeString(sig)
+    if err != nil || !hmac.Equal(mac, cursorMAC(secret, string(payload))) { return "", false }
+    return string(payload), true
+}
+
+func cursorMAC(secret []byte, payload string) []byte {
+    m := hmac.New(sha256.New, secret)
+    m.Write([]byte(payload))
+    return m.Sum(nil)
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+    "context"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/shkmv/httplib/router"
+)
+
+// DeadlineConfig configures Deadline.
+type DeadlineConfig struct {
+    Headers []string      // budget headers, first present wins; default X-Request-Timeout-Ms, Grpc-Timeout
+    Max     time.Duration // budgets are clamped to this; default 30s
+    Default time.Duration // budget for requests without a header; 0 leaves them unbounded
+}
+
+// Deadline applies the caller's remaining time budget as the request context
+// deadline, so work is abandoned once the caller has given up and the budget
+// can be propagated downstream (client.WithDeadlineHeader sends it). Values
+// are milliseconds ("250") or gRPC-style durations ("250m", "2S"; units H, M,
+// S, m, u, n) and are clamped to Max; malformed values are ignored. A budget
+// that is already spent gets 504 "deadline_exceeded" without running the
+// handler. Handlers observe the deadline through r.Context().
+func Deadline(cfg DeadlineConfig) router.Middleware {
+    if cfg.Headers == nil { cfg.Headers = []string{"X-Request-Timeout-Ms", "Grpc-Timeout"} }
+    if cfg.Max <= 0 { cfg.Max = 30 * time.Second }
+    return router.Named("Deadline", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            budget, ok := time.Duration(0), false
+            for _, h := range cfg.Headers {
+                if v := r.Header.Get(h); v != "" {
+                    budget, ok = parseBudget(v)
+                    break
+                }
+            }
+            if !ok { budget = cfg.Default }
+            if !ok && budget <= 0 {
+                next.ServeHTTP(w, r)
+                return
+            }
+            if budget <= 0 {
+                router.RenderError(w, r, http.StatusGatewayTimeout, "deadline_exceeded", "request deadline already passed", nil)
+                return
+            }
+            if budget > cfg.Max { budget = cfg.Max }
+            ctx, cancel := context.WithTimeout(r.Context(), budget)
+            defer cancel()
+            next.ServeHTTP(w, r.WithContext(ctx))
+        })
+    })
+}
+
+// parseBudget parses milliseconds or a gRPC timeout such as "100m".
+func parseBudget(v string) (time.Duration, bool) {
+    v = strings.TrimSpace(v)
+    if v == "" { return 0, false }
+    unit := time.Millisecond
+    if last := v[len(v)-1]; last < '0' || last > '9' {
+        switch last {
+        case 'H':
+            unit = time.Hour
+        case 'M':
+            unit = time.Minute
+        case 'S':
+            unit = time.Second
+        case 'm':
+            unit = time.Millisecond
+        case 'u':
+            unit = time.Microsecond
+        case 'n':
+            unit = time.Nanosecond
+        default:
+            return 0, false
+        }
+        v = v[:len(v)-1]
+    }
+    n, err := strconv.ParseInt(v, 10, 64)
+    if err != nil || n < 0 || n > int64(1<<62)/int64(unit) { return 0, false }
+    return time.Duration(n) * unit, true
+}
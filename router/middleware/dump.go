@@ -0,0 +1,142 @@
+package middleware
+
+import (
+    "bytes"
+    "crypto/subtle"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "sort"
+    "strings"
+
+    "github.com/shkmv/httplib/router"
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+// DumpConfig configures Dump.
+type DumpConfig struct {
+    Enabled       bool        // dump every request
+    HeaderSecret  string      // also dump requests whose Header carries this value; empty disables
+    Header        string      // default "X-Debug-Dump"
+    MaxBody       int         // bytes of each body kept; default 4096
+    RedactHeaders []string    // default Authorization, Cookie, Set-Cookie, Proxy-Authorization
+    Logger        *log.Logger // default log.Default()
+}
+
+// Dump logs the full request and response, headers and bodies, for
+// debugging in staging. It is active for every request when cfg.Enabled is
+// set, otherwise only for requests whose cfg.Header equals cfg.HeaderSecret,
+// so clients cannot switch dumps on unless they know the secret. Bodies are
+// captured as the handler reads and writes them, so streaming is unaffected,
+// and are cut to MaxBody bytes; credentials in redacted headers are masked,
+// but bodies are logged as they are, including any passwords or tokens.
+func Dump(cfg DumpConfig) router.Middleware {
+    if cfg.Header == "" { cfg.Header = "X-Debug-Dump" }
+    if cfg.MaxBody <= 0 { cfg.MaxBody = 4096 }
+    if cfg.RedactHeaders == nil { cfg.RedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"} }
+    if cfg.Logger == nil { cfg.Logger = log.Default() }
+    redact := map[string]bool{http.CanonicalHeaderKey(cfg.Header): true}
+    for _, h := range cfg.RedactHeaders { redact[http.CanonicalHeaderKey(h)] = true }
+    return router.Named("Dump", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if !cfg.Enabled && !dumpRequested(r, cfg) {
+                next.ServeHTTP(w, r)
+                return
+            }
+            reqBody := &capBuffer{max: cfg.MaxBody}
+            if r.Body != nil && r.Body != http.NoBody { r.Body = &teeReadCloser{ReadCloser: r.Body, w: reqBody} }
+            dw := &dumpWriter{ResponseWriter: w, body: capBuffer{max: cfg.MaxBody}}
+            reqHeader := r.Header.Clone()
+            defer func() {
+                var b strings.Builder
+                fmt.Fprintf(&b, "dump: %s %s %s req_id=%s\n", r.Method, r.URL.RequestURI(), r.Proto, ctxutil.GetReqID(r.Context()))
+                if r.Host != "" { fmt.Fprintf(&b, "> Host: %s\n", r.Host) }
+                writeDumpHeaders(&b, "> ", reqHeader, redact)
+                writeDumpBody(&b, "> ", reqBody)
+                status := dw.status
+                if status == 0 { status = http.StatusOK }
+                fmt.Fprintf(&b, "< %d %s\n", status, http.StatusText(status))
+                writeDumpHeaders(&b, "< ", w.Header(), redact)
+                writeDumpBody(&b, "< ", &dw.body)
+                cfg.Logger.Print(b.String())
+            }()
+            next.ServeHTTP(dw, r)
+        })
+    })
+}
+
+func dumpRequested(r *http.Request, cfg DumpConfig) bool {
+    v := r.Header.Get(cfg.Header)
+    return cfg.HeaderSecret != "" && subtle.ConstantTimeCompare([]byte(v), []byte(cfg.HeaderSecret)) == 1
+}
+
+func writeDumpHeaders(b *strings.Builder, prefix string, h http.Header, redact map[string]bool) {
+    names := make([]string, 0, len(h))
+    for k := range h { names = append(names, k) }
+    sort.Strings(names)
+    for _, k := range names {
+        for _, v := range h[k] {
+            if redact[k] { v = "[REDACTED]" }
+            fmt.Fprintf(b, "%s%s: %s\n", prefix, k, v)
+        }
+    }
+}
+
+func writeDumpBody(b *strings.Builder, prefix string, body *capBuffer) {
+    if body.total == 0 { return }
+    b.WriteString(prefix + "\n")
+    for _, line := range strings.Split(strings.TrimRight(body.buf.String(), "\n"), "\n") { b.WriteString(prefix + line + "\n") }
+    if n := body.total - body.buf.Len(); n > 0 { fmt.Fprintf(b, "%s... (%d more bytes)\n", prefix, n) }
+}
+
+// capBuffer keeps the first max bytes written to it and counts the rest.
+type capBuffer struct {
+    buf   bytes.Buffer
+    max   int
+    total int
+}
+
+func (c *capBuffer) Write(p []byte) (int, error) {
+    c.total += len(p)
+    if room := c.max - c.buf.Len(); room > 0 {
+        if len(p) > room { c.buf.Write(p[:room]) } else { c.buf.Write(p) }
+    }
+    return len(p), nil
+}
+
+type teeReadCloser struct {
+    io.ReadCloser
+    w io.Writer
+}
+
+func (t *teeReadCloser) Read(p []byte) (int, error) {
+    n, err := t.ReadCloser.Read(p)
+    if n > 0 { _, _ = t.w.Write(p[:n]) }
+    return n, err
+}
+
+// dumpWriter records the status and the start of the body.
+type dumpWriter struct {
+    http.ResponseWriter
+    status int
+    body   capBuffer
+}
+
+func (w *dumpWriter) WriteHeader(code int) {
+    if w.status == 0 && (code >= 200 || code == http.StatusSwitchingProtocols) { w.status = code }
+    w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *dumpWriter) Write(b []byte) (int, error) {
+    if w.status == 0 { w.status = http.StatusOK }
+    n, err := w.ResponseWriter.Write(b)
+    _, _ = w.body.Write(b[:n])
+    return n, err
+}
+
+func (w *dumpWriter) Flush() {
+    if f, ok := w.ResponseWriter.(http.Flusher); ok { f.Flush() }
+}
+
+func (w *dumpWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "net/http"
+    "strconv"
+
+    "github.com/shkmv/httplib/router"
+)
+
+// ETagConfig configures the ETag middleware.
+type ETagConfig struct {
+    Weak      bool  // send W/"..." validators, e.g. when Compress runs outside ETag
+    MaxBuffer int64 // largest body that is buffered and hashed; default 1MB
+}
+
+// ETag answers conditional GET and HEAD requests. A 200 response whose
+// handler set no validator is buffered and tagged with a hash of its body;
+// a matching If-None-Match then gets 304 Not Modified without the body.
+// Handlers that set their own ETag or Last-Modified are not buffered: their
+// validators are checked against If-None-Match, or If-Modified-Since when no
+// If-None-Match is sent. Bodies over MaxBuffer, responses that are flushed
+// while being written and HEAD responses without a body pass through untagged.
+func ETag(cfgs ...ETagConfig) router.Middleware {
+    cfg := ETagConfig{}
+    if len(cfgs) > 0 { cfg = cfgs[0] }
+    if cfg.MaxBuffer <= 0 { cfg.MaxBuffer = 1 << 20 }
+    return router.Named("ETag", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if r.Method != http.MethodGet && r.Method != http.MethodHead {
+                next.ServeHTTP(w, r)
+                return
+            }
+            ew := &etagWriter{ResponseWriter: w, r: r, max: cfg.MaxBuffer}
+            next.ServeHTTP(ew, r)
+            if ew.mode == etagUndecided { ew.decide(http.StatusOK) }
+            if ew.mode != etagBuffering { return }
+            if r.Method == http.MethodHead && ew.buf.Len() == 0 {
+                // Nothing was written to hash; keep the handler's headers as they are.
+                w.WriteHeader(ew.status)
+                return
+            }
+
+            sum := sha256.Sum256(ew.buf.Bytes())
+            etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+            if cfg.Weak { etag = "W/" + etag }
+            h := w.Header()
+            h.Set("ETag", etag)
+            if notModified(r, h) {
+                h.Del("Content-Length")
+                w.WriteHeader(http.StatusNotModified)
+                return
+            }
+            h.Set("Content-Length", strconv.Itoa(ew.buf.Len()))
+            w.WriteHeader(ew.status)
+            _, _ = w.Write(ew.buf.Bytes())
+        })
+    })
+}
+
+// notModified reports whether the validators in h satisfy the request's
+// conditional headers; If-None-Match takes precedence over If-Modified-Since.
+func notModified(r *http.Request, h http.Header) bool {
+    if inm := r.Header.Get("If-None-Match"); inm != "" {
+        etag := h.Get("ETag")
+        return etag != "" && router.ETagMatches(inm, etag)
+    }
+    ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+    if err != nil { return false }
+    lm, err := http.ParseTime(h.Get("Last-Modified"))
+    return err == nil && !lm.After(ims)
+}
+
+const (
+    etagUndecided = iota
+    etagBuffering  // holding the body back to hash it
+    etagPassing    // writing straight through
+    etagDiscarding // 304 sent; the body is dropped
+)
+
+// etagWriter chooses how to handle the response once its status is known.
+type etagWriter struct {
+    http.ResponseWriter
+    r      *http.Request
+    max    int64
+    mode   int
+    status int
+    buf    bytes.Buffer
+}
+
+func (w *etagWriter) decide(code int) {
+    w.status = code
+    h := w.Header()
+    switch {
+    case code != http.StatusOK:
+        w.mode = etagPassing
+    case h.Get("ETag") != "" || h.Get("Last-Modified") != "":
+        if notModified(w.r, h) {
+            h.Del("Content-Length")
+            w.ResponseWriter.WriteHeader(http.StatusNotModified)
+            w.mode = etagDiscarding
+            return
+        }
+        w.mode = etagPassing
+    default:
+        w.mode = etagBuffering
+        return
+    }
+    w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *etagWriter) WriteHeader(code int) {
+    // Informational responses (e.g. 100 Continue) are not the final status.
+    if code < 200 && code != http.StatusSwitchingProtocols {
+        w.ResponseWriter.WriteHeader(code)
+        return
+    }
+    if w.mode == etagUndecided { w.decide(code) }
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+    if w.mode == etagUndecided { w.decide(http.StatusOK) }
+    switch w.mode {
+    case etagDiscarding:
+        return len(b), nil
+    case etagBuffering:
+        if int64(w.buf.Len()+len(b)) <= w.max { return w.buf.Write(b) }
+        w.passThrough()
+    }
+    return w.ResponseWriter.Write(b)
+}
+
+// passThrough gives up on tagging and sends what was buffered so far.
+func (w *etagWriter) passThrough() {
+    w.mode = etagPassing
+    w.ResponseWriter.WriteHeader(w.status)
+    _, _ = w.ResponseWriter.Write(w.buf.Bytes())
+    w.buf = bytes.Buffer{}
+}
+
+func (w *etagWriter) Flush() {
+    if w.mode == etagUndecided { w.decide(http.StatusOK) }
+    if w.mode == etagBuffering { w.passThrough() }
+    if f, ok := w.ResponseWriter.(http.Flusher); ok { f.Flush() }
+}
+
+func (w *etagWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+    "net/http"
+    "strings"
+
+    "github.com/shkmv/httplib/router"
+)
+
+// ExpectContinue runs check before anything reads the request body. If check
+// rejects the request, an error envelope with the returned status (417
+// Expectation Failed if status is 0) is written without touching the body.
+// For clients that sent "Expect: 100-continue" this means the server never
+// sends "100 Continue", so a large upload is never transmitted; the
+// connection is closed after the response.
+func ExpectContinue(check func(*http.Request) (status int, ok bool)) router.Middleware {
+    return router.Named("ExpectContinue", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            status, ok := check(r)
+            if ok {
+                next.ServeHTTP(w, r)
+                return
+            }
+            if status == 0 { status = http.StatusExpectationFailed }
+            if strings.EqualFold(r.Header.Get("Expect"), "100-continue") {
+                w.Header().Set("Connection", "close")
+            }
+            code := strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+            router.RenderError(w, r, status, code, "request rejected before reading body", nil)
+        })
+    })
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+    "net/http"
+    "strconv"
+
+    "github.com/shkmv/httplib/router"
+)
+
+// Favicon answers GET and HEAD /favicon.ico with icon, cached for a day,
+// without calling the rest of the chain. Register it before heavier
+// middlewares such as Logger so those requests skip them too. Like every
+// router middleware it only sees requests that match a route, such as a
+// catch-all "/", unless the router has a NotFound handler or was created
+// with router.WithUnmatchedMiddlewares.
+func Favicon(icon []byte) router.Middleware {
+    ctype := http.DetectContentType(icon)
+    if ctype == "application/octet-stream" { ctype = "image/x-icon" }
+    return router.Named("Favicon", staticFile("/favicon.ico", ctype, icon))
+}
+
+// Robots answers GET and HEAD /robots.txt with body, cached for a day,
+// without calling the rest of the chain.
+func Robots(body string) router.Middleware {
+    return router.Named("Robots", staticFile("/robots.txt", "text/plain; charset=utf-8", []byte(body)))
+}
+
+func staticFile(path, ctype string, body []byte) router.Middleware {
+    size := strconv.Itoa(len(body))
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if r.URL.Path != path || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+                next.ServeHTTP(w, r)
+                return
+            }
+            h := w.Header()
+            h.Set("Content-Type", ctype)
+            h.Set("Content-Length", size)
+            h.Set("Cache-Control", "public, max-age=86400")
+            w.WriteHeader(http.StatusOK)
+            if r.Method == http.MethodGet { _, _ = w.Write(body) }
+        })
+    }
+}
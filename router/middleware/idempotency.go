@@ -0,0 +1,299 @@
+package middleware
+
+import (
+    "bytes"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "io"
+    "net/http"
+    "reflect"
+    "sync"
+    "time"
+
+    "github.com/shkmv/httplib/router"
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+// IdempotencyConfig configures Idempotency.
+type IdempotencyConfig struct {
+    MaxBody int64                      // largest request body accepted with a key; default 1 MiB
+    Scope   func(*http.Request) string // default: the caller (claims "sub" or a hash of Authorization) and route
+    LockTTL time.Duration              // IdempotencyLocker claim; default 1m, set above the slowest handler
+}
+
+// IdempotentResponse is a response captured for an Idempotency-Key.
+type IdempotentResponse struct {
+    Status      int
+    Header      http.Header
+    Body        []byte
+    Fingerprint string // hash of the method, path, and body of the original request
+}
+
+// IdempotencyStore persists captured responses by idempotency key.
+type IdempotencyStore interface {
+    Get(key string) (*IdempotentResponse, bool)
+    Set(key string, resp *IdempotentResponse, ttl time.Duration)
+}
+
+// IdempotencyLocker is implemented by stores shared between instances that
+// can mark a key as in progress, e.g. with Redis SET key token NX PX, so a
+// duplicate arriving at another instance is not handled twice.
+type IdempotencyLocker interface {
+    // Lock claims key for ttl and reports whether it was unclaimed, returning
+    // a token that identifies this claim.
+    Lock(key string, ttl time.Duration) (token string, ok bool)
+    // Unlock releases key only if it is still claimed with token, so an
+    // instance whose claim expired cannot release another's (in Redis, a
+    // compare-and-delete script).
+    Unlock(key, token string)
+}
+
+// Idempotency replays responses for unsafe requests (POST, PUT, PATCH, DELETE)
+// carrying an Idempotency-Key header. The first response for a key is stored
+// for ttl and returned verbatim, with an Idempotent-Replayed header, for
+// repeats of the same request. Reusing a key with a different method, path, or
+// body returns 409. 5xx responses are not stored so clients can retry them.
+// A nil store uses an in-memory store.
+//
+// Keys are scoped by Scope, so two callers that pick the same key (and send
+// the same body) never see each other's responses. Bodies over MaxBody get
+// 413 "body_too_large".
+//
+// Identical requests that arrive while the first is still being handled are
+// collapsed within this process: they wait for it and receive its response
+// (also marked Idempotent-Replayed) instead of running the handler again.
+// When the store also implements IdempotencyLocker, duplicates arriving at
+// other instances meanwhile get 409 idempotency_key_in_progress with
+// Retry-After, and can retry to receive the stored response.
+func Idempotency(store IdempotencyStore, ttl time.Duration, cfgs ...IdempotencyConfig) router.Middleware {
+    cfg := IdempotencyConfig{}
+    if len(cfgs) > 0 { cfg = cfgs[0] }
+    if cfg.MaxBody <= 0 { cfg.MaxBody = 1 << 20 }
+    if cfg.Scope == nil { cfg.Scope = idempotencyScope }
+    if cfg.LockTTL <= 0 { cfg.LockTTL = time.Minute }
+    if store == nil { store = NewMemoryIdempotencyStore() }
+    locker, _ := store.(IdempotencyLocker)
+    var mu sync.Mutex
+    inflight := map[string]*idempotentCall{}
+    return router.Named("Idempotency", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            key := r.Header.Get("Idempotency-Key")
+            if key == "" || !isUnsafeMethod(r.Method) {
+                next.ServeHTTP(w, r)
+                return
+            }
+            key = scopedKey(cfg.Scope(r), key)
+            body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, cfg.MaxBody))
+            var tooLarge *http.MaxBytesError
+            if errors.As(err, &tooLarge) {
+                router.RenderError(w, r, http.StatusRequestEntityTooLarge, "body_too_large", "request body is too large", nil)
+                return
+            }
+            if err != nil {
+                router.BadRequest(w, r, "invalid_body", "could not read request body", nil)
+                return
+            }
+            r.Body = io.NopCloser(bytes.NewReader(body))
+            fp := requestFingerprint(r, body)
+
+            replayCached := func(cached *IdempotentResponse) {
+                if cached.Fingerprint != fp {
+                    router.Conflict(w, r, "idempotency_key_reused", "idempotency key was already used for a different request")
+                    return
+                }
+                replayResponse(w, cached)
+            }
+            if cached, ok := store.Get(key); ok {
+                replayCached(cached)
+                return
+            }
+
+            mu.Lock()
+            if call, ok := inflight[key]; ok {
+                mu.Unlock()
+                if call.fingerprint != fp {
+                    router.Conflict(w, r, "idempotency_key_reused", "idempotency key was already used for a different request")
+                    return
+                }
+                select {
+                case <-call.done:
+                case <-r.Context().Done():
+                    return
+                }
+                if call.resp == nil {
+                    router.Conflict(w, r, "idempotency_request_failed", "the original request for this idempotency key did not complete")
+                    return
+                }
+                replayResponse(w, call.resp)
+                return
+            }
+            // A leader may have stored its response and left inflight
+            // since the lookup above.
+            if cached, ok := store.Get(key); ok {
+                mu.Unlock()
+                replayCached(cached)
+                return
+            }
+            call := &idempotentCall{fingerprint: fp, done: make(chan struct{})}
+            inflight[key] = call
+            mu.Unlock()
+            defer func() {
+                mu.Lock()
+                delete(inflight, key)
+                mu.Unlock()
+                close(call.done)
+            }()
+            if locker != nil {
+                token, ok := locker.Lock(key, cfg.LockTTL)
+                if ok { defer locker.Unlock(key, token) }
+                // Another instance may have finished since the lookup above.
+                if cached, found := store.Get(key); found {
+                    if cached.Fingerprint == fp { call.resp = cached }
+                    replayCached(cached)
+                    return
+                }
+                if !ok {
+                    w.Header().Set("Retry-After", "1")
+                    router.Conflict(w, r, "idempotency_key_in_progress", "a request with this idempotency key is still being processed")
+                    return
+                }
+            }
+
+            before := w.Header().Clone()
+            bw := &bufferedResponseWriter{ResponseWriter: w}
+            next.ServeHTTP(bw, r)
+            resp := &IdempotentResponse{
+                Status:      bw.code(),
+                Header:      headerDiff(before, w.Header()),
+                Body:        append([]byte(nil), bw.buf.Bytes()...),
+                Fingerprint: fp,
+            }
+            if resp.Status < 500 { store.Set(key, resp, ttl) }
+            call.resp = resp
+            bw.flush()
+        })
+    })
+}
+
+// idempotentCall is a keyed request whose handler is still running.
+type idempotentCall struct {
+    fingerprint string
+    done        chan struct{}
+    resp        *IdempotentResponse // set before done is closed; nil if the handler panicked
+}
+
+func isUnsafeMethod(m string) bool {
+    switch m {
+    case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+        return true
+    }
+    return false
+}
+
+// idempotencyScope identifies the caller, by verified claims subject or else
+// by Authorization header, and the matched route.
+func idempotencyScope(r *http.Request) string {
+    principal := ""
+    if sub, ok := ctxutil.GetClaims(r.Context())["sub"].(string); ok && sub != "" {
+        principal = "sub:" + sub
+    } else if auth := r.Header.Get("Authorization"); auth != "" {
+        sum := sha256.Sum256([]byte(auth))
+        principal = "auth:" + hex.EncodeToString(sum[:])
+    }
+    route := ctxutil.GetRoutePattern(r.Context())
+    if route == "" { route = r.URL.Path }
+    return principal + "\n" + route
+}
+
+// scopedKey is the store key for a client's key within scope.
+func scopedKey(scope, key string) string {
+    sum := sha256.Sum256([]byte(scope + "\n" + key))
+    return hex.EncodeToString(sum[:])
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+    h := sha256.New()
+    io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+    h.Write(body)
+    return hex.EncodeToString(h.Sum(nil))
+}
+
+// headerDiff returns the headers in after that were added or changed relative to before.
+func headerDiff(before, after http.Header) http.Header {
+    out := http.Header{}
+    for k, v := range after {
+        if !reflect.DeepEqual(before[k], v) { out[k] = append([]string(nil), v...) }
+    }
+    return out
+}
+
+func replayResponse(w http.ResponseWriter, resp *IdempotentResponse) {
+    for k, v := range resp.Header { w.Header()[k] = append([]string(nil), v...) }
+    w.Header().Set("Idempotent-Replayed", "true")
+    w.WriteHeader(resp.Status)
+    _, _ = w.Write(resp.Body)
+}
+
+// MemoryIdempotencyStore is an in-process IdempotencyStore with per-entry expiry.
+type MemoryIdempotencyStore struct {
+    mu      sync.Mutex
+    entries map[string]memoryIdempotencyEntry
+    locks   map[string]idempotencyLock
+}
+
+type idempotencyLock struct {
+    token   string
+    expires time.Time
+}
+
+type memoryIdempotencyEntry struct {
+    resp    *IdempotentResponse
+    expires time.Time
+}
+
+// NewMemoryIdempotencyStore creates an empty in-memory store.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+    return &MemoryIdempotencyStore{entries: map[string]memoryIdempotencyEntry{}, locks: map[string]idempotencyLock{}}
+}
+
+// Get returns the unexpired response stored for key.
+func (s *MemoryIdempotencyStore) Get(key string) (*IdempotentResponse, bool) {
+    s.mu.Lock(); defer s.mu.Unlock()
+    e, ok := s.entries[key]
+    if !ok { return nil, false }
+    if time.Now().After(e.expires) {
+        delete(s.entries, key)
+        return nil, false
+    }
+    return e.resp, true
+}
+
+// Set stores resp under key for ttl, sweeping expired entries as it goes.
+func (s *MemoryIdempotencyStore) Set(key string, resp *IdempotentResponse, ttl time.Duration) {
+    s.mu.Lock(); defer s.mu.Unlock()
+    now := time.Now()
+    for k, e := range s.entries {
+        if now.After(e.expires) { delete(s.entries, k) }
+    }
+    s.entries[key] = memoryIdempotencyEntry{resp: resp, expires: now.Add(ttl)}
+}
+
+// Lock implements IdempotencyLocker.
+func (s *MemoryIdempotencyStore) Lock(key string, ttl time.Duration) (string, bool) {
+    s.mu.Lock(); defer s.mu.Unlock()
+    now := time.Now()
+    if l, ok := s.locks[key]; ok && now.Before(l.expires) { return "", false }
+    b := make([]byte, 16)
+    rand.Read(b)
+    token := hex.EncodeToString(b)
+    s.locks[key] = idempotencyLock{token: token, expires: now.Add(ttl)}
+    return token, true
+}
+
+// Unlock implements IdempotencyLocker.
+func (s *MemoryIdempotencyStore) Unlock(key, token string) {
+    s.mu.Lock(); defer s.mu.Unlock()
+    if s.locks[key].token == token { delete(s.locks, key) }
+}
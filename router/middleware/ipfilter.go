@@ -0,0 +1,104 @@
+package middleware
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+    "net/netip"
+    "strings"
+
+    "github.com/shkmv/httplib/router"
+)
+
+// IPFilterConfig configures IPFilter. Entries are CIDR prefixes such as
+// "10.0.0.0/8" or single addresses.
+type IPFilterConfig struct {
+    Allow          []string     // when set, only these addresses are let through
+    Deny           []string     // always blocked, even when also allowed
+    TrustedProxies []string     // peers whose X-Forwarded-For / X-Real-IP are believed
+    Logger         *log.Logger  // audit log of blocked requests; nil disables
+    OnBlocked      http.Handler // default: 403 "ip_forbidden" error envelope
+}
+
+// IPFilter blocks requests by client address. It does not trust the address
+// RealIP resolved, since any client can send X-Forwarded-For: the peer
+// address is used unless it is one of TrustedProxies, in which case the
+// right-most X-Forwarded-For entry that is not a trusted proxy (or
+// X-Real-IP) is. Addresses that cannot be parsed are blocked. IPv4-mapped
+// IPv6 addresses match IPv4 prefixes. It panics on an invalid entry.
+func IPFilter(cfg IPFilterConfig) router.Middleware {
+    allow, deny := parsePrefixes(cfg.Allow), parsePrefixes(cfg.Deny)
+    trusted := parsePrefixes(cfg.TrustedProxies)
+    if cfg.OnBlocked == nil {
+        cfg.OnBlocked = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            router.Forbidden(w, r, "ip_forbidden", "access from this address is not allowed")
+        })
+    }
+    return router.Named("IPFilter", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            ip := forwardedClient(peerAddr(r), r.Header, trusted)
+            addr, err := netip.ParseAddr(ip)
+            addr = addr.Unmap()
+            reason := ""
+            switch {
+            case err != nil:
+                reason = "unparseable address"
+            case containsAddr(deny, addr):
+                reason = "denied"
+            case len(allow) > 0 && !containsAddr(allow, addr):
+                reason = "not allowed"
+            }
+            if reason == "" {
+                next.ServeHTTP(w, r)
+                return
+            }
+            if cfg.Logger != nil { cfg.Logger.Printf("ip filter: blocked %s %s from %q (%s)", r.Method, r.URL.Path, ip, reason) }
+            cfg.OnBlocked.ServeHTTP(w, r)
+        })
+    })
+}
+
+// forwardedClient returns the client address of a request from peer: peer
+// itself unless it is trusted, else the right-most forwarded address that is
+// not a trusted proxy.
+func forwardedClient(peer string, h http.Header, trusted []netip.Prefix) string {
+    isTrusted := func(ip string) bool {
+        a, err := netip.ParseAddr(ip)
+        return err == nil && containsAddr(trusted, a.Unmap())
+    }
+    if !isTrusted(peer) { return peer }
+    if xff := h.Values("X-Forwarded-For"); len(xff) > 0 {
+        hops := strings.Split(strings.Join(xff, ","), ",")
+        for i := len(hops) - 1; i >= 0; i-- {
+            hop := strings.TrimSpace(hops[i])
+            if hop != "" && (i == 0 || !isTrusted(hop)) { return hop }
+        }
+    }
+    if rip := strings.TrimSpace(h.Get("X-Real-IP")); rip != "" { return rip }
+    return peer
+}
+
+func parsePrefixes(entries []string) []netip.Prefix {
+    out := make([]netip.Prefix, 0, len(entries))
+    for _, e := range entries {
+        e = strings.TrimSpace(e)
+        if strings.Contains(e, "/") {
+            p, err := netip.ParsePrefix(e)
+            if err != nil { panic(fmt.Sprintf("middleware: invalid IPFilter prefix %q", e)) }
+            out = append(out, p.Masked())
+            continue
+        }
+        a, err := netip.ParseAddr(e)
+        if err != nil { panic(fmt.Sprintf("middleware: invalid IPFilter address %q", e)) }
+        a = a.Unmap()
+        out = append(out, netip.PrefixFrom(a, a.BitLen()))
+    }
+    return out
+}
+
+func containsAddr(prefixes []netip.Prefix, a netip.Addr) bool {
+    for _, p := range prefixes {
+        if p.Contains(a) { return true }
+    }
+    return false
+}
@@ -0,0 +1,204 @@
+package middleware
+
+import (
+    "context"
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rsa"
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "math/big"
+    "net/url"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/shkmv/httplib/client"
+)
+
+// JWKS refetch limits.
+const (
+    DefaultJWKSRefresh   = time.Hour
+    jwksMinRefetchPeriod = 30 * time.Second
+)
+
+// JWKS.Keyfunc errors.
+var (
+    ErrUnknownKey     = errors.New("middleware: no JWKS key for kid")
+    ErrKeyAlgMismatch = errors.New("middleware: token alg does not match JWKS key")
+)
+
+// JWKS fetches and caches the signing keys published by an identity
+// provider as a JSON Web Key Set, refreshing them in the background. Its
+// Keyfunc plugs into JWTConfig:
+//  jwks, err := middleware.NewJWKS(ctx, "https://idp.example.com/.well-known/jwks.json", time.Hour)
+//  r.Use(middleware.JWT(middleware.JWTConfig{Keyfunc: jwks.Keyfunc}))
+// A token whose kid is not cached triggers an immediate refetch (at most
+// every 30s), so rotated keys are picked up without waiting for the refresh.
+// Only RSA (RS256) and P-256 EC (ES256) public keys are used: symmetric
+// "oct" keys in a published set are public and are ignored. A token whose
+// alg differs from its key's is rejected.
+type JWKS struct {
+    c    *client.Client
+    path string
+
+    mu      sync.RWMutex
+    keys    map[string]jwksKey
+    fetched time.Time
+
+    fetchMu sync.Mutex
+    done    chan struct{}
+    wg      sync.WaitGroup
+    once    sync.Once
+}
+
+// NewJWKS fetches the key set at jwksURL and refreshes it every refresh
+// (DefaultJWKSRefresh if zero) until Close. opts configure the underlying
+// client, e.g. client.WithHTTPClient or client.WithRetryPolicy.
+func NewJWKS(ctx context.Context, jwksURL string, refresh time.Duration, opts ...client.Option) (*JWKS, error) {
+    u, err := url.Parse(jwksURL)
+    if err != nil || u.Scheme == "" || u.Host == "" { return nil, fmt.Errorf("middleware: invalid JWKS URL %q", jwksURL) }
+    if refresh <= 0 { refresh = DefaultJWKSRefresh }
+    base := u.Scheme + "://" + u.Host
+    u.Scheme, u.Host = "", ""
+    j := &JWKS{c: client.New([]client.Endpoint{{BaseURL: base}}, opts...), path: u.String(), done: make(chan struct{})}
+    if err := j.Refresh(ctx); err != nil { return nil, err }
+    j.wg.Add(1)
+    go j.refreshLoop(refresh)
+    return j, nil
+}
+
+// DiscoverJWKS returns the jwks_uri advertised by an OpenID Connect issuer's
+// /.well-known/openid-configuration document.
+func DiscoverJWKS(ctx context.Context, issuer string, opts ...client.Option) (string, error) {
+    c := client.New([]client.Endpoint{{BaseURL: strings.TrimRight(issuer, "/")}}, opts...)
+    defer c.Close()
+    var doc struct {
+        JWKSURI string `json:"jwks_uri"`
+    }
+    if _, err := c.GetJSON(ctx, "/.well-known/openid-configuration", &doc); err != nil { return "", fmt.Errorf("middleware: OIDC discovery: %w", err) }
+    if doc.JWKSURI == "" { return "", errors.New("middleware: OIDC discovery: no jwks_uri") }
+    return doc.JWKSURI, nil
+}
+
+// Keyfunc implements JWTKeyfunc, looking keys up by kid and checking that
+// alg is the one the key is meant for.
+func (j *JWKS) Keyfunc(alg, kid string) (any, error) {
+    if k, ok := j.key(kid); ok { return k.forAlg(alg) }
+    j.fetchMu.Lock()
+    j.mu.RLock()
+    stale := time.Since(j.fetched) >= jwksMinRefetchPeriod
+    j.mu.RUnlock()
+    if stale {
+        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        _ = j.refresh(ctx)
+        cancel()
+    }
+    j.fetchMu.Unlock()
+    if k, ok := j.key(kid); ok { return k.forAlg(alg) }
+    return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+}
+
+// Refresh refetches the key set now.
+func (j *JWKS) Refresh(ctx context.Context) error {
+    j.fetchMu.Lock(); defer j.fetchMu.Unlock()
+    return j.refresh(ctx)
+}
+
+// Close stops the background refresh.
+func (j *JWKS) Close() error {
+    j.once.Do(func() { close(j.done) })
+    j.wg.Wait()
+    return j.c.Close()
+}
+
+func (j *JWKS) key(kid string) (jwksKey, bool) {
+    j.mu.RLock(); defer j.mu.RUnlock()
+    k, ok := j.keys[kid]
+    return k, ok
+}
+
+// refresh fetches and swaps in the key set; fetchMu must be held. The time
+// of the attempt is recorded even on failure to bound refetches.
+func (j *JWKS) refresh(ctx context.Context) error {
+    var set struct {
+        Keys []jwk `json:"keys"`
+    }
+    _, err := j.c.GetJSON(ctx, j.path, &set)
+    j.mu.Lock(); defer j.mu.Unlock()
+    j.fetched = time.Now()
+    if err != nil { return fmt.Errorf("middleware: fetching JWKS: %w", err) }
+    keys := make(map[string]jwksKey, len(set.Keys))
+    for _, k := range set.Keys {
+        if k.Use != "" && k.Use != "sig" { continue }
+        if key, err := k.parse(); err == nil { keys[k.Kid] = key }
+    }
+    j.keys = keys
+    return nil
+}
+
+func (j *JWKS) refreshLoop(every time.Duration) {
+    defer j.wg.Done()
+    t := time.NewTicker(every)
+    defer t.Stop()
+    for {
+        select {
+        case <-j.done:
+            return
+        case <-t.C:
+            ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+            _ = j.Refresh(ctx)
+            cancel()
+        }
+    }
+}
+
+// jwk is one JSON Web Key (RFC 7517); only signature keys usable by JWT are
+// decoded.
+type jwk struct {
+    Kty string `json:"kty"`
+    Kid string `json:"kid"`
+    Use string `json:"use"`
+    Alg string `json:"alg"`
+    N   string `json:"n"`
+    E   string `json:"e"`
+    Crv string `json:"crv"`
+    X   string `json:"x"`
+    Y   string `json:"y"`
+}
+
+// jwksKey is a decoded public key and the one alg it verifies.
+type jwksKey struct {
+    pub any
+    alg string
+}
+
+func (k jwksKey) forAlg(alg string) (any, error) {
+    if alg != k.alg { return nil, fmt.Errorf("%w: %s key used with %q", ErrKeyAlgMismatch, k.alg, alg) }
+    return k.pub, nil
+}
+
+func (k jwk) parse() (jwksKey, error) {
+    b64 := base64.RawURLEncoding
+    var key jwksKey
+    switch k.Kty {
+    case "RSA":
+        n, err1 := b64.DecodeString(k.N)
+        e, err2 := b64.DecodeString(k.E)
+        if err1 != nil || err2 != nil || len(e) > 4 { return key, errors.New("invalid RSA key") }
+        key = jwksKey{&rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, RS256}
+    case "EC":
+        if k.Crv != "P-256" { return key, fmt.Errorf("unsupported curve %q", k.Crv) }
+        x, err1 := b64.DecodeString(k.X)
+        y, err2 := b64.DecodeString(k.Y)
+        if err1 != nil || err2 != nil { return key, errors.New("invalid EC key") }
+        pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+        if !pub.Curve.IsOnCurve(pub.X, pub.Y) { return key, errors.New("invalid EC key") }
+        key = jwksKey{pub, ES256}
+    default:
+        return key, fmt.Errorf("unsupported key type %q", k.Kty)
+    }
+    if k.Alg != "" && k.Alg != key.alg { return key, fmt.Errorf("key alg %q does not fit key type %q", k.Alg, k.Kty) }
+    return key, nil
+}
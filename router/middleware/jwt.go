@@ -0,0 +1,161 @@
+package middleware
+
+import (
+    "crypto"
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/hmac"
+    "crypto/rsa"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log/slog"
+    "math/big"
+    "net/http"
+    "slices"
+    "strings"
+    "time"
+
+    "github.com/shkmv/httplib/router"
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+// JWT signing algorithms supported by the JWT middleware.
+const (
+    HS256 = "HS256"
+    RS256 = "RS256"
+    ES256 = "ES256"
+)
+
+// JWTKeyfunc returns the verification key for a token's alg and kid header:
+// a []byte secret for HS256, *rsa.PublicKey for RS256, or *ecdsa.PublicKey
+// (P-256) for ES256. Looking keys up by kid allows rotation.
+type JWTKeyfunc func(alg, kid string) (any, error)
+
+// JWTConfig configures JWT.
+type JWTConfig struct {
+    Keyfunc    JWTKeyfunc
+    Algorithms []string      // accepted algs; default HS256, RS256, ES256
+    Cookie     string        // cookie to read the token from when there is no Authorization header
+    Issuer     string        // required "iss", if set
+    Audience   string        // required in "aud", if set
+    Leeway     time.Duration // allowed clock skew for exp and nbf
+}
+
+// JWT authenticates requests with a bearer token from the Authorization
+// header (or cfg.Cookie), verifying its signature with cfg.Keyfunc and its
+// exp, nbf, iss and aud claims. The claims are stored in the context (see
+// ctxutil.GetClaims). Failures get 401 with a WWW-Authenticate header, error
+// "token_missing", "token_invalid" or "token_expired" and a fixed message;
+// the underlying reason, which may come from Keyfunc, is only logged at debug
+// level with ctxutil.Logger.
+func JWT(cfg JWTConfig) router.Middleware {
+    if cfg.Keyfunc == nil { panic("middleware: JWT needs a Keyfunc") }
+    if len(cfg.Algorithms) == 0 { cfg.Algorithms = []string{HS256, RS256, ES256} }
+    return router.Named("JWT", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            token := bearerToken(r, cfg.Cookie)
+            if token == "" {
+                w.Header().Set("WWW-Authenticate", `Bearer`)
+                router.Unauthorized(w, r, "token_missing", "a bearer token is required")
+                return
+            }
+            claims, err := verifyJWT(token, cfg, time.Now())
+            if err != nil {
+                code, msg := "token_invalid", "the bearer token is invalid"
+                if errors.Is(err, errTokenExpired) { code, msg = "token_expired", "the bearer token has expired" }
+                ctxutil.Logger(r.Context()).DebugContext(r.Context(), "jwt rejected", slog.String("error", err.Error()))
+                w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+                router.Unauthorized(w, r, code, msg)
+                return
+            }
+            next.ServeHTTP(w, r.WithContext(ctxutil.WithClaims(r.Context(), claims)))
+        })
+    })
+}
+
+var errTokenExpired = errors.New("token has expired")
+
+func bearerToken(r *http.Request, cookie string) string {
+    if h := r.Header.Get("Authorization"); h != "" {
+        scheme, tok, ok := strings.Cut(h, " ")
+        if ok && strings.EqualFold(scheme, "Bearer") { return strings.TrimSpace(tok) }
+        return ""
+    }
+    if cookie != "" {
+        if c, err := r.Cookie(cookie); err == nil { return c.Value }
+    }
+    return ""
+}
+
+func verifyJWT(token string, cfg JWTConfig, now time.Time) (map[string]any, error) {
+    parts := strings.Split(token, ".")
+    if len(parts) != 3 { return nil, errors.New("malformed token") }
+    var header struct {
+        Alg string `json:"alg"`
+        Kid string `json:"kid"`
+    }
+    if err := decodeSegment(parts[0], &header); err != nil { return nil, errors.New("malformed token header") }
+    if !slices.Contains(cfg.Algorithms, header.Alg) { return nil, fmt.Errorf("unsupported algorithm %q", header.Alg) }
+    sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+    if err != nil { return nil, errors.New("malformed token signature") }
+    key, err := cfg.Keyfunc(header.Alg, header.Kid)
+    if err != nil { return nil, fmt.Errorf("no key for token: %w", err) }
+    if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil { return nil, err }
+
+    var claims map[string]any
+    if err := decodeSegment(parts[1], &claims); err != nil { return nil, errors.New("malformed token claims") }
+    if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(cfg.Leeway)) { return nil, errTokenExpired }
+    if nbf, ok := claims["nbf"].(float64); ok && now.Add(cfg.Leeway).Before(time.Unix(int64(nbf), 0)) { return nil, errors.New("token is not valid yet") }
+    if cfg.Issuer != "" && claims["iss"] != cfg.Issuer { return nil, errors.New("token issuer is not accepted") }
+    if cfg.Audience != "" && !hasAudience(claims["aud"], cfg.Audience) { return nil, errors.New("token audience is not accepted") }
+    return claims, nil
+}
+
+func decodeSegment(seg string, v any) error {
+    b, err := base64.RawURLEncoding.DecodeString(seg)
+    if err != nil { return err }
+    return json.Unmarshal(b, v)
+}
+
+// verifySignature checks sig over input, requiring the key type to match alg
+// so an RSA public key can never be used as an HMAC secret.
+func verifySignature(alg string, key any, input string, sig []byte) error {
+    digest := sha256.Sum256([]byte(input))
+    invalid := errors.New("token signature is invalid")
+    switch alg {
+    case HS256:
+        secret, ok := key.([]byte)
+        if !ok { return fmt.Errorf("key for %s must be []byte", alg) }
+        mac := hmac.New(sha256.New, secret)
+        mac.Write([]byte(input))
+        if !hmac.Equal(sig, mac.Sum(nil)) { return invalid }
+    case RS256:
+        pub, ok := key.(*rsa.PublicKey)
+        if !ok { return fmt.Errorf("key for %s must be *rsa.PublicKey", alg) }
+        if rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) != nil { return invalid }
+    case ES256:
+        pub, ok := key.(*ecdsa.PublicKey)
+        if !ok || pub.Curve != elliptic.P256() { return fmt.Errorf("key for %s must be a P-256 *ecdsa.PublicKey", alg) }
+        if len(sig) != 64 { return invalid }
+        rr, ss := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+        if !ecdsa.Verify(pub, digest[:], rr, ss) { return invalid }
+    default:
+        return fmt.Errorf("unsupported algorithm %q", alg)
+    }
+    return nil
+}
+
+func hasAudience(aud any, want string) bool {
+    switch v := aud.(type) {
+    case string:
+        return v == want
+    case []any:
+        for _, a := range v {
+            if a == want { return true }
+        }
+    }
+    return false
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+    "fmt"
     "log"
     "net"
     "net/http"
@@ -10,31 +11,71 @@ import (
     "github.com/shkmv/httplib/router/ctxutil"
 )
 
+// LogEntry describes a completed request for LoggerWithFormatter.
+type LogEntry struct {
+    Method       string
+    Path         string
+    Status       int
+    Bytes        int
+    Duration     time.Duration
+    IP           string
+    RequestID    string
+    RoutePattern string
+    Tenant       string
+}
+
 // Logger logs method, path, status, bytes, duration, IP, and request ID.
 func Logger(l *log.Logger) router.Middleware {
+    return router.Named("Logger", LoggerWithFormatter(l, defaultLogFormat))
+}
+
+// LoggerWithFormatter logs one line per request, produced by format from the
+// request's LogEntry. Use it for logfmt or other custom text formats.
+func LoggerWithFormatter(l *log.Logger, format func(LogEntry) string) router.Middleware {
     if l == nil { l = log.Default() }
-    return func(next http.Handler) http.Handler {
+    return router.Named("LoggerWithFormatter", func(next http.Handler) http.Handler {
         return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
             start := time.Now()
             srw := &statusResponseWriter{ResponseWriter: w}
             next.ServeHTTP(srw, r)
-            dur := time.Since(start)
-            ip := ctxutil.GetRealIP(r.Context())
-            if ip == "" { ip, _, _ = net.SplitHostPort(r.RemoteAddr) }
-            rid := ctxutil.GetReqID(r.Context())
-            if srw.status == 0 { srw.status = http.StatusOK }
-            l.Printf("%s %s %d %dB %s ip=%s req_id=%s", r.Method, r.URL.Path, srw.status, srw.bytes, dur.Truncate(time.Microsecond), ip, rid)
+            l.Print(format(newLogEntry(r, srw, time.Since(start))))
         })
+    })
+}
+
+func newLogEntry(r *http.Request, srw *statusResponseWriter, dur time.Duration) LogEntry {
+    ip := ctxutil.GetRealIP(r.Context())
+    if ip == "" { ip, _, _ = net.SplitHostPort(r.RemoteAddr) }
+    status := srw.status
+    if status == 0 { status = http.StatusOK }
+    return LogEntry{
+        Method:       r.Method,
+        Path:         r.URL.Path,
+        Status:       status,
+        Bytes:        srw.bytes,
+        Duration:     dur,
+        IP:           ip,
+        RequestID:    ctxutil.GetReqID(r.Context()),
+        RoutePattern: ctxutil.GetRoutePattern(r.Context()),
+        Tenant:       ctxutil.GetTenant(r.Context()),
     }
 }
 
+func defaultLogFormat(e LogEntry) string {
+    return fmt.Sprintf("%s %s %d %dB %s ip=%s req_id=%s", e.Method, e.Path, e.Status, e.Bytes, e.Duration.Truncate(time.Microsecond), e.IP, e.RequestID)
+}
+
 type statusResponseWriter struct {
     http.ResponseWriter
     status int
     bytes  int
 }
 
-func (w *statusResponseWriter) WriteHeader(code int) { w.status = code; w.ResponseWriter.WriteHeader(code) }
+func (w *statusResponseWriter) WriteHeader(code int) {
+    // Informational responses (e.g. 100 Continue) are not the final status.
+    if code >= 200 || code == http.StatusSwitchingProtocols { w.status = code }
+    w.ResponseWriter.WriteHeader(code)
+}
 func (w *statusResponseWriter) Write(b []byte) (int, error) {
     if w.status == 0 { w.status = http.StatusOK }
     n, err := w.ResponseWriter.Write(b)
@@ -0,0 +1,249 @@
+package middleware
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "net/http"
+    "slices"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/shkmv/httplib/router"
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+// Default histogram buckets for Metrics.
+var (
+    DefaultDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+    DefaultSizeBuckets     = []float64{100, 1000, 10000, 100000, 1e6, 1e7}
+)
+
+// MetricsCollector writes metric families in the Prometheus text exposition
+// format. Register one with a MetricsRegistry to expose application metrics
+// next to the request metrics.
+type MetricsCollector interface {
+    WriteMetrics(w io.Writer) error
+}
+
+// MetricsRegistry collects metrics and serves them in the Prometheus text
+// format; mount it as the scrape endpoint:
+//  r.Get("/metrics", middleware.DefaultMetricsRegistry)
+type MetricsRegistry struct {
+    mu         sync.Mutex
+    collectors []MetricsCollector
+    http       map[string]*httpMetrics // Metrics collectors by namespace
+}
+
+// DefaultMetricsRegistry is the registry used when MetricsConfig.Registry is nil.
+var DefaultMetricsRegistry = NewMetricsRegistry()
+
+// NewMetricsRegistry creates an empty registry.
+func NewMetricsRegistry() *MetricsRegistry { return &MetricsRegistry{} }
+
+// Register adds c to the registry.
+func (g *MetricsRegistry) Register(c MetricsCollector) {
+    g.mu.Lock(); defer g.mu.Unlock()
+    g.collectors = append(g.collectors, c)
+}
+
+// WriteMetrics writes every registered collector in registration order.
+func (g *MetricsRegistry) WriteMetrics(w io.Writer) error {
+    g.mu.Lock()
+    cs := append([]MetricsCollector(nil), g.collectors...)
+    g.mu.Unlock()
+    for _, c := range cs {
+        if err := c.WriteMetrics(w); err != nil { return err }
+    }
+    return nil
+}
+
+// ServeHTTP serves the registry in the Prometheus text format.
+func (g *MetricsRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+    bw := bufio.NewWriter(w)
+    _ = g.WriteMetrics(bw)
+    _ = bw.Flush()
+}
+
+// MetricsConfig configures the Metrics middleware.
+type MetricsConfig struct {
+    Namespace       string           // metric name prefix; default "http"
+    DurationBuckets []float64        // seconds; default DefaultDurationBuckets
+    SizeBuckets     []float64        // bytes; default DefaultSizeBuckets
+    Registry        *MetricsRegistry // default DefaultMetricsRegistry
+}
+
+// Metrics records, per method, route pattern and status:
+//  <ns>_requests_total                counter
+//  <ns>_request_duration_seconds      histogram
+//  <ns>_response_size_bytes           histogram
+// and <ns>_requests_in_flight, a gauge per method and route. The route label
+// is the pattern the request matched (see ctxutil.GetRoutePattern), or
+// "unmatched" for requests that matched no route, so paths with IDs do not
+// explode label cardinality; likewise non-standard methods are recorded as
+// "OTHER". Use it router-wide, before the middlewares whose latency it should
+// include. Metrics middlewares with the same namespace and registry share
+// their series; registering one with different buckets panics.
+func Metrics(cfg MetricsConfig) router.Middleware {
+    if cfg.Namespace == "" { cfg.Namespace = "http" }
+    if cfg.DurationBuckets == nil { cfg.DurationBuckets = DefaultDurationBuckets }
+    if cfg.SizeBuckets == nil { cfg.SizeBuckets = DefaultSizeBuckets }
+    if cfg.Registry == nil { cfg.Registry = DefaultMetricsRegistry }
+    m := cfg.Registry.httpMetrics(cfg)
+    return router.Named("Metrics", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            start := time.Now()
+            route := ctxutil.GetRoutePattern(r.Context())
+            if route == "" { route = "unmatched" }
+            key := metricLabels{method: metricMethod(r.Method), route: route}
+            m.addInFlight(key, 1)
+            srw := &statusResponseWriter{ResponseWriter: w}
+            defer func() {
+                m.addInFlight(key, -1)
+                status := srw.status
+                if status == 0 { status = http.StatusOK }
+                key.status = strconv.Itoa(status)
+                m.observe(key, time.Since(start).Seconds(), float64(srw.bytes))
+            }()
+            next.ServeHTTP(srw, r)
+        })
+    })
+}
+
+// httpMetrics returns the collector for cfg.Namespace, registering it on
+// first use.
+func (g *MetricsRegistry) httpMetrics(cfg MetricsConfig) *httpMetrics {
+    g.mu.Lock(); defer g.mu.Unlock()
+    if m := g.http[cfg.Namespace]; m != nil {
+        if !slices.Equal(m.cfg.DurationBuckets, cfg.DurationBuckets) || !slices.Equal(m.cfg.SizeBuckets, cfg.SizeBuckets) {
+            panic(fmt.Sprintf("middleware: Metrics namespace %q is already registered with different buckets", cfg.Namespace))
+        }
+        return m
+    }
+    m := &httpMetrics{cfg: cfg, series: map[metricLabels]*metricSeries{}, inflight: map[metricLabels]int64{}}
+    if g.http == nil { g.http = map[string]*httpMetrics{} }
+    g.http[cfg.Namespace] = m
+    g.collectors = append(g.collectors, m)
+    return m
+}
+
+type metricLabels struct {
+    method, route, status string
+}
+
+type metricSeries struct {
+    count                uint64
+    durations, sizes     []uint64 // cumulative bucket counts
+    durationSum, sizeSum float64
+}
+
+type httpMetrics struct {
+    cfg      MetricsConfig
+    mu       sync.Mutex
+    series   map[metricLabels]*metricSeries
+    inflight map[metricLabels]int64
+}
+
+func (m *httpMetrics) addInFlight(k metricLabels, d int64) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    m.inflight[k] += d
+}
+
+func (m *httpMetrics) observe(k metricLabels, seconds, size float64) {
+    m.mu.Lock(); defer m.mu.Unlock()
+    s := m.series[k]
+    if s == nil {
+        s = &metricSeries{durations: make([]uint64, len(m.cfg.DurationBuckets)), sizes: make([]uint64, len(m.cfg.SizeBuckets))}
+        m.series[k] = s
+    }
+    s.count++
+    s.durationSum += seconds
+    s.sizeSum += size
+    for i, b := range m.cfg.DurationBuckets {
+        if seconds <= b { s.durations[i]++ }
+    }
+    for i, b := range m.cfg.SizeBuckets {
+        if size <= b { s.sizes[i]++ }
+    }
+}
+
+// WriteMetrics implements MetricsCollector.
+func (m *httpMetrics) WriteMetrics(w io.Writer) error {
+    m.mu.Lock()
+    keys := make([]metricLabels, 0, len(m.series))
+    series := make(map[metricLabels]metricSeries, len(m.series))
+    for k, s := range m.series {
+        keys = append(keys, k)
+        series[k] = metricSeries{count: s.count, durationSum: s.durationSum, sizeSum: s.sizeSum,
+            durations: append([]uint64(nil), s.durations...), sizes: append([]uint64(nil), s.sizes...)}
+    }
+    inflight := make(map[metricLabels]int64, len(m.inflight))
+    flightKeys := make([]metricLabels, 0, len(m.inflight))
+    for k, n := range m.inflight {
+        inflight[k] = n
+        flightKeys = append(flightKeys, k)
+    }
+    m.mu.Unlock()
+    sortMetricLabels(keys)
+    sortMetricLabels(flightKeys)
+
+    ns := m.cfg.Namespace
+    var b strings.Builder
+    fmt.Fprintf(&b, "# HELP %s_requests_total Total HTTP requests.\n# TYPE %[1]s_requests_total counter\n", ns)
+    for _, k := range keys { fmt.Fprintf(&b, "%s_requests_total{%s} %d\n", ns, k, series[k].count) }
+    writeHistogram(&b, ns+"_request_duration_seconds", "HTTP request latency in seconds.", keys, m.cfg.DurationBuckets,
+        func(k metricLabels) ([]uint64, float64, uint64) { s := series[k]; return s.durations, s.durationSum, s.count })
+    writeHistogram(&b, ns+"_response_size_bytes", "HTTP response body size in bytes.", keys, m.cfg.SizeBuckets,
+        func(k metricLabels) ([]uint64, float64, uint64) { s := series[k]; return s.sizes, s.sizeSum, s.count })
+    fmt.Fprintf(&b, "# HELP %s_requests_in_flight HTTP requests being served.\n# TYPE %[1]s_requests_in_flight gauge\n", ns)
+    for _, k := range flightKeys { fmt.Fprintf(&b, "%s_requests_in_flight{%s} %d\n", ns, k, inflight[k]) }
+    _, err := io.WriteString(w, b.String())
+    return err
+}
+
+func writeHistogram(b *strings.Builder, name, help string, keys []metricLabels, buckets []float64, get func(metricLabels) ([]uint64, float64, uint64)) {
+    fmt.Fprintf(b, "# HELP %s %s\n# TYPE %[1]s histogram\n", name, help)
+    for _, k := range keys {
+        counts, sum, count := get(k)
+        for i, le := range buckets {
+            fmt.Fprintf(b, "%s_bucket{%s,le=\"%s\"} %d\n", name, k, strconv.FormatFloat(le, 'g', -1, 64), counts[i])
+        }
+        fmt.Fprintf(b, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, k, count)
+        fmt.Fprintf(b, "%s_sum{%s} %s\n", name, k, strconv.FormatFloat(sum, 'g', -1, 64))
+        fmt.Fprintf(b, "%s_count{%s} %d\n", name, k, count)
+    }
+}
+
+// String renders the labels for the exposition format; the status label is
+// omitted when empty, as for the in-flight gauge.
+func (k metricLabels) String() string {
+    s := `method="` + escapeLabel(k.method) + `",route="` + escapeLabel(k.route) + `"`
+    if k.status != "" { s += `,status="` + k.status + `"` }
+    return s
+}
+
+func sortMetricLabels(ks []metricLabels) {
+    sort.Slice(ks, func(i, j int) bool {
+        if ks[i].route != ks[j].route { return ks[i].route < ks[j].route }
+        if ks[i].method != ks[j].method { return ks[i].method < ks[j].method }
+        return ks[i].status < ks[j].status
+    })
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string { return labelEscaper.Replace(v) }
+
+// metricMethod bounds the method label to the standard methods.
+func metricMethod(m string) string {
+    switch m {
+    case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+        http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+        return m
+    }
+    return "OTHER"
+}
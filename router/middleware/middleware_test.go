@@ -1,18 +1,44 @@
 package middleware_test
 
 import (
+    "bufio"
     "bytes"
+    "compress/gzip"
+    "compress/zlib"
+    "context"
+    "crypto"
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/sha256"
+    "crypto/tls"
+    "crypto/x509"
+    "crypto/x509/pkix"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
     "io"
     "log"
+    "log/slog"
+    "math/big"
+    "net"
     "net/http"
     "net/http/httptest"
+    "strconv"
     "strings"
+    "sync"
+    "sync/atomic"
     "testing"
     "time"
 
+    "github.com/shkmv/httplib/client"
     "github.com/shkmv/httplib/router"
     "github.com/shkmv/httplib/router/ctxutil"
     mw "github.com/shkmv/httplib/router/middleware"
+    "github.com/shkmv/httplib/session"
 )
 
 func TestRequestID(t *testing.T) {
@@ -61,6 +87,31 @@ func TestRecoverer(t *testing.T) {
     }
 }
 
+func TestRecovererWithConfig(t *testing.T) {
+    var got any
+    var stack []byte
+    r := router.New()
+    r.Use(mw.RequestID(), mw.RecovererWithConfig(mw.RecovererConfig{
+        Logger:  log.New(io.Discard, "", 0),
+        OnPanic: func(ctx context.Context, rec any, st []byte) { got, stack = rec, st },
+    }))
+    r.GetFunc("/panic", func(http.ResponseWriter, *http.Request) { panic("boom") })
+    r.GetFunc("/abort", func(http.ResponseWriter, *http.Request) { panic(http.ErrAbortHandler) })
+
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/panic", nil))
+    var env router.ErrorEnvelope
+    if err := json.Unmarshal(rr.Body.Bytes(), &env); err != nil || rr.Code != http.StatusInternalServerError || env.Error != "internal_error" || env.RequestID == "" {
+        t.Fatalf("expected 500 JSON envelope with request ID, got %d %q", rr.Code, rr.Body.String())
+    }
+    if got != "boom" || !bytes.Contains(stack, []byte("TestRecovererWithConfig")) { t.Fatalf("OnPanic got %v with stack %q", got, stack) }
+
+    defer func() {
+        if rec := recover(); rec != http.ErrAbortHandler { t.Fatalf("expected ErrAbortHandler re-panicked, got %v", rec) }
+    }()
+    r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abort", nil))
+}
+
 func TestTimeout(t *testing.T) {
     r := router.New()
     r.Use(mw.Timeout(10*time.Millisecond, "request timeout"))
@@ -79,6 +130,29 @@ func TestTimeout(t *testing.T) {
     }
 }
 
+func TestTimeoutRouteOverride(t *testing.T) {
+    r := router.New()
+    r.Use(mw.Timeout(10*time.Millisecond, "request timeout"))
+    slow := func(w http.ResponseWriter, req *http.Request) {
+        time.Sleep(40 * time.Millisecond)
+        io.WriteString(w, "done")
+    }
+    r.Route("/reports", func(rep *router.Router) { rep.GetFunc("/monthly", slow) }, router.WithTimeout(time.Second))
+    r.Route("/exports", func(ex *router.Router) { ex.GetFunc("/all", slow) }, router.WithTimeout(20*time.Millisecond))
+    r.GetFunc("/users", slow)
+
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/reports/monthly", nil))
+    if rr.Code != http.StatusOK || rr.Body.String() != "done" {
+        t.Fatalf("expected longer group timeout to apply, got %d %q", rr.Code, rr.Body.String())
+    }
+    for _, p := range []string{"/exports/all", "/users"} {
+        rr := httptest.NewRecorder()
+        r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, p, nil))
+        if rr.Code != http.StatusServiceUnavailable { t.Fatalf("%s: expected 503, got %d", p, rr.Code) }
+    }
+}
+
 func TestNoCache(t *testing.T) {
     r := router.New()
     r.Use(mw.NoCache())
@@ -144,3 +218,1731 @@ func TestCORSActual(t *testing.T) {
     }
 }
 
+
+func TestPrivateETag(t *testing.T) {
+    r := router.New()
+    r.Use(mw.PrivateETag(func(req *http.Request) string { return req.Header.Get("X-User") }))
+    r.GetFunc("/me", func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, "profile") })
+
+    get := func(user, inm string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodGet, "/me", nil)
+        req.Header.Set("X-User", user)
+        if inm != "" { req.Header.Set("If-None-Match", inm) }
+        rr := httptest.NewRecorder()
+        r.ServeHTTP(rr, req)
+        return rr
+    }
+
+    alice, bob := get("alice", ""), get("bob", "")
+    if alice.Code != 200 || alice.Body.String() != "profile" {
+        t.Fatalf("unexpected response: %d %q", alice.Code, alice.Body.String())
+    }
+    if cc := alice.Header().Get("Cache-Control"); cc != "private" {
+        t.Fatalf("expected Cache-Control private, got %q", cc)
+    }
+    ea, eb := alice.Header().Get("ETag"), bob.Header().Get("ETag")
+    if ea == "" || eb == "" || ea == eb {
+        t.Fatalf("expected distinct per-user etags, got %q and %q", ea, eb)
+    }
+    if rr := get("alice", ea); rr.Code != http.StatusNotModified {
+        t.Fatalf("expected 304 for matching etag, got %d", rr.Code)
+    }
+    if rr := get("bob", ea); rr.Code != http.StatusOK {
+        t.Fatalf("expected 200 for another user's etag, got %d", rr.Code)
+    }
+    if rr := get("alice", `"a, `+ea+`, b"`); rr.Code != http.StatusOK {
+        t.Fatalf("expected tags to be compared whole, got %d", rr.Code)
+    }
+}
+
+func TestRequireClientCert(t *testing.T) {
+    cert, leaf := selfSignedClientCert(t, "admin")
+    r := router.New()
+    r.Use(mw.RequireClientCert(func(c *x509.Certificate) bool { return c.Subject.CommonName == "admin" }))
+    r.GetFunc("/admin", func(w http.ResponseWriter, req *http.Request) {
+        io.WriteString(w, ctxutil.GetClientCN(req.Context()))
+    })
+
+    srv := httptest.NewUnstartedServer(r)
+    pool := x509.NewCertPool()
+    pool.AddCert(leaf)
+    srv.TLS = &tls.Config{ClientAuth: tls.VerifyClientCertIfGiven, ClientCAs: pool}
+    srv.StartTLS()
+    defer srv.Close()
+
+    resp, err := srv.Client().Get(srv.URL + "/admin")
+    if err != nil { t.Fatalf("get: %v", err) }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusForbidden {
+        t.Fatalf("expected 403 without client cert, got %d", resp.StatusCode)
+    }
+
+    tr := srv.Client().Transport.(*http.Transport).Clone()
+    tr.TLSClientConfig.Certificates = []tls.Certificate{cert}
+    hc := &http.Client{Transport: tr}
+    resp, err = hc.Get(srv.URL + "/admin")
+    if err != nil { t.Fatalf("get with cert: %v", err) }
+    body, _ := io.ReadAll(resp.Body)
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusOK || string(body) != "admin" {
+        t.Fatalf("expected 200 admin, got %d %q", resp.StatusCode, body)
+    }
+}
+
+func TestRequireClientCertRejectsUnverified(t *testing.T) {
+    cert, _ := selfSignedClientCert(t, "admin")
+    r := router.New()
+    r.Use(mw.RequireClientCert(nil))
+    r.GetFunc("/admin", func(w http.ResponseWriter, req *http.Request) {})
+
+    srv := httptest.NewUnstartedServer(r)
+    srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+    srv.StartTLS()
+    defer srv.Close()
+
+    tr := srv.Client().Transport.(*http.Transport).Clone()
+    tr.TLSClientConfig.Certificates = []tls.Certificate{cert}
+    resp, err := (&http.Client{Transport: tr}).Get(srv.URL + "/admin")
+    if err != nil { t.Fatalf("get with cert: %v", err) }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusForbidden { t.Fatalf("expected 403 for an unverified certificate, got %d", resp.StatusCode) }
+}
+
+func selfSignedClientCert(t *testing.T, cn string) (tls.Certificate, *x509.Certificate) {
+    t.Helper()
+    key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    if err != nil { t.Fatalf("key: %v", err) }
+    tmpl := &x509.Certificate{
+        SerialNumber:          big.NewInt(1),
+        Subject:               pkix.Name{CommonName: cn},
+        NotBefore:             time.Now().Add(-time.Hour),
+        NotAfter:              time.Now().Add(time.Hour),
+        KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+        ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+        BasicConstraintsValid: true,
+        IsCA:                  true,
+    }
+    der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+    if err != nil { t.Fatalf("cert: %v", err) }
+    leaf, _ := x509.ParseCertificate(der)
+    return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, leaf
+}
+
+func TestSlowLog(t *testing.T) {
+    var buf bytes.Buffer
+    r := router.New()
+    r.Use(mw.SlowLog(20*time.Millisecond, log.New(&buf, "", 0)))
+    r.GetFunc("/fast", func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, "ok") })
+    r.GetFunc("/slow", func(w http.ResponseWriter, req *http.Request) {
+        time.Sleep(30 * time.Millisecond)
+        w.WriteHeader(http.StatusAccepted)
+    })
+
+    r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fast", nil))
+    if buf.Len() != 0 {
+        t.Fatalf("expected no log for fast request, got %q", buf.String())
+    }
+    r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+    out := buf.String()
+    if !strings.Contains(out, "GET /slow 202") || !strings.Contains(out, "ms") {
+        t.Fatalf("unexpected slow log line: %q", out)
+    }
+}
+
+func TestAllowQueryParams(t *testing.T) {
+    r := router.New()
+    r.Use(mw.AllowQueryParams("page", "q"))
+    r.GetFunc("/search", func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, req.URL.RawQuery) })
+
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/search?utm_source=x&q=go&fbclid=1&page=2&q=http", nil))
+    if got := rr.Body.String(); got != "q=go&page=2&q=http" {
+        t.Fatalf("unexpected query after filtering: %q", got)
+    }
+}
+
+func TestIdempotencyReplayAndConflict(t *testing.T) {
+    calls := 0
+    r := router.New()
+    r.Use(mw.Idempotency(nil, time.Minute))
+    r.PostFunc("/orders", func(w http.ResponseWriter, req *http.Request) {
+        calls++
+        b, _ := io.ReadAll(req.Body)
+        w.Header().Set("X-Order", "42")
+        w.WriteHeader(http.StatusCreated)
+        io.WriteString(w, "created "+string(b))
+    })
+
+    post := func(body string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+        req.Header.Set("Idempotency-Key", "k1")
+        rr := httptest.NewRecorder()
+        r.ServeHTTP(rr, req)
+        return rr
+    }
+
+    first, replay := post("book"), post("book")
+    if first.Code != http.StatusCreated || first.Body.String() != "created book" {
+        t.Fatalf("unexpected first response: %d %q", first.Code, first.Body.String())
+    }
+    if replay.Code != http.StatusCreated || replay.Body.String() != "created book" || replay.Header().Get("X-Order") != "42" {
+        t.Fatalf("replay differs from original: %d %q %v", replay.Code, replay.Body.String(), replay.Header())
+    }
+    if replay.Header().Get("Idempotent-Replayed") != "true" || calls != 1 {
+        t.Fatalf("expected replay without re-running handler, calls=%d", calls)
+    }
+
+    if rr := post("pen"); rr.Code != http.StatusConflict {
+        t.Fatalf("expected 409 for reused key with different body, got %d", rr.Code)
+    }
+
+    // Another caller using the same key and body gets its own response.
+    req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader("book"))
+    req.Header.Set("Idempotency-Key", "k1")
+    req.Header.Set("Authorization", "Bearer other-user")
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, req)
+    if rr.Code != http.StatusCreated || rr.Header().Get("Idempotent-Replayed") != "" || calls != 2 {
+        t.Fatalf("expected another caller's request to run, got %d replayed=%q calls=%d", rr.Code, rr.Header().Get("Idempotent-Replayed"), calls)
+    }
+
+    small := router.New()
+    small.Use(mw.Idempotency(nil, time.Minute, mw.IdempotencyConfig{MaxBody: 2}))
+    small.PostFunc("/orders", func(w http.ResponseWriter, req *http.Request) { t.Error("oversized body reached the handler") })
+    req = httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader("book"))
+    req.Header.Set("Idempotency-Key", "k1")
+    rr = httptest.NewRecorder()
+    small.ServeHTTP(rr, req)
+    if rr.Code != http.StatusRequestEntityTooLarge { t.Fatalf("expected 413 for oversized body, got %d", rr.Code) }
+}
+
+func TestExpectContinueRejectsBeforeBody(t *testing.T) {
+    bodyRead := false
+    r := router.New()
+    r.Use(mw.ExpectContinue(func(req *http.Request) (int, bool) {
+        return http.StatusUnauthorized, req.Header.Get("Authorization") != ""
+    }))
+    r.PostFunc("/upload", func(w http.ResponseWriter, req *http.Request) {
+        bodyRead = true
+        io.Copy(io.Discard, req.Body)
+    })
+    srv := httptest.NewServer(r)
+    defer srv.Close()
+
+    conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+    if err != nil { t.Fatalf("dial: %v", err) }
+    defer conn.Close()
+    conn.SetDeadline(time.Now().Add(2 * time.Second))
+    io.WriteString(conn, "POST /upload HTTP/1.1\r\nHost: test\r\nContent-Length: 100000000\r\nExpect: 100-continue\r\n\r\n")
+
+    status, err := bufio.NewReader(conn).ReadString('\n')
+    if err != nil { t.Fatalf("read: %v", err) }
+    if !strings.HasPrefix(status, "HTTP/1.1 401") {
+        t.Fatalf("expected immediate 401 without 100 Continue, got %q", status)
+    }
+    if bodyRead {
+        t.Fatalf("handler should not have run")
+    }
+}
+
+func TestExpectContinueDefaultStatus(t *testing.T) {
+    r := router.New()
+    r.Use(mw.ExpectContinue(func(*http.Request) (int, bool) { return 0, false }))
+    r.PostFunc("/upload", func(w http.ResponseWriter, req *http.Request) {})
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("x")))
+    if rr.Code != http.StatusExpectationFailed || !strings.Contains(rr.Body.String(), `"expectation_failed"`) {
+        t.Fatalf("expected 417 for a zero status, got %d %q", rr.Code, rr.Body.String())
+    }
+}
+
+func TestSequenceGuard(t *testing.T) {
+    store := mw.NewMemorySequenceStore()
+    r := router.New()
+    r.Use(mw.SequenceGuard(store, func(req *http.Request) string { return req.URL.Path }))
+    r.PutFunc("/docs/1", func(w http.ResponseWriter, req *http.Request) { w.WriteHeader(http.StatusNoContent) })
+
+    put := func(seq string) int {
+        req := httptest.NewRequest(http.MethodPut, "/docs/1", nil)
+        req.Header.Set("X-Seq", seq)
+        rr := httptest.NewRecorder()
+        r.ServeHTTP(rr, req)
+        return rr.Code
+    }
+
+    if code := put("5"); code != http.StatusNoContent {
+        t.Fatalf("expected in-order write to pass, got %d", code)
+    }
+    if code := put("4"); code != http.StatusConflict {
+        t.Fatalf("expected 409 for out-of-order write, got %d", code)
+    }
+    if code := put("6"); code != http.StatusNoContent {
+        t.Fatalf("expected newer write to pass, got %d", code)
+    }
+    if last, ok := store.Advance("/docs/1", 6); ok || last != 6 {
+        t.Fatalf("expected stored sequence 6, got %d", last)
+    }
+}
+
+func TestAuthorizeByRoutePattern(t *testing.T) {
+    var seen []string
+    r := router.New()
+    r.Use(mw.Authorize(func(_ context.Context, pattern, method string) bool {
+        seen = append(seen, method+" "+pattern)
+        return !(pattern == "/api/users" && method == http.MethodDelete)
+    }))
+    r.Route("/api", func(api *router.Router) {
+        api.GetFunc("/reports", func(w http.ResponseWriter, req *http.Request) { w.WriteHeader(200) })
+        api.DeleteFunc("/users", func(w http.ResponseWriter, req *http.Request) { w.WriteHeader(204) })
+    })
+
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/users", nil))
+    if rr.Code != http.StatusForbidden {
+        t.Fatalf("expected 403 for denied route, got %d", rr.Code)
+    }
+    rr = httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/reports", nil))
+    if rr.Code != http.StatusOK {
+        t.Fatalf("expected 200 for allowed route, got %d", rr.Code)
+    }
+    if len(seen) != 2 || seen[1] != "GET /api/reports" {
+        t.Fatalf("policy did not receive route pattern: %v", seen)
+    }
+}
+
+func TestTimeoutExcept(t *testing.T) {
+    r := router.New()
+    r.Use(mw.TimeoutExcept(10*time.Millisecond, func(req *http.Request) bool {
+        return strings.HasPrefix(req.URL.Path, "/upload")
+    }))
+    slow := func(w http.ResponseWriter, req *http.Request) {
+        time.Sleep(40 * time.Millisecond)
+        io.WriteString(w, "done")
+    }
+    r.PostFunc("/upload", slow)
+    r.GetFunc("/report", slow)
+
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/upload", nil))
+    if rr.Code != http.StatusOK || rr.Body.String() != "done" {
+        t.Fatalf("expected upload to bypass timeout, got %d %q", rr.Code, rr.Body.String())
+    }
+    rr = httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/report", nil))
+    if rr.Code != http.StatusServiceUnavailable {
+        t.Fatalf("expected 503 for slow normal route, got %d", rr.Code)
+    }
+}
+
+func TestLoggerWithFormatter(t *testing.T) {
+    var buf bytes.Buffer
+    r := router.New()
+    r.Use(mw.LoggerWithFormatter(log.New(&buf, "", 0), func(e mw.LogEntry) string {
+        return fmt.Sprintf("method=%s route=%s status=%d bytes=%d ip=%s", e.Method, e.RoutePattern, e.Status, e.Bytes, e.IP)
+    }))
+    r.Route("/api", func(api *router.Router) {
+        api.GetFunc("/users", func(w http.ResponseWriter, req *http.Request) {
+            w.WriteHeader(http.StatusAccepted)
+            io.WriteString(w, "ok")
+        })
+    })
+
+    req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+    req.RemoteAddr = "10.0.0.1:1234"
+    r.ServeHTTP(httptest.NewRecorder(), req)
+    if got, want := buf.String(), "method=GET route=/api/users status=202 bytes=2 ip=10.0.0.1\n"; got != want {
+        t.Fatalf("unexpected log line:\n got %q\nwant %q", got, want)
+    }
+}
+
+func TestSharedCache(t *testing.T) {
+    r := router.New()
+    r.With(mw.SharedCache(mw.SharedCachePolicy{
+        MaxAge:               time.Minute,
+        SMaxAge:              time.Hour,
+        StaleWhileRevalidate: 30 * time.Second,
+        StaleIfError:         24 * time.Hour,
+        SurrogateKeys:        func(*http.Request) []string { return []string{"products", "product-42"} },
+    })).GetFunc("/products/42", func(w http.ResponseWriter, _ *http.Request) { io.WriteString(w, "ok") })
+
+    rec := httptest.NewRecorder()
+    r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products/42", nil))
+    if rec.Code != http.StatusOK { t.Fatalf("expected 200, got %d", rec.Code) }
+    if got, want := rec.Header().Get("Cache-Control"), "public, max-age=60, s-maxage=3600, stale-while-revalidate=30, stale-if-error=86400"; got != want {
+        t.Fatalf("unexpected Cache-Control:\n got %q\nwant %q", got, want)
+    }
+    if got := rec.Header().Get("Surrogate-Key"); got != "products product-42" { t.Fatalf("unexpected Surrogate-Key %q", got) }
+    if got := rec.Header().Get("Cache-Tag"); got != "products,product-42" { t.Fatalf("unexpected Cache-Tag %q", got) }
+}
+
+func TestLimitRequestComplexity(t *testing.T) {
+    r := router.New()
+    r.Use(mw.LimitRequestComplexity(3, 10))
+    r.GetFunc("/search", func(w http.ResponseWriter, _ *http.Request) { io.WriteString(w, "ok") })
+
+    rec := httptest.NewRecorder()
+    r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search?a=1&a=2&b=3&c=4", nil))
+    if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "too_many_params") {
+        t.Fatalf("expected 400 too_many_params, got %d %s", rec.Code, rec.Body.String())
+    }
+
+    rec = httptest.NewRecorder()
+    r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search?a=1&b=2", nil))
+    if rec.Code != http.StatusOK { t.Fatalf("expected 200 for normal request, got %d", rec.Code) }
+}
+
+func TestCSPNonce(t *testing.T) {
+    r := router.New()
+    r.Use(mw.CSPNonce())
+    r.GetFunc("/", func(w http.ResponseWriter, req *http.Request) {
+        io.WriteString(w, ctxutil.GetCSPNonce(req.Context()))
+    })
+
+    rec := httptest.NewRecorder()
+    r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+    nonce := rec.Body.String()
+    if nonce == "" { t.Fatal("expected nonce in context") }
+    if csp := rec.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "script-src 'nonce-"+nonce+"'") {
+        t.Fatalf("CSP %q does not carry nonce %q", csp, nonce)
+    }
+
+    rec2 := httptest.NewRecorder()
+    r.ServeHTTP(rec2, httptest.NewRequest(http.MethodGet, "/", nil))
+    if rec2.Body.String() == nonce { t.Fatal("nonce reused across requests") }
+}
+
+func TestTenant(t *testing.T) {
+    known := map[string]bool{"acme": true}
+    var logBuf bytes.Buffer
+    r := router.New()
+    r.Use(
+        mw.Tenant(func(req *http.Request) (string, bool) {
+            id := req.Header.Get("X-Tenant-ID")
+            return id, known[id]
+        }),
+        mw.LoggerWithFormatter(log.New(&logBuf, "", 0), func(e mw.LogEntry) string { return "tenant=" + e.Tenant }),
+    )
+    r.GetFunc("/", func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, ctxutil.GetTenant(req.Context())) })
+
+    do := func(tenant string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodGet, "/", nil)
+        if tenant != "" { req.Header.Set("X-Tenant-ID", tenant) }
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, req)
+        return rec
+    }
+
+    if rec := do("acme"); rec.Code != http.StatusOK || rec.Body.String() != "acme" {
+        t.Fatalf("expected tenant in context, got %d %q", rec.Code, rec.Body.String())
+    }
+    if !strings.HasPrefix(logBuf.String(), "tenant=acme\n") { t.Fatalf("expected tenant in log entry, got %q", logBuf.String()) }
+    if rec := do(""); rec.Code != http.StatusBadRequest { t.Fatalf("expected 400 for missing tenant, got %d", rec.Code) }
+    if rec := do("globex"); rec.Code != http.StatusForbidden { t.Fatalf("expected 403 for unknown tenant, got %d", rec.Code) }
+}
+
+func TestFaviconAndRobots(t *testing.T) {
+    icon := []byte("\x00\x00\x01\x00\x01\x00icon-bytes")
+    downstream := false
+    r := router.New(router.WithUnmatchedMiddlewares())
+    r.Use(mw.Favicon(icon), mw.Robots("User-agent: *\nDisallow: /admin\n"))
+    r.GetFunc("/other", func(w http.ResponseWriter, _ *http.Request) { downstream = true })
+
+    rec := httptest.NewRecorder()
+    r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/favicon.ico", nil))
+    if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), icon) { t.Fatalf("unexpected favicon response %d %q", rec.Code, rec.Body.Bytes()) }
+    if ct := rec.Header().Get("Content-Type"); ct != "image/x-icon" { t.Fatalf("unexpected content type %q", ct) }
+    if rec.Header().Get("Cache-Control") == "" { t.Fatal("expected caching headers") }
+
+    rec = httptest.NewRecorder()
+    r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/robots.txt", nil))
+    if rec.Body.String() != "User-agent: *\nDisallow: /admin\n" || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+        t.Fatalf("unexpected robots response %q %q", rec.Header().Get("Content-Type"), rec.Body.String())
+    }
+    if downstream { t.Fatal("downstream handler should not run for favicon or robots") }
+
+    r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/other", nil))
+    if !downstream { t.Fatal("other paths should reach the handler") }
+
+    plain := router.New()
+    plain.Use(mw.Favicon(icon))
+    plain.GetFunc("/other", func(w http.ResponseWriter, _ *http.Request) {})
+    rec = httptest.NewRecorder()
+    plain.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/favicon.ico", nil))
+    if rec.Code != http.StatusNotFound { t.Fatalf("expected unmatched requests to skip middlewares by default, got %d", rec.Code) }
+}
+
+func TestCursor(t *testing.T) {
+    secret := []byte("cursor-secret")
+    r := router.New()
+    r.Use(mw.Cursor(secret))
+    r.GetFunc("/items", func(w http.ResponseWriter, req *http.Request) {
+        io.WriteString(w, "after="+ctxutil.GetCursor(req.Context()))
+    })
+    get := func(query string) *httptest.ResponseRecorder {
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items"+query, nil))
+        return rec
+    }
+
+    next := mw.EncodeCursor(secret, "id:42")
+    if rec := get("?cursor=" + next); rec.Code != http.StatusOK || rec.Body.String() != "after=id:42" {
+        t.Fatalf("expected decoded cursor, got %d %q", rec.Code, rec.Body.String())
+    }
+    if rec := get(""); rec.Code != http.StatusOK || rec.Body.String() != "after=" {
+        t.Fatalf("expected first page without cursor, got %d %q", rec.Code, rec.Body.String())
+    }
+
+    payload, sig, _ := strings.Cut(next, ".")
+    forged := mw.EncodeCursor(secret, "id:99")
+    forgedPayload, _, _ := strings.Cut(forged, ".")
+    for _, bad := range []string{forgedPayload + "." + sig, payload, payload + ".AAAA", mw.EncodeCursor([]byte("other"), "id:42")} {
+        if rec := get("?cursor=" + bad); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid_cursor") {
+            t.Fatalf("cursor %q: expected 400, got %d", bad, rec.Code)
+        }
+    }
+}
+
+func TestIdempotencyCollapsesConcurrentRequests(t *testing.T) {
+    var calls int32
+    entered := make(chan struct{})
+    release := make(chan struct{})
+    r := router.New()
+    r.Use(mw.Idempotency(nil, time.Minute))
+    r.PostFunc("/payments", func(w http.ResponseWriter, req *http.Request) {
+        if atomic.AddInt32(&calls, 1) == 1 { close(entered) }
+        <-release
+        w.Header().Set("Location", "/payments/1")
+        w.WriteHeader(http.StatusCreated)
+        io.WriteString(w, `{"id":1}`)
+    })
+
+    const n = 5
+    recs := make([]*httptest.ResponseRecorder, n)
+    var wg sync.WaitGroup
+    post := func(i int) {
+        defer wg.Done()
+        req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(`{"amount":10}`))
+        req.Header.Set("Idempotency-Key", "pay-1")
+        recs[i] = httptest.NewRecorder()
+        r.ServeHTTP(recs[i], req)
+    }
+    wg.Add(1)
+    go post(0)
+    <-entered
+    for i := 1; i < n; i++ {
+        wg.Add(1)
+        go post(i)
+    }
+    time.Sleep(20 * time.Millisecond) // let the followers reach the in-flight wait
+    close(release)
+    wg.Wait()
+
+    if got := atomic.LoadInt32(&calls); got != 1 { t.Fatalf("expected handler to run once, ran %d times", got) }
+    for i, rec := range recs {
+        if rec.Code != http.StatusCreated || rec.Body.String() != `{"id":1}` || rec.Header().Get("Location") != "/payments/1" {
+            t.Fatalf("request %d: unexpected response %d %q", i, rec.Code, rec.Body.String())
+        }
+        if replayed := rec.Header().Get("Idempotent-Replayed") == "true"; replayed != (i > 0) {
+            t.Fatalf("request %d: unexpected Idempotent-Replayed %q", i, rec.Header().Get("Idempotent-Replayed"))
+        }
+    }
+}
+
+func TestIdempotencyLocksAcrossInstances(t *testing.T) {
+    store := mw.NewMemoryIdempotencyStore()
+    entered, release := make(chan struct{}), make(chan struct{})
+    var calls int32
+    newInstance := func() *router.Router {
+        r := router.New()
+        r.Use(mw.Idempotency(store, time.Minute))
+        r.PostFunc("/payments", func(w http.ResponseWriter, req *http.Request) {
+            if atomic.AddInt32(&calls, 1) == 1 { close(entered) }
+            <-release
+            w.WriteHeader(http.StatusCreated)
+        })
+        return r
+    }
+    a, b := newInstance(), newInstance()
+    post := func(r *router.Router) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(`{"amount":10}`))
+        req.Header.Set("Idempotency-Key", "pay-2")
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, req)
+        return rec
+    }
+    done := make(chan *httptest.ResponseRecorder)
+    go func() { done <- post(a) }()
+    <-entered
+    rec := post(b)
+    if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), `"idempotency_key_in_progress"`) || rec.Header().Get("Retry-After") == "" {
+        t.Fatalf("expected 409 in progress on the other instance, got %d %q", rec.Code, rec.Body.String())
+    }
+    close(release)
+    if rec := <-done; rec.Code != http.StatusCreated { t.Fatalf("expected original 201, got %d", rec.Code) }
+    if rec := post(b); rec.Code != http.StatusCreated || rec.Header().Get("Idempotent-Replayed") != "true" { t.Fatalf("expected replay after completion, got %d", rec.Code) }
+    if got := atomic.LoadInt32(&calls); got != 1 { t.Fatalf("expected handler to run once, ran %d times", got) }
+}
+
+// lockHookStore runs hook the first time Lock is called.
+type lockHookStore struct {
+    *mw.MemoryIdempotencyStore
+    hooked int32
+    hook   func()
+}
+
+func (s *lockHookStore) Lock(key string, ttl time.Duration) (string, bool) {
+    if atomic.CompareAndSwapInt32(&s.hooked, 0, 1) { s.hook() }
+    return s.MemoryIdempotencyStore.Lock(key, ttl)
+}
+
+func TestIdempotencyRechecksStoreAfterLock(t *testing.T) {
+    store := &lockHookStore{MemoryIdempotencyStore: mw.NewMemoryIdempotencyStore()}
+    var calls int32
+    newInstance := func() *router.Router {
+        r := router.New()
+        r.Use(mw.Idempotency(store, time.Minute, mw.IdempotencyConfig{LockTTL: time.Second}))
+        r.PostFunc("/payments", func(w http.ResponseWriter, req *http.Request) {
+            atomic.AddInt32(&calls, 1)
+            w.WriteHeader(http.StatusCreated)
+        })
+        return r
+    }
+    a, b := newInstance(), newInstance()
+    post := func(r *router.Router) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(`{"amount":10}`))
+        req.Header.Set("Idempotency-Key", "pay-3")
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, req)
+        return rec
+    }
+    // Instance b handles the key completely between a's lookup and its Lock.
+    store.hook = func() { post(b) }
+    if rec := post(a); rec.Code != http.StatusCreated || rec.Header().Get("Idempotent-Replayed") != "true" {
+        t.Fatalf("expected replay of the other instance's response, got %d", rec.Code)
+    }
+    if got := atomic.LoadInt32(&calls); got != 1 { t.Fatalf("expected handler to run once, ran %d times", got) }
+}
+
+func TestMemoryIdempotencyStoreLockOwner(t *testing.T) {
+    s := mw.NewMemoryIdempotencyStore()
+    stale, ok := s.Lock("k", time.Millisecond)
+    if !ok { t.Fatal("expected first lock to succeed") }
+    time.Sleep(5 * time.Millisecond)
+    if _, ok := s.Lock("k", time.Minute); !ok { t.Fatal("expected expired lock to be claimable") }
+    s.Unlock("k", stale)
+    if _, ok := s.Lock("k", time.Minute); ok { t.Fatal("expected stale token not to release the new claim") }
+}
+
+func TestSignedRequest(t *testing.T) {
+    secret := []byte("webhook-secret")
+    calls := 0
+    r := router.New()
+    r.Use(mw.SignedRequest(mw.SignedRequestConfig{Secret: secret, MaxSkew: time.Minute}))
+    r.PostFunc("/hooks", func(w http.ResponseWriter, req *http.Request) {
+        b, _ := io.ReadAll(req.Body)
+        if string(b) != `{"event":"paid"}` { t.Errorf("handler saw body %q", b) }
+        calls++
+    })
+    send := func(ts time.Time, nonce string) *httptest.ResponseRecorder {
+        body := `{"event":"paid"}`
+        stamp := strconv.FormatInt(ts.Unix(), 10)
+        req := httptest.NewRequest(http.MethodPost, "/hooks", strings.NewReader(body))
+        req.Header.Set("X-Timestamp", stamp)
+        req.Header.Set("X-Nonce", nonce)
+        req.Header.Set("X-Signature", mw.SignRequest(secret, stamp, nonce, []byte(body)))
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, req)
+        return rec
+    }
+
+    if rec := send(time.Now(), "n-1"); rec.Code != http.StatusOK { t.Fatalf("expected fresh request to pass, got %d %s", rec.Code, rec.Body.String()) }
+    if rec := send(time.Now().Add(-2*time.Minute), "n-2"); rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "signature_expired") {
+        t.Fatalf("expected stale timestamp to get 401, got %d %s", rec.Code, rec.Body.String())
+    }
+    if rec := send(time.Now(), "n-1"); rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "signature_replayed") {
+        t.Fatalf("expected replayed nonce to get 401, got %d %s", rec.Code, rec.Body.String())
+    }
+    if calls != 1 { t.Fatalf("expected handler to run once, ran %d times", calls) }
+
+    small := router.New()
+    small.Use(mw.SignedRequest(mw.SignedRequestConfig{Secret: secret, MaxBody: 8}))
+    small.PostFunc("/hooks", func(w http.ResponseWriter, req *http.Request) { t.Error("oversized body reached the handler") })
+    req := httptest.NewRequest(http.MethodPost, "/hooks", strings.NewReader(`{"event":"paid"}`))
+    req.Header.Set("X-Timestamp", "1")
+    req.Header.Set("X-Nonce", "n")
+    req.Header.Set("X-Signature", "00")
+    rec := httptest.NewRecorder()
+    small.ServeHTTP(rec, req)
+    if rec.Code != http.StatusRequestEntityTooLarge { t.Fatalf("expected 413 for oversized body, got %d", rec.Code) }
+}
+
+func TestMemoryNonceStoreFull(t *testing.T) {
+    s := mw.NewMemoryNonceStore(2)
+    if !s.Use("a", time.Minute) || !s.Use("b", time.Minute) { t.Fatal("expected fresh nonces accepted") }
+    if s.Use("c", time.Minute) { t.Fatal("expected full store to refuse new nonces") }
+    if s.Use("a", time.Minute) { t.Fatal("expected live nonce to stay remembered") }
+
+    short := mw.NewMemoryNonceStore(1)
+    short.Use("x", 10*time.Millisecond)
+    time.Sleep(20 * time.Millisecond)
+    if !short.Use("y", time.Minute) { t.Fatal("expected expired nonces to free space") }
+}
+
+func TestTrailingSlash(t *testing.T) {
+    r := router.New()
+    r.GetFunc("/users", func(w http.ResponseWriter, _ *http.Request) { io.WriteString(w, "users") })
+    r.Mount("/docs", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { io.WriteString(w, "docs") }))
+
+    strip := mw.TrailingSlash(mw.StripSlash, http.StatusPermanentRedirect)(r)
+    add := mw.TrailingSlash(mw.AddSlash, 0)(r)
+    cases := []struct {
+        h        http.Handler
+        target   string
+        code     int
+        location string
+    }{
+        {strip, "/users/?page=2", http.StatusPermanentRedirect, "/users?page=2"},
+        {strip, "//evil.com/", http.StatusPermanentRedirect, "/evil.com"},
+        {strip, "/users", http.StatusOK, ""},
+        {strip, "/", http.StatusNotFound, ""},
+        {add, "/docs", http.StatusMovedPermanently, "/docs/"},
+        {add, "/app.js", http.StatusNotFound, ""},
+        {add, "/docs/", http.StatusOK, ""},
+    }
+    for _, c := range cases {
+        rec := httptest.NewRecorder()
+        c.h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, c.target, nil))
+        if rec.Code != c.code || rec.Header().Get("Location") != c.location {
+            t.Fatalf("%s: expected %d %q, got %d %q", c.target, c.code, c.location, rec.Code, rec.Header().Get("Location"))
+        }
+    }
+}
+
+func TestCompress(t *testing.T) {
+    var logBuf bytes.Buffer
+    payload := strings.Repeat(`{"name":"alice"},`, 200)
+    r := router.New()
+    r.Use(mw.LoggerWithFormatter(log.New(&logBuf, "", 0), func(e mw.LogEntry) string { return strconv.Itoa(e.Bytes) }))
+    r.Use(mw.Compress(gzip.DefaultCompression))
+    r.GetFunc("/json", func(w http.ResponseWriter, _ *http.Request) {
+        w.Header().Set("Content-Type", "application/json")
+        w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+        io.WriteString(w, payload)
+    })
+    r.GetFunc("/text", func(w http.ResponseWriter, _ *http.Request) { io.WriteString(w, payload) })
+    r.GetFunc("/png", func(w http.ResponseWriter, _ *http.Request) {
+        w.Header().Set("Content-Type", "image/png")
+        io.WriteString(w, payload)
+    })
+    r.GetFunc("/empty", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })
+    r.GetFunc("/partial", func(w http.ResponseWriter, _ *http.Request) {
+        w.Header().Set("Content-Type", "text/plain")
+        w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-99/%d", len(payload)))
+        w.WriteHeader(http.StatusPartialContent)
+        io.WriteString(w, payload[:100])
+    })
+    r.GetFunc("/range", func(w http.ResponseWriter, _ *http.Request) {
+        w.Header().Set("Content-Type", "text/plain")
+        w.Header().Set("Content-Range", "bytes */100")
+        w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+        io.WriteString(w, payload)
+    })
+
+    do := func(method, path, accept string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(method, path, nil)
+        if accept != "" { req.Header.Set("Accept-Encoding", accept) }
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, req)
+        return rec
+    }
+    get := func(path, accept string) *httptest.ResponseRecorder { return do(http.MethodGet, path, accept) }
+
+    logBuf.Reset()
+    rec := get("/json", "deflate;q=0.5, gzip")
+    if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Content-Length") != "" { t.Fatalf("expected gzip without Content-Length, got %v", rec.Header()) }
+    if rec.Header().Get("Vary") != "Accept-Encoding" { t.Fatalf("expected Vary header, got %q", rec.Header().Get("Vary")) }
+    wire := rec.Body.Len()
+    zr, err := gzip.NewReader(rec.Body)
+    if err != nil { t.Fatal(err) }
+    if body, _ := io.ReadAll(zr); string(body) != payload { t.Fatal("gzip body does not round-trip") }
+    if logged := strings.TrimSpace(logBuf.String()); logged != strconv.Itoa(wire) {
+        t.Fatalf("expected Logger to count compressed bytes, logged %s", logged)
+    }
+
+    rec = get("/text", "deflate")
+    if rec.Header().Get("Content-Encoding") != "deflate" { t.Fatalf("expected deflate for sniffed text, got %v", rec.Header()) }
+    zl, err := zlib.NewReader(rec.Body)
+    if err != nil { t.Fatalf("deflate body is not zlib: %v", err) }
+    if body, _ := io.ReadAll(zl); string(body) != payload { t.Fatal("deflate body does not round-trip") }
+
+    for _, c := range []struct{ path, accept string }{
+        {"/png", "gzip"},
+        {"/json", ""},
+        {"/json", "gzip;q=0, br"},
+        {"/empty", "gzip"},
+        {"/partial", "gzip"},
+        {"/range", "gzip"},
+    } {
+        rec := get(c.path, c.accept)
+        if rec.Header().Get("Content-Encoding") != "" { t.Fatalf("%s with %q: expected no compression, got %v", c.path, c.accept, rec.Header()) }
+    }
+
+    rec = do(http.MethodHead, "/json", "gzip")
+    if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Content-Length") != "" || rec.Body.Len() != 0 {
+        t.Fatalf("expected HEAD to carry the compressed GET's headers and no body, got %v with %d bytes", rec.Header(), rec.Body.Len())
+    }
+}
+
+func TestCompressWithConfig(t *testing.T) {
+    payload := strings.Repeat("hello ", 100)
+    upper := mw.CompressEncoder{Encoding: "x-upper", New: func(w io.Writer, _ int) io.WriteCloser { return upperWriter{w} }}
+    handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+        w.Header().Set("ETag", `"v1"`)
+        io.WriteString(w, payload)
+    })
+    get := func(m router.Middleware, accept string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodGet, "/", nil)
+        req.Header.Set("Accept-Encoding", accept)
+        rec := httptest.NewRecorder()
+        m(handler).ServeHTTP(rec, req)
+        return rec
+    }
+    rec := get(mw.CompressWithConfig(mw.CompressConfig{Encoders: []mw.CompressEncoder{upper}}), "gzip, x-upper")
+    if rec.Header().Get("Content-Encoding") != "x-upper" || rec.Body.String() != strings.ToUpper(payload) { t.Fatalf("expected custom encoder, got %v", rec.Header()) }
+    if rec.Header().Get("ETag") != `W/"v1"` { t.Fatalf("expected weakened ETag, got %q", rec.Header().Get("ETag")) }
+    if rec := get(mw.Compress(gzip.DefaultCompression), "x-upper"); rec.Header().Get("Content-Encoding") != "" || rec.Header().Get("ETag") != `"v1"` {
+        t.Fatalf("encoders must not leak into other Compress instances, got %v", rec.Header())
+    }
+}
+
+type upperWriter struct{ w io.Writer }
+
+func (u upperWriter) Write(p []byte) (int, error) { return u.w.Write(bytes.ToUpper(p)) }
+func (u upperWriter) Close() error                { return nil }
+
+func TestRateLimit(t *testing.T) {
+    r := router.New()
+    r.Use(mw.RateLimit(mw.RateLimitConfig{Limit: 2, Window: time.Minute}))
+    r.GetFunc("/x", func(w http.ResponseWriter, _ *http.Request) { io.WriteString(w, "ok") })
+
+    get := func(ip string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodGet, "/x", nil)
+        req.RemoteAddr = ip + ":1234"
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, req)
+        return rec
+    }
+
+    for i, wantRemaining := range []string{"1", "0"} {
+        rec := get("10.0.0.1")
+        if rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Remaining") != wantRemaining || rec.Header().Get("RateLimit-Limit") != "2" {
+            t.Fatalf("request %d: expected 200 with %s remaining, got %d %v", i, wantRemaining, rec.Code, rec.Header())
+        }
+    }
+    rec := get("10.0.0.1")
+    if rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), `"rate_limited"`) { t.Fatalf("expected 429, got %d %q", rec.Code, rec.Body.String()) }
+    if ra, _ := strconv.Atoi(rec.Header().Get("Retry-After")); ra < 29 || ra > 30 { t.Fatalf("expected Retry-After ~30s, got %q", rec.Header().Get("Retry-After")) }
+    if reset, _ := strconv.Atoi(rec.Header().Get("RateLimit-Reset")); reset < 59 || reset > 60 { t.Fatalf("expected RateLimit-Reset ~60s, got %q", rec.Header().Get("RateLimit-Reset")) }
+
+    if rec := get("10.0.0.2"); rec.Code != http.StatusOK { t.Fatalf("expected other client to have its own bucket, got %d", rec.Code) }
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(context.Context, string, int, time.Duration) (mw.RateLimitResult, error) {
+    return mw.RateLimitResult{}, fmt.Errorf("redis: connection refused")
+}
+
+func TestRateLimitStoreFailureFailsOpen(t *testing.T) {
+    r := router.New()
+    r.Use(mw.RateLimit(mw.RateLimitConfig{Limit: 1, Store: failingRateLimitStore{}}))
+    r.GetFunc("/x", func(w http.ResponseWriter, _ *http.Request) {})
+    for i := 0; i < 3; i++ {
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/x", nil))
+        if rec.Code != http.StatusOK { t.Fatalf("expected requests through on store failure, got %d", rec.Code) }
+    }
+}
+
+func TestThrottle(t *testing.T) {
+    release := make(chan struct{})
+    var started sync.WaitGroup
+    r := router.New()
+    r.Use(mw.Throttle(1, 1, 50*time.Millisecond))
+    r.GetFunc("/x", func(w http.ResponseWriter, _ *http.Request) {
+        started.Done()
+        <-release
+    })
+    serve := func() *httptest.ResponseRecorder {
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/x", nil))
+        return rec
+    }
+
+    // One request holds the only slot.
+    started.Add(1)
+    first := make(chan *httptest.ResponseRecorder)
+    go func() { first <- serve() }()
+    started.Wait()
+
+    // A second waits in the backlog and times out; while it waits, a third
+    // finds the backlog full and is shed immediately.
+    queued := make(chan *httptest.ResponseRecorder)
+    go func() { queued <- serve() }()
+    time.Sleep(10 * time.Millisecond)
+    if rec := serve(); rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"overloaded"`) {
+        t.Fatalf("expected 503 beyond the backlog, got %d %q", rec.Code, rec.Body.String())
+    }
+    if rec := <-queued; rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "timed out") {
+        t.Fatalf("expected backlog timeout, got %d %q", rec.Code, rec.Body.String())
+    }
+
+    close(release)
+    if rec := <-first; rec.Code != http.StatusOK { t.Fatalf("expected first request to finish, got %d", rec.Code) }
+    started.Add(1)
+    if rec := serve(); rec.Code != http.StatusOK { t.Fatalf("expected capacity to be released, got %d", rec.Code) }
+}
+
+// signJWT builds a compact JWS for tests.
+func signJWT(t *testing.T, alg, kid string, key any, claims map[string]any) string {
+    t.Helper()
+    seg := func(v any) string {
+        b, _ := json.Marshal(v)
+        return base64.RawURLEncoding.EncodeToString(b)
+    }
+    input := seg(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + seg(claims)
+    digest := sha256.Sum256([]byte(input))
+    var sig []byte
+    switch k := key.(type) {
+    case []byte:
+        mac := hmac.New(sha256.New, k)
+        mac.Write([]byte(input))
+        sig = mac.Sum(nil)
+    case *rsa.PrivateKey:
+        sig, _ = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+    case *ecdsa.PrivateKey:
+        r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+        if err != nil { t.Fatal(err) }
+        sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+    }
+    return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWT(t *testing.T) {
+    secret := []byte("hs-secret")
+    rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+    ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    keys := map[string]any{"hs": secret, "rs": &rsaKey.PublicKey, "es": &ecKey.PublicKey}
+
+    r := router.New()
+    r.Use(mw.JWT(mw.JWTConfig{
+        Keyfunc:  func(alg, kid string) (any, error) {
+            if k, ok := keys[kid]; ok { return k, nil }
+            return nil, fmt.Errorf("unknown kid %q", kid)
+        },
+        Cookie:   "session",
+        Audience: "api",
+    }))
+    r.GetFunc("/me", func(w http.ResponseWriter, req *http.Request) {
+        io.WriteString(w, ctxutil.GetClaims(req.Context())["sub"].(string))
+    })
+
+    valid := map[string]any{"sub": "alice", "aud": []any{"api"}, "exp": time.Now().Add(time.Hour).Unix()}
+    do := func(token string, cookie bool) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodGet, "/me", nil)
+        if cookie {
+            req.AddCookie(&http.Cookie{Name: "session", Value: token})
+        } else if token != "" {
+            req.Header.Set("Authorization", "Bearer "+token)
+        }
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, req)
+        return rec
+    }
+
+    for _, c := range []struct {
+        alg, kid string
+        key      any
+    }{{mw.HS256, "hs", secret}, {mw.RS256, "rs", rsaKey}, {mw.ES256, "es", ecKey}} {
+        if rec := do(signJWT(t, c.alg, c.kid, c.key, valid), false); rec.Code != http.StatusOK || rec.Body.String() != "alice" {
+            t.Fatalf("%s: expected 200 alice, got %d %q", c.alg, rec.Code, rec.Body.String())
+        }
+    }
+    if rec := do(signJWT(t, mw.HS256, "hs", secret, valid), true); rec.Code != http.StatusOK { t.Fatalf("expected cookie token accepted, got %d", rec.Code) }
+
+    expired := map[string]any{"sub": "alice", "aud": "api", "exp": time.Now().Add(-time.Hour).Unix()}
+    wrongAud := map[string]any{"sub": "alice", "aud": "other"}
+    cases := []struct {
+        name, token, code string
+    }{
+        {"missing", "", "token_missing"},
+        {"expired", signJWT(t, mw.HS256, "hs", secret, expired), "token_expired"},
+        {"bad signature", signJWT(t, mw.HS256, "hs", []byte("wrong"), valid), "token_invalid"},
+        {"wrong audience", signJWT(t, mw.HS256, "hs", secret, wrongAud), "token_invalid"},
+        {"unknown kid", signJWT(t, mw.HS256, "nope", secret, valid), "token_invalid"},
+        {"alg confusion", signJWT(t, mw.HS256, "rs", secret, valid), "token_invalid"},
+        {"garbage", "not.a.jwt", "token_invalid"},
+    }
+    for _, c := range cases {
+        rec := do(c.token, false)
+        if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), `"`+c.code+`"`) || rec.Header().Get("WWW-Authenticate") == "" {
+            t.Fatalf("%s: expected 401 %s, got %d %q", c.name, c.code, rec.Code, rec.Body.String())
+        }
+        if body := rec.Body.String(); strings.Contains(body, "kid") || strings.Contains(body, "audience") || strings.Contains(body, "signature") {
+            t.Fatalf("%s: verification details must not be sent, got %q", c.name, body)
+        }
+    }
+}
+
+func TestJWKSRotation(t *testing.T) {
+    rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+    ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+    var mu sync.Mutex
+    var fetches int
+    keys := []map[string]string{{"kty": "RSA", "kid": "rs", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())}}
+    idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        mu.Lock(); defer mu.Unlock()
+        fetches++
+        json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+    }))
+    defer idp.Close()
+
+    jwks, err := mw.NewJWKS(context.Background(), idp.URL+"/jwks.json", time.Hour)
+    if err != nil { t.Fatal(err) }
+    defer jwks.Close()
+
+    r := router.New()
+    r.Use(mw.JWT(mw.JWTConfig{Keyfunc: jwks.Keyfunc}))
+    r.GetFunc("/me", func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, ctxutil.GetClaims(r.Context())["sub"]) })
+    do := func(token string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodGet, "/me", nil)
+        req.Header.Set("Authorization", "Bearer "+token)
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, req)
+        return rec
+    }
+    claims := map[string]any{"sub": "alice"}
+    if rec := do(signJWT(t, mw.RS256, "rs", rsaKey, claims)); rec.Code != http.StatusOK || rec.Body.String() != "alice" {
+        t.Fatalf("expected 200 alice, got %d %q", rec.Code, rec.Body.String())
+    }
+
+    // A key published after the initial fetch is picked up on first use.
+    mu.Lock()
+    keys = append(keys, map[string]string{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))})
+    mu.Unlock()
+    if rec := do(signJWT(t, mw.ES256, "ec", ecKey, claims)); rec.Code != http.StatusUnauthorized {
+        t.Fatalf("expected refetch to be rate limited right after the initial fetch, got %d", rec.Code)
+    }
+    if err := jwks.Refresh(context.Background()); err != nil { t.Fatal(err) }
+    if rec := do(signJWT(t, mw.ES256, "ec", ecKey, claims)); rec.Code != http.StatusOK {
+        t.Fatalf("expected rotated key accepted, got %d %q", rec.Code, rec.Body.String())
+    }
+    if rec := do(signJWT(t, mw.ES256, "missing", ecKey, claims)); rec.Code != http.StatusUnauthorized { t.Fatalf("expected unknown kid rejected, got %d", rec.Code) }
+    if rec := do(signJWT(t, mw.ES256, "rs", ecKey, claims)); rec.Code != http.StatusUnauthorized { t.Fatalf("expected alg mismatch rejected, got %d", rec.Code) }
+    if _, err := jwks.Keyfunc(mw.ES256, "rs"); !errors.Is(err, mw.ErrKeyAlgMismatch) { t.Fatalf("expected ErrKeyAlgMismatch, got %v", err) }
+
+    // Symmetric keys published in a JWKS are public and must not verify tokens.
+    mu.Lock()
+    keys = append(keys, map[string]string{"kty": "oct", "kid": "hs", "k": b64([]byte("public-secret"))})
+    mu.Unlock()
+    if err := jwks.Refresh(context.Background()); err != nil { t.Fatal(err) }
+    if rec := do(signJWT(t, mw.HS256, "hs", []byte("public-secret"), claims)); rec.Code != http.StatusUnauthorized { t.Fatalf("expected oct key ignored, got %d", rec.Code) }
+    mu.Lock(); defer mu.Unlock()
+    if fetches != 3 { t.Fatalf("expected 3 fetches, got %d", fetches) }
+}
+
+func TestDiscoverJWKS(t *testing.T) {
+    idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path != "/.well-known/openid-configuration" { http.NotFound(w, r); return }
+        fmt.Fprintf(w, `{"issuer":"x","jwks_uri":"https://idp.example.com/keys"}`)
+    }))
+    defer idp.Close()
+    uri, err := mw.DiscoverJWKS(context.Background(), idp.URL+"/")
+    if err != nil || uri != "https://idp.example.com/keys" { t.Fatalf("expected jwks_uri, got %q %v", uri, err) }
+}
+
+func TestSecureHeaders(t *testing.T) {
+    r := router.New()
+    r.Use(mw.SecureHeaders(mw.SecureHeadersConfig{HSTSSubdomains: true}))
+    r.GetFunc("/", func(w http.ResponseWriter, req *http.Request) {})
+    r.With(mw.SecureHeadersOverride(mw.SecureHeadersConfig{
+        FrameOptions:   "SAMEORIGIN",
+        ReferrerPolicy: mw.Omit,
+        CSP:            mw.DefaultCSP().Set("frame-ancestors", "'self'").Add("img-src", "'self'", "data:", "'self'"),
+    })).GetFunc("/embed", func(w http.ResponseWriter, req *http.Request) {})
+
+    do := func(path string, https bool) http.Header {
+        req := httptest.NewRequest(http.MethodGet, path, nil)
+        if https { req.Header.Set("X-Forwarded-Proto", "https") }
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, req)
+        return rec.Header()
+    }
+    h := do("/", false)
+    want := map[string]string{
+        "X-Content-Type-Options":  "nosniff",
+        "X-Frame-Options":         "DENY",
+        "Referrer-Policy":         "strict-origin-when-cross-origin",
+        "Content-Security-Policy": "default-src 'self'; base-uri 'self'; object-src 'none'; frame-ancestors 'none'",
+    }
+    for k, v := range want {
+        if got := h.Get(k); got != v { t.Fatalf("%s: expected %q, got %q", k, v, got) }
+    }
+    if h.Get("Strict-Transport-Security") != "" { t.Fatal("HSTS must not be sent over plain HTTP") }
+    if got := do("/", true).Get("Strict-Transport-Security"); got != "max-age=31536000; includeSubDomains" { t.Fatalf("unexpected HSTS %q", got) }
+
+    h = do("/embed", false)
+    if h.Get("X-Frame-Options") != "SAMEORIGIN" || h.Get("Referrer-Policy") != "" || h.Get("X-Content-Type-Options") != "nosniff" {
+        t.Fatalf("override not applied: %v", h)
+    }
+    if got := h.Get("Content-Security-Policy"); got != "default-src 'self'; base-uri 'self'; object-src 'none'; frame-ancestors 'self'; img-src 'self' data:" {
+        t.Fatalf("unexpected CSP %q", got)
+    }
+
+    base := mw.NewCSP().Set("default-src", "'none'").Set("upgrade-insecure-requests")
+    ext := base.Clone().Add("script-src", "'self'").Remove("default-src")
+    if base.String() != "default-src 'none'; upgrade-insecure-requests" || ext.String() != "upgrade-insecure-requests; script-src 'self'" {
+        t.Fatalf("unexpected policies %q / %q", base, ext)
+    }
+}
+
+func TestSession(t *testing.T) {
+    store, _ := session.NewCookieStore([]byte("0123456789abcdef"))
+    r := router.New()
+    r.Use(mw.Session(store))
+    r.GetFunc("/login", func(w http.ResponseWriter, req *http.Request) {
+        s := session.FromContext(req.Context())
+        s.Regenerate()
+        s.Set("user", "alice")
+        io.WriteString(w, "ok")
+    })
+    r.GetFunc("/me", func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, session.FromContext(req.Context()).GetString("user")) })
+    r.GetFunc("/static", func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, "public") })
+    r.GetFunc("/logout", func(w http.ResponseWriter, req *http.Request) { session.FromContext(req.Context()).Destroy() })
+
+    do := func(path string, c *http.Cookie) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodGet, path, nil)
+        if c != nil { req.AddCookie(c) }
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, req)
+        return rec
+    }
+    rec := do("/login", nil)
+    cookies := rec.Result().Cookies()
+    if len(cookies) != 1 || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteLaxMode || rec.Header().Get("Cache-Control") != "private" {
+        t.Fatalf("expected a session cookie on a private response, got %v %v", cookies, rec.Header())
+    }
+    c := cookies[0]
+    if rec := do("/me", c); rec.Body.String() != "alice" || len(rec.Result().Cookies()) != 0 {
+        t.Fatalf("expected alice without a new cookie, got %q %v", rec.Body.String(), rec.Result().Cookies())
+    }
+    if rec := do("/static", nil); len(rec.Result().Cookies()) != 0 || rec.Header().Get("Vary") != "" { t.Fatal("untouched sessions must not set cookies") }
+    rec = do("/logout", c)
+    if out := rec.Result().Cookies(); len(out) != 1 || out[0].MaxAge != -1 { t.Fatalf("expected cookie expired on logout, got %v", out) }
+}
+
+func TestETag(t *testing.T) {
+    modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+    r := router.New()
+    r.Use(mw.ETag(mw.ETagConfig{MaxBuffer: 16}))
+    r.GetFunc("/doc", func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, "hello") })
+    r.GetFunc("/big", func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, strings.Repeat("x", 32)) })
+    r.GetFunc("/file", func(w http.ResponseWriter, req *http.Request) {
+        w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+        io.WriteString(w, "file")
+    })
+    r.GetFunc("/missing", func(w http.ResponseWriter, req *http.Request) { http.NotFound(w, req) })
+    r.HeadFunc("/head", func(w http.ResponseWriter, req *http.Request) { w.Header().Set("Content-Length", "5") })
+
+    head := httptest.NewRecorder()
+    r.ServeHTTP(head, httptest.NewRequest(http.MethodHead, "/head", nil))
+    if head.Code != http.StatusOK || head.Header().Get("ETag") != "" || head.Header().Get("Content-Length") != "5" {
+        t.Fatalf("expected an untagged HEAD keeping its Content-Length, got %d %v", head.Code, head.Header())
+    }
+
+    do := func(path string, hdr ...string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodGet, path, nil)
+        for i := 0; i+1 < len(hdr); i += 2 { req.Header.Set(hdr[i], hdr[i+1]) }
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, req)
+        return rec
+    }
+    rec := do("/doc")
+    etag := rec.Header().Get("ETag")
+    if rec.Code != http.StatusOK || rec.Body.String() != "hello" || !strings.HasPrefix(etag, `"`) || rec.Header().Get("Content-Length") != "5" {
+        t.Fatalf("expected tagged 200, got %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+    }
+    if rec := do("/doc", "If-None-Match", `"other", W/`+etag); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+        t.Fatalf("expected 304 for matching If-None-Match, got %d %q", rec.Code, rec.Body.String())
+    }
+    if rec := do("/doc", "If-None-Match", `"other"`); rec.Code != http.StatusOK { t.Fatalf("expected 200 for stale tag, got %d", rec.Code) }
+    if rec := do("/doc", "If-None-Match", `"a, `+etag+`, b"`); rec.Code != http.StatusOK { t.Fatalf("expected tags to be compared whole, got %d", rec.Code) }
+
+    if rec := do("/big"); rec.Header().Get("ETag") != "" || rec.Body.Len() != 32 { t.Fatalf("expected oversized body streamed untagged, got %v %d", rec.Header(), rec.Body.Len()) }
+    if rec := do("/missing"); rec.Header().Get("ETag") != "" || rec.Code != http.StatusNotFound { t.Fatalf("expected untagged 404, got %d %v", rec.Code, rec.Header()) }
+
+    if rec := do("/file", "If-Modified-Since", modified.Add(time.Minute).Format(http.TimeFormat)); rec.Code != http.StatusNotModified || rec.Header().Get("ETag") != "" {
+        t.Fatalf("expected 304 from Last-Modified, got %d %v", rec.Code, rec.Header())
+    }
+    if rec := do("/file", "If-Modified-Since", modified.Add(-time.Minute).Format(http.TimeFormat)); rec.Code != http.StatusOK || rec.Body.String() != "file" {
+        t.Fatalf("expected 200 for newer resource, got %d", rec.Code)
+    }
+
+    weak := router.New()
+    weak.Use(mw.ETag(mw.ETagConfig{Weak: true}))
+    weak.GetFunc("/", func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, "hello") })
+    rec = httptest.NewRecorder()
+    weak.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+    if got := rec.Header().Get("ETag"); got != "W/"+etag { t.Fatalf("expected weak %s, got %s", etag, got) }
+}
+
+func TestMetrics(t *testing.T) {
+    reg := mw.NewMetricsRegistry()
+    r := router.New()
+    r.Use(mw.Metrics(mw.MetricsConfig{Namespace: "api", Registry: reg, DurationBuckets: []float64{60}, SizeBuckets: []float64{10}}))
+    r.GetFunc("/users/{id}", func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, "user "+req.PathValue("id")) })
+    r.Get("/metrics", reg)
+    r.NotFound(http.NotFoundHandler())
+
+    for _, path := range []string{"/users/1", "/users/2", "/nope"} {
+        r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+    }
+    r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PURGE", "/users/1", nil))
+    rec := httptest.NewRecorder()
+    r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+    if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") { t.Fatalf("unexpected content type %q", rec.Header().Get("Content-Type")) }
+    body := rec.Body.String()
+    for _, want := range []string{
+        "# TYPE api_requests_total counter\n",
+        `api_requests_total{method="GET",route="/users/{id}",status="200"} 2` + "\n",
+        `api_requests_total{method="GET",route="unmatched",status="404"} 1` + "\n",
+        `api_requests_total{method="OTHER",route="/users/{id}",status="405"} 1` + "\n",
+        "# TYPE api_request_duration_seconds histogram\n",
+        `api_request_duration_seconds_bucket{method="GET",route="/users/{id}",status="200",le="60"} 2` + "\n",
+        `api_request_duration_seconds_count{method="GET",route="/users/{id}",status="200"} 2` + "\n",
+        `api_response_size_bytes_bucket{method="GET",route="/users/{id}",status="200",le="10"} 2` + "\n",
+        `api_response_size_bytes_sum{method="GET",route="/users/{id}",status="200"} 12` + "\n",
+        `api_requests_in_flight{method="GET",route="/metrics"} 1` + "\n",
+        `api_requests_in_flight{method="GET",route="/users/{id}"} 0` + "\n",
+    } {
+        if !strings.Contains(body, want) { t.Fatalf("metrics missing %q:\n%s", want, body) }
+    }
+}
+
+func TestMetricsSharedNamespace(t *testing.T) {
+    reg := mw.NewMetricsRegistry()
+    r := router.New()
+    r.With(mw.Metrics(mw.MetricsConfig{Registry: reg})).GetFunc("/a", func(w http.ResponseWriter, req *http.Request) {})
+    r.With(mw.Metrics(mw.MetricsConfig{Registry: reg})).GetFunc("/b", func(w http.ResponseWriter, req *http.Request) {})
+    for _, path := range []string{"/a", "/b"} { r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil)) }
+
+    var b strings.Builder
+    reg.WriteMetrics(&b)
+    out := b.String()
+    if n := strings.Count(out, "# TYPE http_requests_total counter\n"); n != 1 { t.Fatalf("expected one requests_total family, got %d:\n%s", n, out) }
+    for _, want := range []string{`http_requests_total{method="GET",route="/a",status="200"} 1`, `http_requests_total{method="GET",route="/b",status="200"} 1`} {
+        if !strings.Contains(out, want) { t.Fatalf("metrics missing %q:\n%s", want, out) }
+    }
+
+    defer func() {
+        if v := recover(); !strings.Contains(fmt.Sprint(v), "different buckets") { t.Fatalf("expected a panic for conflicting buckets, got %v", v) }
+    }()
+    mw.Metrics(mw.MetricsConfig{Registry: reg, DurationBuckets: []float64{1}})
+}
+
+func TestSlogLogger(t *testing.T) {
+    var buf bytes.Buffer
+    l := slog.New(slog.NewJSONHandler(&buf, nil))
+    r := router.New()
+    r.Use(mw.RequestID(), mw.SlogLogger(l, mw.LogConfig{Headers: []string{"Authorization", "X-Client"}, SlowThreshold: 20 * time.Millisecond}))
+    r.GetFunc("/users/{id}", func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, "ok") })
+    r.GetFunc("/slow", func(w http.ResponseWriter, req *http.Request) { time.Sleep(25 * time.Millisecond) })
+    r.GetFunc("/fail", func(w http.ResponseWriter, req *http.Request) { w.WriteHeader(http.StatusBadGateway) })
+
+    do := func(path string) map[string]any {
+        buf.Reset()
+        req := httptest.NewRequest(http.MethodGet, path, nil)
+        req.Header.Set("Authorization", "Bearer secret")
+        req.Header.Set("X-Client", "cli/1.0")
+        req.Header.Set("User-Agent", "test-agent")
+        req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+        r.ServeHTTP(httptest.NewRecorder(), req)
+        var rec map[string]any
+        if err := json.Unmarshal(buf.Bytes(), &rec); err != nil { t.Fatalf("bad log line %q: %v", buf.String(), err) }
+        return rec
+    }
+    rec := do("/users/7")
+    if rec["level"] != "INFO" || rec["msg"] != "request" || rec["status"] != float64(200) || rec["route"] != "/users/{id}" || rec["bytes"] != float64(2) {
+        t.Fatalf("unexpected record %v", rec)
+    }
+    if rec["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" || rec["user_agent"] != "test-agent" || rec["request_id"] == nil || rec["latency"] == nil {
+        t.Fatalf("missing fields in %v", rec)
+    }
+    if hs, _ := rec["headers"].(map[string]any); hs["Authorization"] != "[REDACTED]" || hs["X-Client"] != "cli/1.0" { t.Fatalf("unexpected headers %v", rec["headers"]) }
+    if rec := do("/slow"); rec["level"] != "WARN" || rec["slow"] != true { t.Fatalf("expected slow request at WARN, got %v", rec) }
+    if rec := do("/fail"); rec["level"] != "ERROR" { t.Fatalf("expected 5xx at ERROR, got %v", rec) }
+
+    minimal := router.New()
+    minimal.Use(mw.SlogLogger(l, mw.LogConfig{Fields: mw.LogLatency}))
+    minimal.GetFunc("/", func(w http.ResponseWriter, req *http.Request) {})
+    buf.Reset()
+    minimal.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+    if strings.Contains(buf.String(), "user_agent") || !strings.Contains(buf.String(), `"latency"`) { t.Fatalf("expected only selected fields, got %s", buf.String()) }
+}
+
+func TestSlogLoggerSamplingAndSkip(t *testing.T) {
+    var buf bytes.Buffer
+    l := slog.New(slog.NewJSONHandler(&buf, nil))
+    var healthy atomic.Bool
+    healthy.Store(true)
+    r := router.New()
+    r.Use(mw.SlogLogger(l, mw.LogConfig{Fields: mw.LogLatency, SkipPaths: []string{"/healthz", "/debug/*"}, SampleRate: 1e-9}))
+    r.GetFunc("/healthz", func(w http.ResponseWriter, req *http.Request) {
+        if !healthy.Load() { w.WriteHeader(http.StatusServiceUnavailable) }
+    })
+    r.GetFunc("/debug/vars", func(w http.ResponseWriter, req *http.Request) {})
+    r.GetFunc("/items/{id}", func(w http.ResponseWriter, req *http.Request) {
+        switch req.PathValue("id") {
+        case "missing":
+            w.WriteHeader(http.StatusNotFound)
+        case "broken":
+            w.WriteHeader(http.StatusInternalServerError)
+        }
+    })
+    do := func(path string) string {
+        buf.Reset()
+        req := httptest.NewRequest(http.MethodGet, path, nil)
+        req.Header.Set("Cookie", "sid=secret")
+        r.ServeHTTP(httptest.NewRecorder(), req)
+        return buf.String()
+    }
+    for _, path := range []string{"/healthz", "/debug/vars", "/items/1", "/items/2"} {
+        if out := do(path); out != "" { t.Fatalf("%s: expected no log line, got %s", path, out) }
+    }
+    if out := do("/items/missing"); !strings.Contains(out, `"status":404`) { t.Fatalf("expected 4xx always logged, got %q", out) }
+    out := do("/items/broken?access_token=secret")
+    if !strings.Contains(out, `"level":"ERROR"`) || !strings.Contains(out, `"route":"/items/{id}"`) || strings.Contains(out, "secret") || strings.Contains(out, "Cookie") {
+        t.Fatalf("expected 5xx with all fields but no query or headers, got %s", out)
+    }
+    buf.Reset()
+    detailed := router.New()
+    detailed.Use(mw.SlogLogger(l, mw.LogConfig{ErrorDetails: true}))
+    detailed.GetFunc("/items/{id}", func(w http.ResponseWriter, req *http.Request) { w.WriteHeader(http.StatusInternalServerError) })
+    req := httptest.NewRequest(http.MethodGet, "/items/broken?debug=1", nil)
+    req.Header.Set("Cookie", "sid=secret")
+    detailed.ServeHTTP(httptest.NewRecorder(), req)
+    if out := buf.String(); !strings.Contains(out, `"query":"debug=1"`) || !strings.Contains(out, `"Cookie":"[REDACTED]"`) {
+        t.Fatalf("expected 5xx with full detail, got %s", out)
+    }
+    healthy.Store(false)
+    if out := do("/healthz"); !strings.Contains(out, `"status":503`) { t.Fatalf("expected failing health check logged, got %q", out) }
+}
+
+func TestIPFilter(t *testing.T) {
+    var audit bytes.Buffer
+    r := router.New()
+    r.Use(mw.RealIP(), mw.IPFilter(mw.IPFilterConfig{
+        Allow:          []string{"10.0.0.0/8", "2001:db8::/32", "192.0.2.7"},
+        Deny:           []string{"10.6.0.0/16"},
+        TrustedProxies: []string{"192.0.2.1"}, // httptest's RemoteAddr
+        Logger:         log.New(&audit, "", 0),
+    }))
+    r.GetFunc("/", func(w http.ResponseWriter, req *http.Request) {})
+
+    cases := []struct {
+        ip   string
+        want int
+    }{
+        {"10.1.2.3", http.StatusOK},
+        {"::ffff:10.1.2.3", http.StatusOK},
+        {"2001:db8::1", http.StatusOK},
+        {"192.0.2.7", http.StatusOK},
+        {"192.0.2.8", http.StatusForbidden},
+        {"10.6.1.1", http.StatusForbidden},
+        {"not-an-ip", http.StatusForbidden},
+    }
+    for _, c := range cases {
+        req := httptest.NewRequest(http.MethodGet, "/", nil)
+        req.Header.Set("X-Real-IP", c.ip)
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, req)
+        if rec.Code != c.want { t.Fatalf("%s: expected %d, got %d", c.ip, c.want, rec.Code) }
+        if c.want == http.StatusForbidden && !strings.Contains(rec.Body.String(), `"ip_forbidden"`) { t.Fatalf("%s: unexpected body %q", c.ip, rec.Body.String()) }
+    }
+    if !strings.Contains(audit.String(), `blocked GET / from "10.6.1.1" (denied)`) { t.Fatalf("unexpected audit log %q", audit.String()) }
+
+    // Forwarding headers from an untrusted peer are ignored.
+    direct := router.New()
+    direct.Use(mw.RealIP(), mw.IPFilter(mw.IPFilterConfig{Allow: []string{"10.0.0.0/8"}}))
+    direct.GetFunc("/", func(w http.ResponseWriter, req *http.Request) {})
+    req := httptest.NewRequest(http.MethodGet, "/", nil)
+    req.Header.Set("X-Forwarded-For", "10.0.0.1")
+    rec := httptest.NewRecorder()
+    direct.ServeHTTP(rec, req)
+    if rec.Code != http.StatusForbidden { t.Fatalf("expected spoofed X-Forwarded-For to be rejected, got %d", rec.Code) }
+
+    // Behind a trusted proxy, the right-most untrusted hop is the client.
+    req = httptest.NewRequest(http.MethodGet, "/", nil)
+    req.Header.Set("X-Forwarded-For", "10.0.0.1, 203.0.113.9, 192.0.2.1")
+    rec = httptest.NewRecorder()
+    r.ServeHTTP(rec, req)
+    if rec.Code != http.StatusForbidden { t.Fatalf("expected client-supplied hop to be ignored, got %d", rec.Code) }
+
+    defer func() {
+        if recover() == nil { t.Fatal("expected panic on invalid prefix") }
+    }()
+    mw.IPFilter(mw.IPFilterConfig{Deny: []string{"10.0.0.0/99"}})
+}
+
+func TestDump(t *testing.T) {
+    var out bytes.Buffer
+    r := router.New()
+    r.Use(mw.Dump(mw.DumpConfig{MaxBody: 8, HeaderSecret: "let-me-in", Logger: log.New(&out, "", 0)}))
+    r.PostFunc("/echo", func(w http.ResponseWriter, req *http.Request) {
+        body, _ := io.ReadAll(req.Body)
+        http.SetCookie(w, &http.Cookie{Name: "sid", Value: "secret"})
+        w.WriteHeader(http.StatusCreated)
+        w.Write(body)
+    })
+    do := func(debug string) string {
+        out.Reset()
+        req := httptest.NewRequest(http.MethodPost, "/echo?x=1", strings.NewReader("hello world"))
+        req.Header.Set("Authorization", "Bearer token")
+        if debug != "" { req.Header.Set("X-Debug-Dump", debug) }
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, req)
+        if rec.Body.String() != "hello world" { t.Fatalf("dump must not alter the response, got %q", rec.Body.String()) }
+        return out.String()
+    }
+    if got := do(""); got != "" { t.Fatalf("expected no dump without the debug header, got %q", got) }
+    if got := do("1"); got != "" { t.Fatalf("expected no dump with the wrong secret, got %q", got) }
+    got := do("let-me-in")
+    for _, want := range []string{"dump: POST /echo?x=1 HTTP/1.1", "> Authorization: [REDACTED]", "> X-Debug-Dump: [REDACTED]", "> hello wo\n> ... (3 more bytes)", "< 201 Created", "< Set-Cookie: [REDACTED]", "< hello wo\n"} {
+        if !strings.Contains(got, want) { t.Fatalf("dump missing %q:\n%s", want, got) }
+    }
+    if strings.Contains(got, "token") || strings.Contains(got, "secret") || strings.Contains(got, "let-me-in") { t.Fatalf("dump leaked credentials:\n%s", got) }
+
+    open := router.New()
+    open.Use(mw.Dump(mw.DumpConfig{Logger: log.New(&out, "", 0)}))
+    open.GetFunc("/", func(w http.ResponseWriter, req *http.Request) {})
+    out.Reset()
+    req := httptest.NewRequest(http.MethodGet, "/", nil)
+    req.Header.Set("X-Debug-Dump", "1")
+    open.ServeHTTP(httptest.NewRecorder(), req)
+    if out.Len() != 0 { t.Fatalf("expected header activation off without a secret, got %q", out.String()) }
+}
+
+func TestSingleflight(t *testing.T) {
+    var calls int32
+    entered, release := make(chan struct{}), make(chan struct{})
+    r := router.New()
+    r.Use(mw.Singleflight(nil))
+    r.GetFunc("/report", func(w http.ResponseWriter, req *http.Request) {
+        if atomic.AddInt32(&calls, 1) == 1 { close(entered) }
+        <-release
+        w.Header().Set("X-Generated", "1")
+        io.WriteString(w, "report "+req.URL.Query().Get("q"))
+    })
+    get := func(path string, hdr ...string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodGet, path, nil)
+        for i := 0; i+1 < len(hdr); i += 2 { req.Header.Set(hdr[i], hdr[i+1]) }
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, req)
+        return rec
+    }
+
+    const n = 5
+    recs := make([]*httptest.ResponseRecorder, n)
+    var wg sync.WaitGroup
+    wg.Add(1)
+    go func() { defer wg.Done(); recs[0] = get("/report?q=a") }()
+    <-entered
+    for i := 1; i < n; i++ {
+        wg.Add(1)
+        go func(i int) { defer wg.Done(); recs[i] = get("/report?q=a") }(i)
+    }
+    time.Sleep(20 * time.Millisecond) // let the followers reach the wait
+    close(release)
+    wg.Wait()
+    if got := atomic.LoadInt32(&calls); got != 1 { t.Fatalf("expected one handler run, got %d", got) }
+    for i, rec := range recs {
+        if rec.Code != http.StatusOK || rec.Body.String() != "report a" || rec.Header().Get("X-Generated") != "1" {
+            t.Fatalf("request %d: unexpected response %d %q %v", i, rec.Code, rec.Body.String(), rec.Header())
+        }
+    }
+
+    get("/report?q=b")
+    get("/report?q=a", "Authorization", "Bearer x")
+    get("/report?q=a", "Accept-Language", "de")
+    if got := atomic.LoadInt32(&calls); got != 4 { t.Fatalf("expected separate runs for other keys, credentialed and negotiated requests, got %d", got) }
+}
+
+func TestSingleflightSkipsVaryingResponses(t *testing.T) {
+    var calls int32
+    entered, release := make(chan struct{}), make(chan struct{})
+    r := router.New()
+    r.Use(mw.Singleflight(nil))
+    r.GetFunc("/tenant", func(w http.ResponseWriter, req *http.Request) {
+        if atomic.AddInt32(&calls, 1) == 1 {
+            close(entered)
+            <-release
+        }
+        w.Header().Set("Vary", "X-Tenant")
+        io.WriteString(w, req.Header.Get("X-Tenant"))
+    })
+    get := func(tenant string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodGet, "/tenant", nil)
+        req.Header.Set("X-Tenant", tenant)
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, req)
+        return rec
+    }
+
+    var wg sync.WaitGroup
+    var first, second *httptest.ResponseRecorder
+    wg.Add(2)
+    go func() { defer wg.Done(); first = get("a") }()
+    <-entered
+    go func() { defer wg.Done(); second = get("b") }()
+    time.Sleep(20 * time.Millisecond) // let the follower reach the wait
+    close(release)
+    wg.Wait()
+    if first.Body.String() != "a" || second.Body.String() != "b" {
+        t.Fatalf("responses varying on other headers must not be shared, got %q and %q", first.Body.String(), second.Body.String())
+    }
+    if got := atomic.LoadInt32(&calls); got != 2 { t.Fatalf("expected the follower to run the handler, got %d runs", got) }
+}
+
+func TestCircuitBreaker(t *testing.T) {
+    var failing atomic.Bool
+    var calls int32
+    failing.Store(true)
+    reg := mw.NewMetricsRegistry()
+    r := router.New()
+    r.Use(mw.CircuitBreaker(mw.CircuitBreakerConfig{MinRequests: 4, OpenFor: 50 * time.Millisecond, Probes: 2, Registry: reg}))
+    r.GetFunc("/upstream", func(w http.ResponseWriter, req *http.Request) {
+        atomic.AddInt32(&calls, 1)
+        if failing.Load() { w.WriteHeader(http.StatusBadGateway) }
+    })
+    r.GetFunc("/other", func(w http.ResponseWriter, req *http.Request) {})
+    get := func(path string) *httptest.ResponseRecorder {
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+        return rec
+    }
+
+    for i := 0; i < 4; i++ { get("/upstream") }
+    rec := get("/upstream")
+    if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"circuit_open"`) || rec.Header().Get("Retry-After") != "1" {
+        t.Fatalf("expected fast 503 once tripped, got %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+    }
+    if got := atomic.LoadInt32(&calls); got != 4 { t.Fatalf("handler must not run while open, ran %d times", got) }
+    if rec := get("/other"); rec.Code != http.StatusOK { t.Fatalf("breakers must be per route, got %d", rec.Code) }
+    metrics := func() string {
+        var b strings.Builder
+        reg.WriteMetrics(&b)
+        return b.String()
+    }
+    if m := metrics(); !strings.Contains(m, `http_circuit_breaker_state{key="/upstream"} 1`) || !strings.Contains(m, `http_circuit_breaker_rejected_total{key="/upstream"} 1`) {
+        t.Fatalf("unexpected metrics:\n%s", m)
+    }
+
+    // A failed probe reopens the breaker.
+    time.Sleep(60 * time.Millisecond)
+    if rec := get("/upstream"); rec.Code != http.StatusBadGateway { t.Fatalf("expected probe to reach the handler, got %d", rec.Code) }
+    if rec := get("/upstream"); rec.Code != http.StatusServiceUnavailable { t.Fatalf("expected reopened breaker, got %d", rec.Code) }
+
+    // Enough successful probes close it.
+    failing.Store(false)
+    time.Sleep(60 * time.Millisecond)
+    for i := 0; i < 3; i++ {
+        if rec := get("/upstream"); rec.Code != http.StatusOK { t.Fatalf("request %d: expected recovery, got %d", i, rec.Code) }
+    }
+    if m := metrics(); !strings.Contains(m, `http_circuit_breaker_state{key="/upstream"} 0`) { t.Fatalf("expected closed breaker:\n%s", m) }
+}
+
+func TestPriorityShed(t *testing.T) {
+    var pressure atomic.Value
+    pressure.Store(0.0)
+    entered, release := make(chan struct{}), make(chan struct{})
+    r := router.New()
+    r.Use(mw.PriorityShed(mw.PriorityShedConfig{
+        MaxInFlight: 2,
+        Classify:    mw.PriorityByPath(map[string]mw.Priority{"/batch": mw.PriorityLow, "/healthz": mw.PriorityCritical, "/api/admin": mw.PriorityHigh}, mw.PriorityNormal),
+        Pressure:    func() float64 { return pressure.Load().(float64) },
+    }))
+    r.GetFunc("/hold", func(w http.ResponseWriter, req *http.Request) {
+        close(entered)
+        <-release
+    })
+    for _, p := range []string{"/batch/export", "/api/users", "/api/admin/users", "/healthz"} {
+        r.GetFunc(p, func(w http.ResponseWriter, req *http.Request) {})
+    }
+    get := func(path string) int {
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+        return rec.Code
+    }
+
+    done := make(chan struct{})
+    go func() { get("/hold"); close(done) }()
+    <-entered
+    if code := get("/batch/export"); code != http.StatusServiceUnavailable { t.Fatalf("expected low priority shed at half capacity, got %d", code) }
+    if code := get("/api/users"); code != http.StatusOK { t.Fatalf("expected normal priority admitted, got %d", code) }
+    close(release)
+    <-done
+
+    pressure.Store(0.95)
+    want := map[string]int{"/batch/export": 503, "/api/users": 503, "/api/admin/users": 503, "/healthz": 200}
+    for path, code := range want {
+        if got := get(path); got != code { t.Fatalf("%s under pressure: expected %d, got %d", path, code, got) }
+    }
+    if p, ok := mw.ParsePriority("HIGH"); !ok || p != mw.PriorityHigh { t.Fatalf("unexpected ParsePriority result %v %v", p, ok) }
+}
+
+func slowStackHandler(w http.ResponseWriter, req *http.Request) { time.Sleep(40 * time.Millisecond) }
+
+func TestSlowStack(t *testing.T) {
+    var buf bytes.Buffer
+    var mu sync.Mutex
+    r := router.New()
+    r.Use(mw.SlowStack(10*time.Millisecond, log.New(writerFunc(func(p []byte) (int, error) {
+        mu.Lock(); defer mu.Unlock()
+        return buf.Write(p)
+    }), "", 0)))
+    r.GetFunc("/slow", slowStackHandler)
+    r.GetFunc("/fast", func(w http.ResponseWriter, req *http.Request) {})
+
+    r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fast", nil))
+    if buf.Len() != 0 { t.Fatalf("expected nothing logged for a fast request, got %q", buf.String()) }
+    r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+    mu.Lock(); defer mu.Unlock()
+    out := buf.String()
+    warn, fin := strings.Index(out, "slow request still running: GET /slow after 10ms"), strings.Index(out, "slow request finished: GET /slow in ")
+    if warn < 0 || fin < warn { t.Fatalf("expected warning then completion, got %q", out) }
+    if !strings.Contains(out[warn:fin], "slowStackHandler") { t.Fatalf("expected the handler's stack in the warning, got %q", out[warn:fin]) }
+
+    buf.Reset()
+    mu.Unlock()
+    r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+    mu.Lock()
+    if out := buf.String(); !strings.Contains(out, "slow request still running") || !strings.Contains(out, "stack omitted") || strings.Contains(out, "slowStackHandler") {
+        t.Fatalf("expected a second warning within the interval to skip the stack, got %q", out)
+    }
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+func TestDeadline(t *testing.T) {
+    r := router.New()
+    r.Use(mw.Deadline(mw.DeadlineConfig{Max: time.Second}))
+    r.GetFunc("/budget", func(w http.ResponseWriter, req *http.Request) {
+        dl, ok := req.Context().Deadline()
+        if !ok {
+            io.WriteString(w, "none")
+            return
+        }
+        fmt.Fprint(w, time.Until(dl).Round(100*time.Millisecond))
+    })
+    do := func(header, value string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodGet, "/budget", nil)
+        if header != "" { req.Header.Set(header, value) }
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, req)
+        return rec
+    }
+    cases := []struct {
+        header, value, want string
+    }{
+        {"", "", "none"},
+        {"X-Request-Timeout-Ms", "500", "500ms"},
+        {"Grpc-Timeout", "300m", "300ms"},
+        {"Grpc-Timeout", "1H", "1s"},
+        {"X-Request-Timeout-Ms", "soon", "none"},
+        {"Grpc-Timeout", "5x", "none"},
+    }
+    for _, c := range cases {
+        if got := do(c.header, c.value).Body.String(); got != c.want { t.Fatalf("%s: %s: expected %s, got %s", c.header, c.value, c.want, got) }
+    }
+    if rec := do("X-Request-Timeout-Ms", "0"); rec.Code != http.StatusGatewayTimeout || !strings.Contains(rec.Body.String(), `"deadline_exceeded"`) {
+        t.Fatalf("expected 504 for a spent budget, got %d %q", rec.Code, rec.Body.String())
+    }
+
+    // Budgets propagate from an httplib client to an httplib server.
+    srv := httptest.NewServer(r)
+    defer srv.Close()
+    c := client.New([]client.Endpoint{{BaseURL: srv.URL}}, client.WithDeadlineHeader(""))
+    defer c.Close()
+    ctx, cancel := context.WithTimeout(context.Background(), 700*time.Millisecond)
+    defer cancel()
+    req, _ := http.NewRequest(http.MethodGet, "/budget", nil)
+    resp, err := c.Do(ctx, req)
+    if err != nil { t.Fatal(err) }
+    body, _ := io.ReadAll(resp.Body)
+    resp.Body.Close()
+    if string(body) != "700ms" { t.Fatalf("expected the client's budget on the server, got %q", body) }
+}
+
+func TestBaggage(t *testing.T) {
+    downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprintf(w, "%s|%s|%s|%s", r.Header.Get("X-Request-ID"), r.Header.Get("X-B3-TraceId"), r.Header.Get("Traceparent"), r.Header.Get("X-Secret"))
+    }))
+    defer downstream.Close()
+    c := client.New([]client.Endpoint{{BaseURL: downstream.URL}}, client.WithBaggage())
+    defer c.Close()
+
+    r := router.New()
+    r.Use(mw.RequestID(), mw.Baggage())
+    r.GetFunc("/proxy", func(w http.ResponseWriter, req *http.Request) {
+        out, _ := http.NewRequest(http.MethodGet, "/", nil)
+        resp, err := c.Do(req.Context(), out)
+        if err != nil { t.Fatal(err) }
+        defer resp.Body.Close()
+        io.Copy(w, resp.Body)
+    })
+    req := httptest.NewRequest(http.MethodGet, "/proxy", nil)
+    req.Header.Set("X-B3-TraceId", "80f198ee56343ba8")
+    req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+    req.Header.Set("X-Secret", "not propagated")
+    rec := httptest.NewRecorder()
+    r.ServeHTTP(rec, req)
+    parts := strings.Split(rec.Body.String(), "|")
+    if len(parts) != 4 || parts[0] == "" || parts[0] != rec.Header().Get("X-Request-ID") || parts[1] != "80f198ee56343ba8" || !strings.HasPrefix(parts[2], "00-4bf9") || parts[3] != "" {
+        t.Fatalf("unexpected downstream headers %q (request ID %q)", rec.Body.String(), rec.Header().Get("X-Request-ID"))
+    }
+
+    only := router.New()
+    only.Use(mw.Baggage("X-Tenant-ID"))
+    only.GetFunc("/", func(w http.ResponseWriter, req *http.Request) { fmt.Fprint(w, ctxutil.GetBaggage(req.Context())) })
+    req = httptest.NewRequest(http.MethodGet, "/", nil)
+    req.Header.Set("X-Tenant-ID", "acme")
+    req.Header.Set("Traceparent", "x")
+    rec = httptest.NewRecorder()
+    only.ServeHTTP(rec, req)
+    if rec.Body.String() != "map[X-Tenant-Id:acme]" { t.Fatalf("unexpected baggage %q", rec.Body.String()) }
+}
+
+func TestContextLogger(t *testing.T) {
+    var buf bytes.Buffer
+    base := slog.New(slog.NewJSONHandler(&buf, nil))
+    r := router.New()
+    r.Use(mw.RequestID(), mw.ContextLogger(base))
+    r.GetFunc("/users/{id}", func(w http.ResponseWriter, req *http.Request) {
+        ctxutil.Logger(req.Context()).Info("loaded user")
+    })
+    rec := httptest.NewRecorder()
+    req := httptest.NewRequest(http.MethodGet, "/users/7", nil)
+    req.RemoteAddr = "192.0.2.10:1234"
+    r.ServeHTTP(rec, req)
+    var line map[string]any
+    if err := json.Unmarshal(buf.Bytes(), &line); err != nil { t.Fatalf("bad log line %q: %v", buf.String(), err) }
+    if line["request_id"] != rec.Header().Get("X-Request-ID") || line["ip"] != "192.0.2.10" || line["route"] != "/users/{id}" || line["msg"] != "loaded user" {
+        t.Fatalf("unexpected log line %v", line)
+    }
+
+    if ctxutil.Logger(context.Background()) != slog.Default() { t.Fatal("expected default logger without middleware") }
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+    "net/http"
+    "strings"
+    "sync/atomic"
+
+    "github.com/shkmv/httplib/router"
+)
+
+// Priority is a request's importance tier for PriorityShed.
+type Priority int
+
+const (
+    PriorityLow      Priority = iota // batch jobs, prefetches, crawlers
+    PriorityNormal                   // ordinary traffic
+    PriorityHigh                     // interactive, paying or internal callers
+    PriorityCritical                 // health checks, control plane; shed last
+)
+
+// ParsePriority parses "low", "normal", "high" or "critical".
+func ParsePriority(s string) (Priority, bool) {
+    switch strings.ToLower(strings.TrimSpace(s)) {
+    case "low":
+        return PriorityLow, true
+    case "normal":
+        return PriorityNormal, true
+    case "high":
+        return PriorityHigh, true
+    case "critical":
+        return PriorityCritical, true
+    }
+    return PriorityNormal, false
+}
+
+// PriorityShedConfig configures PriorityShed.
+type PriorityShedConfig struct {
+    MaxInFlight int                          // capacity the tiers' shares refer to; required
+    Shares      [4]float64                   // load, per tier, above which it is shed; default {0.5, 0.75, 0.9, 1}
+    Classify    func(*http.Request) Priority // default PriorityByHeader("X-Priority")
+    Pressure    func() float64               // optional extra load signal in [0, 1], e.g. CPU utilization; must be cheap
+}
+
+// PriorityShed sheds low-priority traffic first as load rises. Load is the
+// share of MaxInFlight in use, or Pressure() when that is higher; a request
+// is admitted only while load is below its tier's share, so with the default
+// shares low priority requests are shed at half capacity and critical ones
+// only at full capacity. Shed requests get 503 "overloaded" with
+// Retry-After. Classify by auth principal with a custom function:
+//  Classify: func(r *http.Request) middleware.Priority {
+//      if ctxutil.GetClaims(r.Context())["plan"] == "enterprise" { return middleware.PriorityHigh }
+//      return middleware.PriorityNormal
+//  }
+func PriorityShed(cfg PriorityShedConfig) router.Middleware {
+    if cfg.MaxInFlight <= 0 { panic("middleware: PriorityShed needs a positive MaxInFlight") }
+    if cfg.Shares == [4]float64{} { cfg.Shares = [4]float64{0.5, 0.75, 0.9, 1} }
+    if cfg.Classify == nil { cfg.Classify = PriorityByHeader("X-Priority") }
+    var inflight atomic.Int64
+    return router.Named("PriorityShed", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            p := cfg.Classify(r)
+            if p < PriorityLow { p = PriorityLow }
+            if p > PriorityCritical { p = PriorityCritical }
+            n := inflight.Add(1)
+            defer inflight.Add(-1)
+            load := float64(n-1) / float64(cfg.MaxInFlight)
+            if cfg.Pressure != nil {
+                if pr := cfg.Pressure(); pr > load { load = pr }
+            }
+            if load >= cfg.Shares[p] {
+                w.Header().Set("Retry-After", "1")
+                router.RenderError(w, r, http.StatusServiceUnavailable, "overloaded", "server is shedding "+p.String()+" priority requests, try again later", nil)
+                return
+            }
+            next.ServeHTTP(w, r)
+        })
+    })
+}
+
+func (p Priority) String() string {
+    switch p {
+    case PriorityLow:
+        return "low"
+    case PriorityHigh:
+        return "high"
+    case PriorityCritical:
+        return "critical"
+    }
+    return "normal"
+}
+
+// PriorityByHeader classifies requests by a header holding a ParsePriority
+// name, defaulting to PriorityNormal. Clients can claim any tier, so only use
+// it behind a proxy that sets or strips the header.
+func PriorityByHeader(name string) func(*http.Request) Priority {
+    return func(r *http.Request) Priority {
+        p, _ := ParsePriority(r.Header.Get(name))
+        return p
+    }
+}
+
+// PriorityByPath classifies requests by the longest matching path prefix in
+// tiers, or def when none matches.
+func PriorityByPath(tiers map[string]Priority, def Priority) func(*http.Request) Priority {
+    return func(r *http.Request) Priority {
+        best, p := -1, def
+        for prefix, tier := range tiers {
+            if len(prefix) > best && strings.HasPrefix(r.URL.Path, prefix) { best, p = len(prefix), tier }
+        }
+        return p
+    }
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "net/http"
+    "strings"
+
+    "github.com/shkmv/httplib/router"
+)
+
+// PrivateETag marks personalized GET/HEAD responses as private and tags them
+// with an ETag derived from both the user identity and the response body, so
+// shared caches never serve one user's content to another and two users never
+// share a validator for the same path. identity returns the authenticated user
+// for the request; requests without an identity pass through untouched.
+func PrivateETag(identity func(*http.Request) string) router.Middleware {
+    return router.Named("PrivateETag", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if r.Method != http.MethodGet && r.Method != http.MethodHead {
+                next.ServeHTTP(w, r)
+                return
+            }
+            user := identity(r)
+            if user == "" {
+                next.ServeHTTP(w, r)
+                return
+            }
+
+            bw := &bufferedResponseWriter{ResponseWriter: w}
+            next.ServeHTTP(bw, r)
+
+            h := w.Header()
+            h.Add("Vary", "Authorization")
+            h.Add("Vary", "Cookie")
+            h.Set("Cache-Control", privateCacheControl(h.Get("Cache-Control")))
+            if bw.code() != http.StatusOK {
+                bw.flush()
+                return
+            }
+
+            sum := sha256.New()
+            sum.Write([]byte(user))
+            sum.Write([]byte{0})
+            sum.Write(bw.buf.Bytes())
+            etag := `"` + hex.EncodeToString(sum.Sum(nil)[:16]) + `"`
+            h.Set("ETag", etag)
+            if router.ETagMatches(r.Header.Get("If-None-Match"), etag) {
+                h.Del("Content-Length")
+                w.WriteHeader(http.StatusNotModified)
+                return
+            }
+            bw.flush()
+        })
+    })
+}
+
+// privateCacheControl ensures a Cache-Control value forbids shared caching.
+func privateCacheControl(cc string) string {
+    if cc == "" { return "private" }
+    lc := strings.ToLower(cc)
+    if strings.Contains(lc, "private") || strings.Contains(lc, "no-store") { return cc }
+    return "private, " + cc
+}
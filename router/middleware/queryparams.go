@@ -0,0 +1,38 @@
+package middleware
+
+import (
+    "net/http"
+    "net/url"
+    "strings"
+
+    "github.com/shkmv/httplib/router"
+)
+
+// AllowQueryParams strips every query parameter not named in names before the
+// request reaches the handler, rewriting r.URL.RawQuery. The relative order of
+// the remaining parameters is preserved. Useful in front of caches to prevent
+// tracking parameters from fragmenting or poisoning cache keys.
+func AllowQueryParams(names ...string) router.Middleware {
+    allowed := make(map[string]bool, len(names))
+    for _, n := range names { allowed[n] = true }
+    return router.Named("AllowQueryParams", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if r.URL.RawQuery != "" {
+                r.URL.RawQuery = filterQuery(r.URL.RawQuery, allowed)
+            }
+            next.ServeHTTP(w, r)
+        })
+    })
+}
+
+func filterQuery(raw string, allowed map[string]bool) string {
+    kept := make([]string, 0, 4)
+    for _, pair := range strings.Split(raw, "&") {
+        if pair == "" { continue }
+        key, _, _ := strings.Cut(pair, "=")
+        if k, err := url.QueryUnescape(key); err == nil && allowed[k] {
+            kept = append(kept, pair)
+        }
+    }
+    return strings.Join(kept, "&")
+}
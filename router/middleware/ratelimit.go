@@ -0,0 +1,147 @@
+package middleware
+
+import (
+    "context"
+    "math"
+    "net"
+    "net/http"
+    "strconv"
+    "sync"
+    "time"
+
+    "github.com/shkmv/httplib/router"
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+// RateLimitConfig configures RateLimit.
+type RateLimitConfig struct {
+    Limit  int                        // requests per Window, also the burst size
+    Window time.Duration              // default 1m
+    Key    func(*http.Request) string // default: client IP (see RealIP)
+    Store  RateLimitStore             // default: in-memory
+}
+
+// RateLimitStore keeps token buckets, e.g. in process or in Redis so that
+// several instances share limits.
+type RateLimitStore interface {
+    // Take spends one token from key's bucket, which holds up to limit
+    // tokens and refills at limit per window.
+    Take(ctx context.Context, key string, limit int, window time.Duration) (RateLimitResult, error)
+}
+
+// RateLimitResult is the outcome of a Take.
+type RateLimitResult struct {
+    Allowed    bool
+    Remaining  int           // whole tokens left
+    Reset      time.Duration // until the bucket is full again
+    RetryAfter time.Duration // until the next token, when not Allowed
+}
+
+// RateLimit limits requests per key with a token bucket. Every response
+// carries RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset (seconds);
+// requests over the limit get 429 "rate_limited" with Retry-After. If the
+// store fails, requests are let through rather than rejected.
+//  r.Use(middleware.RateLimit(middleware.RateLimitConfig{
+//      Limit: 100, Window: time.Minute,
+//      Key:   func(r *http.Request) string { return r.Header.Get("X-API-Key") },
+//  }))
+func RateLimit(cfg RateLimitConfig) router.Middleware {
+    if cfg.Limit <= 0 { panic("middleware: RateLimit needs a positive Limit") }
+    if cfg.Window <= 0 { cfg.Window = time.Minute }
+    if cfg.Key == nil { cfg.Key = clientIP }
+    if cfg.Store == nil { cfg.Store = NewMemoryRateLimitStore() }
+    limit := strconv.Itoa(cfg.Limit)
+    return router.Named("RateLimit", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            res, err := cfg.Store.Take(r.Context(), cfg.Key(r), cfg.Limit, cfg.Window)
+            if err != nil {
+                next.ServeHTTP(w, r)
+                return
+            }
+            h := w.Header()
+            h.Set("RateLimit-Limit", limit)
+            h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+            h.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+            if !res.Allowed {
+                h.Set("Retry-After", ceilSeconds(res.RetryAfter))
+                router.RenderError(w, r, http.StatusTooManyRequests, "rate_limited", "too many requests", nil)
+                return
+            }
+            next.ServeHTTP(w, r)
+        })
+    })
+}
+
+func clientIP(r *http.Request) string {
+    if ip := ctxutil.GetRealIP(r.Context()); ip != "" { return ip }
+    ip, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil { return r.RemoteAddr }
+    return ip
+}
+
+func ceilSeconds(d time.Duration) string {
+    return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// MemoryRateLimitStore is an in-process RateLimitStore. Buckets that have
+// refilled completely are dropped periodically.
+type MemoryRateLimitStore struct {
+    mu      sync.Mutex
+    buckets map[string]*tokenBucket
+    takes   int
+}
+
+type tokenBucket struct {
+    tokens float64
+    last   time.Time
+    rate   float64 // tokens per second
+    limit  float64
+}
+
+// NewMemoryRateLimitStore creates an empty in-memory store.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+    return &MemoryRateLimitStore{buckets: map[string]*tokenBucket{}}
+}
+
+// Take implements RateLimitStore.
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, limit int, window time.Duration) (RateLimitResult, error) {
+    s.mu.Lock(); defer s.mu.Unlock()
+    now := time.Now()
+    if s.takes++; s.takes%1024 == 0 { s.sweep(now) }
+
+    b, ok := s.buckets[key]
+    if !ok {
+        b = &tokenBucket{tokens: float64(limit), last: now}
+        s.buckets[key] = b
+    }
+    b.rate, b.limit = float64(limit)/window.Seconds(), float64(limit)
+    b.refill(now)
+
+    res := RateLimitResult{Allowed: b.tokens >= 1}
+    if res.Allowed {
+        b.tokens--
+    } else {
+        res.RetryAfter = b.until(1)
+    }
+    res.Remaining = int(b.tokens)
+    res.Reset = b.until(b.limit)
+    return res, nil
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+    b.tokens = math.Min(b.limit, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+    b.last = now
+}
+
+// until returns how long until the bucket holds n tokens.
+func (b *tokenBucket) until(n float64) time.Duration {
+    if b.tokens >= n { return 0 }
+    return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
+
+// sweep drops buckets that are full by now; they behave like missing ones.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+    for k, b := range s.buckets {
+        if b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.limit { delete(s.buckets, k) }
+    }
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+    "context"
     "net"
     "net/http"
     "strings"
@@ -11,7 +12,7 @@ import (
 
 // RealIP resolves the client IP using X-Forwarded-For or X-Real-IP and stores it in context.
 func RealIP() router.Middleware {
-    return func(next http.Handler) http.Handler {
+    return router.Named("RealIP", func(next http.Handler) http.Handler {
         return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
             ip := realIPFromRequest(r)
             if ip == "" {
@@ -20,11 +21,12 @@ func RealIP() router.Middleware {
                     ip = r.RemoteAddr
                 }
             }
+            ctx := context.WithValue(r.Context(), peerAddrKey{}, r.RemoteAddr)
             r.RemoteAddr = ip
-            r = r.WithContext(ctxutil.WithRealIP(r.Context(), ip))
+            r = r.WithContext(ctxutil.WithRealIP(ctx, ip))
             next.ServeHTTP(w, r)
         })
-    }
+    })
 }
 
 func realIPFromRequest(r *http.Request) string {
@@ -43,3 +45,14 @@ func realIPFromRequest(r *http.Request) string {
     return ""
 }
 
+
+type peerAddrKey struct{}
+
+// peerAddr returns the host of the connection's peer address, even after
+// RealIP has replaced r.RemoteAddr.
+func peerAddr(r *http.Request) string {
+    addr := r.RemoteAddr
+    if a, ok := r.Context().Value(peerAddrKey{}).(string); ok { addr = a }
+    if host, _, err := net.SplitHostPort(addr); err == nil { return host }
+    return addr
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+    "context"
     "log"
     "net/http"
     "runtime/debug"
@@ -8,19 +9,45 @@ import (
     "github.com/shkmv/httplib/router"
 )
 
+// RecovererConfig configures RecovererWithConfig.
+type RecovererConfig struct {
+    Logger  *log.Logger                                                 // logs the panic and stack; default log.Default()
+    OnPanic func(ctx context.Context, recovered any, stack []byte)      // e.g. report to an error tracker; runs after logging
+    Render  func(w http.ResponseWriter, r *http.Request, recovered any) // default: 500 JSON error envelope with the request ID
+}
+
 // Recoverer recovers from panics, logs stack, and returns 500.
 func Recoverer(l *log.Logger) router.Middleware {
-    if l == nil { l = log.Default() }
-    return func(next http.Handler) http.Handler {
+    return router.Named("Recoverer", RecovererWithConfig(RecovererConfig{
+        Logger: l,
+        Render: func(w http.ResponseWriter, r *http.Request, _ any) {
+            http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+        },
+    }))
+}
+
+// RecovererWithConfig recovers from panics like Recoverer, then passes the
+// panic to cfg.OnPanic and renders the response with cfg.Render.
+// http.ErrAbortHandler is re-panicked so net/http can abort the connection.
+func RecovererWithConfig(cfg RecovererConfig) router.Middleware {
+    if cfg.Logger == nil { cfg.Logger = log.Default() }
+    if cfg.Render == nil {
+        cfg.Render = func(w http.ResponseWriter, r *http.Request, _ any) {
+            router.InternalError(w, r, "internal_error", "internal server error")
+        }
+    }
+    return router.Named("RecovererWithConfig", func(next http.Handler) http.Handler {
         return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
             defer func() {
-                if rec := recover(); rec != nil {
-                    l.Printf("panic: %v\n%s", rec, debug.Stack())
-                    http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-                }
+                rec := recover()
+                if rec == nil { return }
+                if rec == http.ErrAbortHandler { panic(rec) }
+                stack := debug.Stack()
+                cfg.Logger.Printf("panic: %v\n%s", rec, stack)
+                if cfg.OnPanic != nil { cfg.OnPanic(r.Context(), rec, stack) }
+                cfg.Render(w, r, rec)
             }()
             next.ServeHTTP(w, r)
         })
-    }
+    })
 }
-
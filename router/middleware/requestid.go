@@ -12,7 +12,7 @@ import (
 
 // RequestID adds/propagates an X-Request-ID header and stores it in context.
 func RequestID() router.Middleware {
-    return func(next http.Handler) http.Handler {
+    return router.Named("RequestID", func(next http.Handler) http.Handler {
         return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
             id := r.Header.Get("X-Request-ID")
             if id == "" {
@@ -27,6 +27,6 @@ func RequestID() router.Middleware {
             r = r.WithContext(ctxutil.WithReqID(r.Context(), id))
             next.ServeHTTP(w, r)
         })
-    }
+    })
 }
 
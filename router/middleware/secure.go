@@ -0,0 +1,171 @@
+package middleware
+
+import (
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/shkmv/httplib/router"
+)
+
+// Omit disables a SecureHeadersConfig string header.
+const Omit = "-"
+
+// SecureHeadersConfig configures SecureHeaders. Zero fields get defaults.
+type SecureHeadersConfig struct {
+    HSTS           time.Duration // Strict-Transport-Security max-age; default 1 year, negative omits
+    HSTSSubdomains bool          // add includeSubDomains
+    HSTSPreload    bool          // add preload
+    FrameOptions   string        // X-Frame-Options; default "DENY"
+    ReferrerPolicy string        // default "strict-origin-when-cross-origin"
+    CSP            *CSP          // default DefaultCSP(); an empty NewCSP() omits the header
+    CSPReportOnly  bool          // send Content-Security-Policy-Report-Only instead
+}
+
+// SecureHeaders sets common security response headers before calling the
+// handler: Strict-Transport-Security (on HTTPS requests only, including those
+// with X-Forwarded-Proto: https), X-Content-Type-Options: nosniff,
+// X-Frame-Options, Referrer-Policy and Content-Security-Policy. String fields
+// set to Omit are not sent. Handlers and SecureHeadersOverride can replace
+// any of them per route.
+func SecureHeaders(cfg SecureHeadersConfig) router.Middleware {
+    if cfg.HSTS == 0 { cfg.HSTS = 365 * 24 * time.Hour }
+    if cfg.FrameOptions == "" { cfg.FrameOptions = "DENY" }
+    if cfg.ReferrerPolicy == "" { cfg.ReferrerPolicy = "strict-origin-when-cross-origin" }
+    if cfg.CSP == nil { cfg.CSP = DefaultCSP() }
+    apply := secureHeaders(cfg)
+    return router.Named("SecureHeaders", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            w.Header().Set("X-Content-Type-Options", "nosniff")
+            apply(w.Header(), r)
+            next.ServeHTTP(w, r)
+        })
+    })
+}
+
+// SecureHeadersOverride changes the headers set by an outer SecureHeaders for
+// the routes it wraps; only non-zero fields apply:
+//  r.With(middleware.SecureHeadersOverride(middleware.SecureHeadersConfig{
+//      FrameOptions: "SAMEORIGIN",
+//      CSP:          middleware.DefaultCSP().Set("frame-ancestors", "'self'"),
+//  })).Get("/embed", embed)
+func SecureHeadersOverride(cfg SecureHeadersConfig) router.Middleware {
+    apply := secureHeaders(cfg)
+    return router.Named("SecureHeadersOverride", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            apply(w.Header(), r)
+            next.ServeHTTP(w, r)
+        })
+    })
+}
+
+// secureHeaders precomputes the header values of cfg and returns a function
+// applying its non-zero fields.
+func secureHeaders(cfg SecureHeadersConfig) func(http.Header, *http.Request) {
+    var hsts string
+    if cfg.HSTS > 0 {
+        hsts = "max-age=" + strconv.FormatInt(int64(cfg.HSTS/time.Second), 10)
+        if cfg.HSTSSubdomains { hsts += "; includeSubDomains" }
+        if cfg.HSTSPreload { hsts += "; preload" }
+    }
+    cspHeader, cspOther := "Content-Security-Policy", "Content-Security-Policy-Report-Only"
+    if cfg.CSPReportOnly { cspHeader, cspOther = cspOther, cspHeader }
+    var csp string
+    if cfg.CSP != nil { csp = cfg.CSP.String() }
+    set := func(h http.Header, key, v string) {
+        switch v {
+        case "":
+        case Omit:
+            h.Del(key)
+        default:
+            h.Set(key, v)
+        }
+    }
+    return func(h http.Header, r *http.Request) {
+        if cfg.HSTS < 0 {
+            h.Del("Strict-Transport-Security")
+        } else if hsts != "" && (r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")) {
+            h.Set("Strict-Transport-Security", hsts)
+        }
+        set(h, "X-Frame-Options", cfg.FrameOptions)
+        set(h, "Referrer-Policy", cfg.ReferrerPolicy)
+        if cfg.CSP != nil {
+            h.Del(cspOther)
+            if csp == "" { h.Del(cspHeader) } else { h.Set(cspHeader, csp) }
+        }
+    }
+}
+
+// CSP builds a Content-Security-Policy header value, keeping directives in
+// the order they were first added:
+//  middleware.DefaultCSP().Add("img-src", "https://cdn.example.com").Set("report-uri", "/csp")
+type CSP struct {
+    names   []string
+    sources map[string][]string
+}
+
+// NewCSP returns an empty policy.
+func NewCSP() *CSP { return &CSP{sources: map[string][]string{}} }
+
+// DefaultCSP returns a restrictive same-origin policy: default-src 'self';
+// base-uri 'self'; object-src 'none'; frame-ancestors 'none'.
+func DefaultCSP() *CSP {
+    return NewCSP().
+        Set("default-src", "'self'").
+        Set("base-uri", "'self'").
+        Set("object-src", "'none'").
+        Set("frame-ancestors", "'none'")
+}
+
+// Set replaces the sources of directive. A directive without sources, such as
+// upgrade-insecure-requests, is rendered bare.
+func (c *CSP) Set(directive string, sources ...string) *CSP {
+    if _, ok := c.sources[directive]; !ok { c.names = append(c.names, directive) }
+    c.sources[directive] = append([]string(nil), sources...)
+    return c
+}
+
+// Add appends sources to directive, skipping ones already present.
+func (c *CSP) Add(directive string, sources ...string) *CSP {
+    cur, ok := c.sources[directive]
+    if !ok { c.names = append(c.names, directive) }
+    for _, s := range sources {
+        dup := false
+        for _, have := range cur { dup = dup || have == s }
+        if !dup { cur = append(cur, s) }
+    }
+    c.sources[directive] = cur
+    return c
+}
+
+// Remove drops directive.
+func (c *CSP) Remove(directive string) *CSP {
+    if _, ok := c.sources[directive]; !ok { return c }
+    delete(c.sources, directive)
+    for i, n := range c.names {
+        if n == directive { c.names = append(c.names[:i:i], c.names[i+1:]...); break }
+    }
+    return c
+}
+
+// Clone returns an independent copy, so a shared base policy can be extended
+// per route.
+func (c *CSP) Clone() *CSP {
+    out := NewCSP()
+    for _, n := range c.names { out.Set(n, c.sources[n]...) }
+    return out
+}
+
+// String renders the policy, e.g. "default-src 'self'; object-src 'none'".
+func (c *CSP) String() string {
+    parts := make([]string, 0, len(c.names))
+    for _, n := range c.names {
+        if src := c.sources[n]; len(src) > 0 {
+            parts = append(parts, n+" "+strings.Join(src, " "))
+        } else {
+            parts = append(parts, n)
+        }
+    }
+    return strings.Join(parts, "; ")
+}
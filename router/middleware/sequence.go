@@ -0,0 +1,63 @@
+package middleware
+
+import (
+    "net/http"
+    "strconv"
+    "sync"
+
+    "github.com/shkmv/httplib/router"
+)
+
+// SequenceStore tracks the last accepted sequence number per key.
+type SequenceStore interface {
+    // Advance atomically records seq for key if it is greater than the last
+    // recorded value. It returns the previous value and whether seq was accepted.
+    Advance(key string, seq uint64) (last uint64, ok bool)
+}
+
+// SequenceGuard rejects out-of-order writes. Unsafe requests (POST, PUT,
+// PATCH, DELETE) must carry an X-Seq header with a sequence number greater than
+// the last one accepted for keyFn(r); stale or repeated sequences get 409 and a
+// missing or malformed header gets 400. The sequence is recorded before the
+// handler runs. A nil store uses an in-memory store.
+func SequenceGuard(store SequenceStore, keyFn func(*http.Request) string) router.Middleware {
+    if store == nil { store = NewMemorySequenceStore() }
+    return router.Named("SequenceGuard", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if !isUnsafeMethod(r.Method) {
+                next.ServeHTTP(w, r)
+                return
+            }
+            seq, err := strconv.ParseUint(r.Header.Get("X-Seq"), 10, 64)
+            if err != nil {
+                router.BadRequest(w, r, "invalid_sequence", "X-Seq header must be a non-negative integer", nil)
+                return
+            }
+            if last, ok := store.Advance(keyFn(r), seq); !ok {
+                router.RenderError(w, r, http.StatusConflict, "stale_sequence", "request sequence is not newer than the last accepted one", map[string]any{"last_seq": last})
+                return
+            }
+            next.ServeHTTP(w, r)
+        })
+    })
+}
+
+// MemorySequenceStore is an in-process SequenceStore.
+type MemorySequenceStore struct {
+    mu   sync.Mutex
+    last map[string]uint64
+}
+
+// NewMemorySequenceStore creates an empty in-memory store.
+func NewMemorySequenceStore() *MemorySequenceStore {
+    return &MemorySequenceStore{last: map[string]uint64{}}
+}
+
+// Advance implements SequenceStore.
+func (s *MemorySequenceStore) Advance(key string, seq uint64) (uint64, bool) {
+    s.mu.Lock(); defer s.mu.Unlock()
+    last, seen := s.last[key]
+    if seen && seq <= last { return last, false }
+    s.last[key] = seq
+    return last, true
+}
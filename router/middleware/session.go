@@ -0,0 +1,100 @@
+package middleware
+
+import (
+    "log"
+    "net/http"
+    "time"
+
+    "github.com/shkmv/httplib/router"
+    "github.com/shkmv/httplib/session"
+)
+
+// SessionConfig configures the Session middleware.
+type SessionConfig struct {
+    CookieName string        // default "session"
+    Path       string        // default "/"
+    Domain     string
+    TTL        time.Duration // session and cookie lifetime; default 24h
+    Secure     bool          // always mark the cookie Secure; it is on HTTPS requests regardless
+    SameSite   http.SameSite // default Lax
+    Logger     *log.Logger   // receives load and save errors; default log.Default()
+}
+
+// Session makes the client's session available to handlers through
+// session.FromContext. The session is loaded from store only when a handler
+// first uses it and saved, with a fresh Set-Cookie, only when it changed;
+// saving happens before the response header is written, so handlers may
+// stream. Store errors are logged and leave the session empty or unsaved.
+func Session(store session.Store, cfgs ...SessionConfig) router.Middleware {
+    cfg := SessionConfig{}
+    if len(cfgs) > 0 { cfg = cfgs[0] }
+    if cfg.CookieName == "" { cfg.CookieName = "session" }
+    if cfg.Path == "" { cfg.Path = "/" }
+    if cfg.TTL <= 0 { cfg.TTL = 24 * time.Hour }
+    if cfg.SameSite == 0 { cfg.SameSite = http.SameSiteLaxMode }
+    if cfg.Logger == nil { cfg.Logger = log.Default() }
+    return router.Named("Session", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            var token string
+            if c, err := r.Cookie(cfg.CookieName); err == nil { token = c.Value }
+            sess := session.Load(r.Context(), store, token)
+            sw := &sessionWriter{ResponseWriter: w}
+            sw.commit = func() {
+                if err := sess.Err(); err != nil { cfg.Logger.Printf("session: load: %v", err) }
+                if !sess.Modified() { return }
+                token, err := sess.Commit(r.Context(), cfg.TTL)
+                if err != nil {
+                    cfg.Logger.Printf("session: save: %v", err)
+                    return
+                }
+                c := &http.Cookie{
+                    Name:     cfg.CookieName,
+                    Value:    token,
+                    Path:     cfg.Path,
+                    Domain:   cfg.Domain,
+                    MaxAge:   int(cfg.TTL / time.Second),
+                    Secure:   cfg.Secure || r.TLS != nil,
+                    HttpOnly: true,
+                    SameSite: cfg.SameSite,
+                }
+                if token == "" { c.MaxAge = -1 }
+                http.SetCookie(w, c)
+                w.Header().Add("Vary", "Cookie")
+                w.Header().Set("Cache-Control", privateCacheControl(w.Header().Get("Cache-Control")))
+            }
+            next.ServeHTTP(sw, r.WithContext(session.NewContext(r.Context(), sess)))
+            sw.flush()
+        })
+    })
+}
+
+// sessionWriter commits the session just before the header is written.
+type sessionWriter struct {
+    http.ResponseWriter
+    commit    func()
+    committed bool
+}
+
+func (w *sessionWriter) flush() {
+    if !w.committed {
+        w.committed = true
+        w.commit()
+    }
+}
+
+func (w *sessionWriter) WriteHeader(code int) {
+    if code >= 200 || code == http.StatusSwitchingProtocols { w.flush() }
+    w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *sessionWriter) Write(b []byte) (int, error) {
+    w.flush()
+    return w.ResponseWriter.Write(b)
+}
+
+func (w *sessionWriter) Flush() {
+    w.flush()
+    if f, ok := w.ResponseWriter.(http.Flusher); ok { f.Flush() }
+}
+
+func (w *sessionWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/shkmv/httplib/router"
+)
+
+// SharedCachePolicy declares how a CDN or other shared cache may store a
+// route's responses. Zero durations omit the matching directive.
+type SharedCachePolicy struct {
+    MaxAge               time.Duration // browser freshness (max-age)
+    SMaxAge              time.Duration // shared-cache freshness (s-maxage)
+    StaleWhileRevalidate time.Duration
+    StaleIfError         time.Duration
+    // SurrogateKeys returns purge tags for the request, emitted as both
+    // Surrogate-Key (space separated) and Cache-Tag (comma separated).
+    SurrogateKeys func(*http.Request) []string
+}
+
+// SharedCache sets public Cache-Control directives and surrogate keys on 200
+// responses to GET and HEAD. Handlers that set their own Cache-Control keep
+// it; surrogate keys are still added.
+func SharedCache(p SharedCachePolicy) router.Middleware {
+    cc := p.cacheControl()
+    return router.Named("SharedCache", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if r.Method != http.MethodGet && r.Method != http.MethodHead {
+                next.ServeHTTP(w, r)
+                return
+            }
+            var keys []string
+            if p.SurrogateKeys != nil { keys = p.SurrogateKeys(r) }
+            next.ServeHTTP(&sharedCacheWriter{ResponseWriter: w, cc: cc, keys: keys}, r)
+        })
+    })
+}
+
+func (p SharedCachePolicy) cacheControl() string {
+    parts := []string{"public"}
+    add := func(name string, d time.Duration) {
+        if d > 0 { parts = append(parts, name+"="+strconv.FormatInt(int64(d/time.Second), 10)) }
+    }
+    add("max-age", p.MaxAge)
+    add("s-maxage", p.SMaxAge)
+    add("stale-while-revalidate", p.StaleWhileRevalidate)
+    add("stale-if-error", p.StaleIfError)
+    return strings.Join(parts, ", ")
+}
+
+// sharedCacheWriter adds the cache headers just before a 200 status is sent.
+type sharedCacheWriter struct {
+    http.ResponseWriter
+    cc      string
+    keys    []string
+    written bool
+}
+
+func (w *sharedCacheWriter) WriteHeader(code int) {
+    if !w.written && code >= 200 {
+        w.written = true
+        if code == http.StatusOK {
+            h := w.Header()
+            if h.Get("Cache-Control") == "" { h.Set("Cache-Control", w.cc) }
+            if len(w.keys) > 0 {
+                h.Set("Surrogate-Key", strings.Join(w.keys, " "))
+                h.Set("Cache-Tag", strings.Join(w.keys, ","))
+            }
+        }
+    }
+    w.ResponseWriter.WriteHeader(code)
+}
+func (w *sharedCacheWriter) Write(b []byte) (int, error) {
+    if !w.written { w.WriteHeader(http.StatusOK) }
+    return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+    "bytes"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "io"
+    "net/http"
+    "strconv"
+    "sync"
+    "time"
+
+    "github.com/shkmv/httplib/router"
+)
+
+// SignedRequestConfig configures SignedRequest.
+type SignedRequestConfig struct {
+    Secret          []byte
+    SignatureHeader string        // default "X-Signature"
+    TimestampHeader string        // unix seconds; default "X-Timestamp"
+    NonceHeader     string        // default "X-Nonce"
+    MaxSkew         time.Duration // accepted clock difference either way; default 5m
+    Nonces          NonceStore    // default: in-memory store of 10000 nonces
+    MaxBody         int64         // largest body read for verification; default 1 MiB
+}
+
+// NonceStore remembers recently used request nonces.
+type NonceStore interface {
+    // Use records nonce for ttl and reports whether it was unused.
+    Use(nonce string, ttl time.Duration) bool
+}
+
+// SignedRequest verifies that requests are signed and fresh. The signature
+// header must carry hex(HMAC-SHA256(secret, timestamp + "\n" + nonce + "\n" +
+// body)) as produced by SignRequest; the timestamp must be within MaxSkew of
+// the server clock; and the nonce must not have been used within the skew
+// window, which blocks replays of captured requests. Any failure gets 401;
+// bodies over MaxBody get 413 "body_too_large" before they are verified.
+func SignedRequest(cfg SignedRequestConfig) router.Middleware {
+    if cfg.SignatureHeader == "" { cfg.SignatureHeader = "X-Signature" }
+    if cfg.TimestampHeader == "" { cfg.TimestampHeader = "X-Timestamp" }
+    if cfg.NonceHeader == "" { cfg.NonceHeader = "X-Nonce" }
+    if cfg.MaxSkew <= 0 { cfg.MaxSkew = 5 * time.Minute }
+    if cfg.Nonces == nil { cfg.Nonces = NewMemoryNonceStore(10000) }
+    if cfg.MaxBody <= 0 { cfg.MaxBody = 1 << 20 }
+    return router.Named("SignedRequest", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            ts, nonce := r.Header.Get(cfg.TimestampHeader), r.Header.Get(cfg.NonceHeader)
+            sig, err := hex.DecodeString(r.Header.Get(cfg.SignatureHeader))
+            if err != nil || len(sig) == 0 || ts == "" || nonce == "" {
+                router.Unauthorized(w, r, "signature_missing", "request signature, timestamp, and nonce are required")
+                return
+            }
+            body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, cfg.MaxBody))
+            var tooLarge *http.MaxBytesError
+            if errors.As(err, &tooLarge) {
+                router.RenderError(w, r, http.StatusRequestEntityTooLarge, "body_too_large", "request body is too large", nil)
+                return
+            }
+            if err != nil {
+                router.Unauthorized(w, r, "signature_invalid", "could not read request body")
+                return
+            }
+            r.Body = io.NopCloser(bytes.NewReader(body))
+            if !hmac.Equal(sig, requestMAC(cfg.Secret, ts, nonce, body)) {
+                router.Unauthorized(w, r, "signature_invalid", "request signature does not match")
+                return
+            }
+            sec, err := strconv.ParseInt(ts, 10, 64)
+            if skew := time.Since(time.Unix(sec, 0)); err != nil || skew > cfg.MaxSkew || skew < -cfg.MaxSkew {
+                router.Unauthorized(w, r, "signature_expired", "request timestamp is outside the allowed window")
+                return
+            }
+            if !cfg.Nonces.Use(nonce, 2*cfg.MaxSkew) {
+                router.Unauthorized(w, r, "signature_replayed", "request nonce was already used")
+                return
+            }
+            next.ServeHTTP(w, r)
+        })
+    })
+}
+
+// SignRequest returns the hex signature SignedRequest expects for a request
+// with the given unix timestamp, nonce, and body.
+func SignRequest(secret []byte, timestamp, nonce string, body []byte) string {
+    return hex.EncodeToString(requestMAC(secret, timestamp, nonce, body))
+}
+
+func requestMAC(secret []byte, timestamp, nonce string, body []byte) []byte {
+    m := hmac.New(sha256.New, secret)
+    io.WriteString(m, timestamp+"\n"+nonce+"\n")
+    m.Write(body)
+    return m.Sum(nil)
+}
+
+// MemoryNonceStore is an in-process NonceStore holding at most max nonces.
+// Nonces are only dropped once their ttl has passed, so while it is full new
+// nonces are refused (and their requests rejected) rather than forgetting
+// nonces that could still be replayed. Size max for the peak request rate
+// times 2*MaxSkew.
+type MemoryNonceStore struct {
+    mu      sync.Mutex
+    max     int
+    expires map[string]time.Time
+    order   []nonceEntry // insertion order, for eviction
+}
+
+type nonceEntry struct {
+    nonce   string
+    expires time.Time
+}
+
+// NewMemoryNonceStore creates a store bounded to max nonces.
+func NewMemoryNonceStore(max int) *MemoryNonceStore {
+    return &MemoryNonceStore{max: max, expires: map[string]time.Time{}}
+}
+
+// Use implements NonceStore.
+func (s *MemoryNonceStore) Use(nonce string, ttl time.Duration) bool {
+    s.mu.Lock(); defer s.mu.Unlock()
+    now := time.Now()
+    if exp, ok := s.expires[nonce]; ok && now.Before(exp) { return false }
+    // Drop expired entries; all share ttl, so they are at the front.
+    for len(s.order) > 0 {
+        oldest := s.order[0]
+        if now.Before(oldest.expires) { break }
+        s.order = s.order[1:]
+        // A nonce re-used after expiring has a newer entry; keep that one.
+        if s.expires[oldest.nonce] == oldest.expires { delete(s.expires, oldest.nonce) }
+    }
+    if len(s.order) >= s.max { return false }
+    s.expires[nonce] = now.Add(ttl)
+    s.order = append(s.order, nonceEntry{nonce, s.expires[nonce]})
+    return true
+}
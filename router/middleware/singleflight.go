@@ -0,0 +1,100 @@
+package middleware
+
+import (
+    "net/http"
+    "slices"
+    "strings"
+    "sync"
+
+    "github.com/shkmv/httplib/router"
+)
+
+// Singleflight collapses concurrent identical GET and HEAD requests into one
+// handler run: requests arriving while a request with the same key is being
+// handled wait for it and receive a copy of its status, headers and body.
+// key returns the coalescing key for a request, "" to handle it on its own;
+// it must distinguish everything the response depends on. A nil key uses the
+// method, URL and the Accept, Accept-Encoding and Accept-Language headers, and
+// skips requests carrying Authorization or Cookie headers, whose responses may
+// be personalized. A response whose Vary names any other header is not
+// shared. Waiters whose own context ends stop waiting; if the handler panics
+// or its response is not shared, each waiter runs the handler itself.
+func Singleflight(key func(*http.Request) string) router.Middleware {
+    if key == nil { key = defaultSingleflightKey }
+    var mu sync.Mutex
+    calls := map[string]*flightCall{}
+    return router.Named("Singleflight", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            k := ""
+            if r.Method == http.MethodGet || r.Method == http.MethodHead { k = key(r) }
+            if k == "" {
+                next.ServeHTTP(w, r)
+                return
+            }
+            k = r.Method + " " + k
+
+            mu.Lock()
+            if c, ok := calls[k]; ok {
+                mu.Unlock()
+                select {
+                case <-c.done:
+                case <-r.Context().Done():
+                    return
+                }
+                if c.resp == nil {
+                    next.ServeHTTP(w, r)
+                    return
+                }
+                for h, v := range c.resp.Header { w.Header()[h] = append([]string(nil), v...) }
+                w.WriteHeader(c.resp.Status)
+                _, _ = w.Write(c.resp.Body)
+                return
+            }
+            c := &flightCall{done: make(chan struct{})}
+            calls[k] = c
+            mu.Unlock()
+            defer func() {
+                mu.Lock()
+                delete(calls, k)
+                mu.Unlock()
+                close(c.done)
+            }()
+
+            before := w.Header().Clone()
+            bw := &bufferedResponseWriter{ResponseWriter: w}
+            next.ServeHTTP(bw, r)
+            resp := &IdempotentResponse{Status: bw.code(), Header: headerDiff(before, w.Header()), Body: bw.buf.Bytes()}
+            if shareable(resp.Header) { c.resp = resp }
+            bw.flush()
+        })
+    })
+}
+
+// flightCall is a request whose handler is still running.
+type flightCall struct {
+    done chan struct{}
+    resp *IdempotentResponse // set before done is closed; nil if the handler panicked or the response is not shared
+}
+
+// singleflightVary lists the request headers the default key covers.
+var singleflightVary = []string{"Accept", "Accept-Encoding", "Accept-Language"}
+
+func defaultSingleflightKey(r *http.Request) string {
+    if r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" { return "" }
+    k := r.Host + r.URL.RequestURI()
+    for _, h := range singleflightVary { k += "\n" + strings.Join(r.Header.Values(h), ",") }
+    return k
+}
+
+// shareable reports whether a response may be handed to waiters: it must not
+// vary on request headers outside singleflightVary.
+func shareable(h http.Header) bool {
+    for _, v := range h.Values("Vary") {
+        for _, f := range strings.Split(v, ",") {
+            f = strings.TrimSpace(f)
+            if f == "" { continue }
+            if !slices.ContainsFunc(singleflightVary, func(s string) bool { return strings.EqualFold(s, f) }) { return false }
+        }
+    }
+    return true
+}
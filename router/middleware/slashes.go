@@ -0,0 +1,56 @@
+package middleware
+
+import (
+    "net/http"
+    "path"
+    "strings"
+
+    "github.com/shkmv/httplib/router"
+)
+
+// SlashPolicy selects the canonical form TrailingSlash redirects to.
+type SlashPolicy int
+
+const (
+    // StripSlash redirects "/foo/" to "/foo".
+    StripSlash SlashPolicy = iota
+    // AddSlash redirects "/foo" to "/foo/". Paths whose last segment has a
+    // file extension, such as "/app.js", are left alone.
+    AddSlash
+)
+
+// TrailingSlash redirects requests to the canonical trailing-slash form of
+// their path so each resource has one URL. code is 301 or 308 (which keeps
+// the method and body); 0 means 301. The root path and the query string are
+// preserved. Middlewares added with Use run only after the mux has matched a
+// route, or for unmatched requests when the router has a NotFound handler or
+// router.WithUnmatchedMiddlewares. Without those, "/foo/" with only "/foo"
+// registered never reaches TrailingSlash, and the mux redirects "/foo" to a
+// registered "/foo/" before any middleware runs either way. Wrap the router
+// itself so every request is normalized first:
+//  http.ListenAndServe(":8080", middleware.TrailingSlash(middleware.StripSlash, http.StatusPermanentRedirect)(r))
+func TrailingSlash(policy SlashPolicy, code int) router.Middleware {
+    if code == 0 { code = http.StatusMovedPermanently }
+    return router.Named("TrailingSlash", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            p := r.URL.Path
+            target := p
+            switch {
+            case p == "/":
+            case policy == StripSlash && strings.HasSuffix(p, "/"):
+                target = strings.TrimRight(p, "/")
+            case policy == AddSlash && !strings.HasSuffix(p, "/") && path.Ext(p) == "":
+                target = p + "/"
+            }
+            if target == p {
+                next.ServeHTTP(w, r)
+                return
+            }
+            // A leading "//" would redirect to another host.
+            target = "/" + strings.TrimLeft(target, "/")
+            u := *r.URL
+            u.Path, u.RawPath = target, ""
+            http.Redirect(w, r, u.RequestURI(), code)
+        })
+    })
+}
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+    "log/slog"
+    "math/rand/v2"
+    "net"
+    "net/http"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/shkmv/httplib/router"
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+// LogField selects optional SlogLogger attributes.
+type LogField uint
+
+const (
+    LogLatency      LogField = 1 << iota // "latency"
+    LogBytes                             // "bytes": response body size
+    LogRemoteIP                          // "ip": the RealIP or peer address
+    LogHost                              // "host"
+    LogUserAgent                         // "user_agent"
+    LogReferer                           // "referer"
+    LogRoutePattern                      // "route": the matched pattern
+    LogRequestID                         // "request_id"
+    LogTraceID                           // "trace_id" from a W3C traceparent header
+
+    LogAllFields = LogLatency | LogBytes | LogRemoteIP | LogHost | LogUserAgent | LogReferer | LogRoutePattern | LogRequestID | LogTraceID
+)
+
+// LogConfig configures SlogLogger.
+type LogConfig struct {
+    Fields        LogField      // default LogAllFields
+    Headers       []string      // request headers to log under "headers"
+    RedactHeaders []string      // logged as "[REDACTED]"; default Authorization, Cookie, Proxy-Authorization
+    Level         slog.Level    // level of ordinary requests; default Info
+    SlowThreshold time.Duration // requests at least this slow are logged at SlowLevel; 0 disables
+    SlowLevel     slog.Level    // default Warn
+    SkipPaths     []string      // paths not logged unless the response is a 5xx; "/prefix/*" matches a subtree
+    SampleRate    float64       // fraction of fast 1xx-3xx responses logged, e.g. 0.01; 0 logs all
+    ErrorDetails  bool          // log the query string and every request header on 5xx; may capture secrets
+}
+
+// SlogLogger logs one "request" record per request to l (slog.Default() if
+// nil) with method, path and status plus the attributes selected by
+// cfg.Fields. Use a slog.JSONHandler for JSON output. Server errors are
+// logged at Error, slow requests at SlowLevel with slow=true, and the rest
+// at Level.
+//
+// To keep volume down at high QPS, SkipPaths drops health checks and the
+// like, and SampleRate logs only a share of fast, successful requests, each
+// with a sample_rate attribute for reweighting. Client errors, slow requests
+// and server errors are always logged, with every field. With ErrorDetails,
+// server errors also carry the query string and all request headers. Only
+// RedactHeaders are masked there, so API keys in other headers or tokens in
+// the query string end up in the log; enable it only where that is acceptable.
+func SlogLogger(l *slog.Logger, cfg LogConfig) router.Middleware {
+    if l == nil { l = slog.Default() }
+    if cfg.Fields == 0 { cfg.Fields = LogAllFields }
+    if cfg.RedactHeaders == nil { cfg.RedactHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"} }
+    if cfg.SlowLevel == 0 { cfg.SlowLevel = slog.LevelWarn }
+    redact := map[string]bool{}
+    for _, h := range cfg.RedactHeaders { redact[http.CanonicalHeaderKey(h)] = true }
+    return router.Named("SlogLogger", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            start := time.Now()
+            srw := &statusResponseWriter{ResponseWriter: w}
+            next.ServeHTTP(srw, r)
+            dur := time.Since(start)
+
+            status := srw.status
+            if status == 0 { status = http.StatusOK }
+            serverErr, slow := status >= 500, cfg.SlowThreshold > 0 && dur >= cfg.SlowThreshold
+            if !serverErr && skipPath(cfg.SkipPaths, r.URL.Path) { return }
+            sampled := cfg.SampleRate > 0 && cfg.SampleRate < 1 && status < 400 && !slow
+            if sampled && rand.Float64() >= cfg.SampleRate { return }
+
+            level := cfg.Level
+            attrs := make([]slog.Attr, 0, 16)
+            attrs = append(attrs, slog.String("method", r.Method), slog.String("path", r.URL.Path), slog.Int("status", status))
+            if slow {
+                level = cfg.SlowLevel
+                attrs = append(attrs, slog.Bool("slow", true))
+            }
+            if serverErr { level = slog.LevelError }
+            if !l.Enabled(r.Context(), level) { return }
+            if sampled { attrs = append(attrs, slog.Float64("sample_rate", cfg.SampleRate)) }
+            fields, headers := cfg.Fields, cfg.Headers
+            if serverErr { fields = LogAllFields }
+            if serverErr && cfg.ErrorDetails {
+                headers = nil
+                for name := range r.Header { headers = append(headers, name) }
+                sort.Strings(headers)
+                if r.URL.RawQuery != "" { attrs = append(attrs, slog.String("query", r.URL.RawQuery)) }
+            }
+            attrs = appendLogFields(attrs, fields, r, srw.bytes, dur)
+            if len(headers) > 0 {
+                hs := make([]any, 0, len(headers))
+                for _, name := range headers {
+                    name = http.CanonicalHeaderKey(name)
+                    v := r.Header.Get(name)
+                    if v == "" { continue }
+                    if redact[name] { v = "[REDACTED]" }
+                    hs = append(hs, slog.String(name, v))
+                }
+                if len(hs) > 0 { attrs = append(attrs, slog.Group("headers", hs...)) }
+            }
+            l.LogAttrs(r.Context(), level, "request", attrs...)
+        })
+    })
+}
+
+func appendLogFields(attrs []slog.Attr, f LogField, r *http.Request, bytes int, dur time.Duration) []slog.Attr {
+    ctx := r.Context()
+    if f&LogLatency != 0 { attrs = append(attrs, slog.Duration("latency", dur)) }
+    if f&LogBytes != 0 { attrs = append(attrs, slog.Int("bytes", bytes)) }
+    if f&LogRemoteIP != 0 {
+        ip := ctxutil.GetRealIP(ctx)
+        if ip == "" { ip, _, _ = net.SplitHostPort(r.RemoteAddr) }
+        attrs = append(attrs, slog.String("ip", ip))
+    }
+    if f&LogHost != 0 { attrs = append(attrs, slog.String("host", r.Host)) }
+    if f&LogUserAgent != 0 { attrs = append(attrs, slog.String("user_agent", r.UserAgent())) }
+    if f&LogReferer != 0 && r.Referer() != "" { attrs = append(attrs, slog.String("referer", r.Referer())) }
+    if p := ctxutil.GetRoutePattern(ctx); f&LogRoutePattern != 0 && p != "" { attrs = append(attrs, slog.String("route", p)) }
+    if id := ctxutil.GetReqID(ctx); f&LogRequestID != 0 && id != "" { attrs = append(attrs, slog.String("request_id", id)) }
+    if id := traceID(r.Header.Get("Traceparent")); f&LogTraceID != 0 && id != "" { attrs = append(attrs, slog.String("trace_id", id)) }
+    return attrs
+}
+
+// skipPath reports whether path is listed in skip, either exactly or under
+// a "/prefix/*" entry.
+func skipPath(skip []string, path string) bool {
+    for _, p := range skip {
+        if prefix, ok := strings.CutSuffix(p, "*"); ok {
+            if strings.HasPrefix(path, prefix) { return true }
+        } else if path == p {
+            return true
+        }
+    }
+    return false
+}
+
+// traceID extracts the trace ID from a W3C traceparent header,
+// "version-traceid-parentid-flags".
+func traceID(traceparent string) string {
+    parts := strings.Split(traceparent, "-")
+    if len(parts) < 4 || len(parts[1]) != 32 || strings.Trim(parts[1], "0") == "" { return "" }
+    return parts[1]
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+    "log"
+    "net/http"
+    "runtime"
+    "strings"
+    "sync/atomic"
+    "time"
+
+    "github.com/shkmv/httplib/router"
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+// SlowLog logs only requests that take longer than threshold, with method,
+// path, status, bytes, duration, and request ID. Faster requests are silent.
+func SlowLog(threshold time.Duration, l *log.Logger) router.Middleware {
+    if l == nil { l = log.Default() }
+    return router.Named("SlowLog", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            start := time.Now()
+            srw := &statusResponseWriter{ResponseWriter: w}
+            next.ServeHTTP(srw, r)
+            dur := time.Since(start)
+            if dur < threshold { return }
+            if srw.status == 0 { srw.status = http.StatusOK }
+            rid := ctxutil.GetReqID(r.Context())
+            l.Printf("slow request: %s %s %d %dB %s (threshold %s) req_id=%s", r.Method, r.URL.Path, srw.status, srw.bytes, dur.Truncate(time.Microsecond), threshold, rid)
+        })
+    })
+}
+
+// SlowStack logs a warning with the handler goroutine's stack when a request
+// is still running after threshold, showing where it is stuck, then lets it
+// complete and logs its final duration. Capturing the stack briefly stops
+// the world, so at most one is captured per threshold (and per second);
+// other warnings in that window are logged without a stack.
+func SlowStack(threshold time.Duration, l *log.Logger) router.Middleware {
+    if l == nil { l = log.Default() }
+    interval := max(threshold, time.Second)
+    var last atomic.Int64 // unix nanos of the last stack capture
+    stack := func(gid string) string {
+        now, prev := time.Now().UnixNano(), last.Load()
+        if (prev != 0 && now-prev < int64(interval)) || !last.CompareAndSwap(prev, now) {
+            return "(stack omitted: one was captured within the last " + interval.String() + ")"
+        }
+        return goroutineStack(gid)
+    }
+    return router.Named("SlowStack", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            start := time.Now()
+            gid := goroutineID()
+            warned := make(chan struct{})
+            timer := time.AfterFunc(threshold, func() {
+                l.Printf("slow request still running: %s %s after %s req_id=%s\n%s", r.Method, r.URL.Path, threshold, ctxutil.GetReqID(r.Context()), stack(gid))
+                close(warned)
+            })
+            defer func() {
+                if timer.Stop() { return }
+                <-warned
+                l.Printf("slow request finished: %s %s in %s req_id=%s", r.Method, r.URL.Path, time.Since(start).Truncate(time.Microsecond), ctxutil.GetReqID(r.Context()))
+            }()
+            next.ServeHTTP(w, r)
+        })
+    })
+}
+
+// goroutineID returns the ID of the calling goroutine from its stack header,
+// "goroutine 18 [running]:".
+func goroutineID() string {
+    var buf [64]byte
+    s := string(buf[:runtime.Stack(buf[:], false)])
+    s = strings.TrimPrefix(s, "goroutine ")
+    id, _, _ := strings.Cut(s, " ")
+    return id
+}
+
+// goroutineStack returns the current stack of goroutine id.
+func goroutineStack(id string) string {
+    buf := make([]byte, 1<<16)
+    for {
+        n := runtime.Stack(buf, true)
+        if n < len(buf) {
+            buf = buf[:n]
+            break
+        }
+        buf = make([]byte, 2*len(buf))
+    }
+    prefix := "goroutine " + id + " ["
+    for _, g := range strings.Split(string(buf), "\n\n") {
+        if strings.HasPrefix(g, prefix) { return g }
+    }
+    return "goroutine " + id + " not found"
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+    "net/http"
+
+    "github.com/shkmv/httplib/router"
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+// Tenant resolves the tenant for each request (from a header, subdomain, or
+// token claim) and stores it in the context for ctxutil.GetTenant and the
+// logger. resolve returns the tenant ID and whether it is valid: requests
+// without a tenant get 400, and requests with an unknown tenant get 403.
+// Register it before Logger so log entries carry the tenant.
+func Tenant(resolve func(*http.Request) (string, bool)) router.Middleware {
+    return router.Named("Tenant", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            tenant, ok := resolve(r)
+            if tenant == "" {
+                router.BadRequest(w, r, "tenant_required", "request does not identify a tenant", nil)
+                return
+            }
+            if !ok {
+                router.Forbidden(w, r, "tenant_invalid", "tenant is not recognized")
+                return
+            }
+            next.ServeHTTP(w, r.WithContext(ctxutil.WithTenant(r.Context(), tenant)))
+        })
+    })
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+    "net/http"
+    "time"
+
+    "github.com/shkmv/httplib/router"
+)
+
+// Throttle bounds the number of requests handled at once to maxInFlight.
+// Up to backlog further requests wait, each for at most backlogTimeout, for a
+// slot; requests beyond the backlog, or that time out waiting, get 503
+// "overloaded" straight away instead of piling up.
+func Throttle(maxInFlight, backlog int, backlogTimeout time.Duration) router.Middleware {
+    if maxInFlight <= 0 { panic("middleware: Throttle needs a positive maxInFlight") }
+    if backlog < 0 { backlog = 0 }
+    slots := make(chan struct{}, maxInFlight)
+    admitted := make(chan struct{}, maxInFlight+backlog) // in flight or waiting
+    return router.Named("Throttle", func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            select {
+            case admitted <- struct{}{}:
+            default:
+                router.RenderError(w, r, http.StatusServiceUnavailable, "overloaded", "server is at capacity, try again later", nil)
+                return
+            }
+            defer func() { <-admitted }()
+
+            select {
+            case slots <- struct{}{}:
+            default:
+                timer := time.NewTimer(backlogTimeout)
+                defer timer.Stop()
+                select {
+                case slots <- struct{}{}:
+                case <-timer.C:
+                    router.RenderError(w, r, http.StatusServiceUnavailable, "overloaded", "timed out waiting for capacity", nil)
+                    return
+                case <-r.Context().Done():
+                    return
+                }
+            }
+            defer func() { <-slots }()
+            next.ServeHTTP(w, r)
+        })
+    })
+}
@@ -5,23 +5,51 @@ import (
     "time"
 
     "github.com/shkmv/httplib/router"
+    "github.com/shkmv/httplib/router/ctxutil"
 )
 
-// Timeout sets a request timeout using http.TimeoutHandler.
+// Timeout sets a request timeout using http.TimeoutHandler. Routes in a group
+// with its own router.WithTimeout are left to that timeout instead.
 func Timeout(d time.Duration, msg string) router.Middleware {
     if msg == "" { msg = "request timeout" }
-    return func(next http.Handler) http.Handler { return http.TimeoutHandler(next, d, msg) }
+    return router.Named("Timeout", func(next http.Handler) http.Handler {
+        limited := http.TimeoutHandler(next, d, msg)
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if ctxutil.GetRouteTimeout(r.Context()) > 0 {
+                next.ServeHTTP(w, r)
+                return
+            }
+            limited.ServeHTTP(w, r)
+        })
+    })
+}
+
+// TimeoutExcept applies Timeout(d) to every request except those for which
+// skip returns true, e.g. long uploads or streaming downloads that
+// http.TimeoutHandler would otherwise buffer and cut off.
+func TimeoutExcept(d time.Duration, skip func(*http.Request) bool) router.Middleware {
+    timeout := Timeout(d, "")
+    return router.Named("TimeoutExcept", func(next http.Handler) http.Handler {
+        limited := timeout(next)
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if skip(r) {
+                next.ServeHTTP(w, r)
+                return
+            }
+            limited.ServeHTTP(w, r)
+        })
+    })
 }
 
 // NoCache sets headers to disable caching.
 func NoCache() router.Middleware {
-    return func(next http.Handler) http.Handler {
+    return router.Named("NoCache", func(next http.Handler) http.Handler {
         return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
             w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, max-age=0, private")
             w.Header().Set("Pragma", "no-cache")
             w.Header().Set("Expires", "0")
             next.ServeHTTP(w, r)
         })
-    }
+    })
 }
 
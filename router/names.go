@@ -0,0 +1,76 @@
+package router
+
+import (
+    "net/http"
+    "reflect"
+    "runtime"
+    "strings"
+)
+
+// NamedMiddleware is implemented by handlers that report the name of the
+// middleware that produced them. Built-in middlewares are wrapped with Named
+// so MiddlewareNames can list them for debugging, ordering checks, and
+// per-middleware configuration.
+type NamedMiddleware interface {
+    Name() string
+}
+
+// Named returns m with its handlers reporting name via NamedMiddleware.
+// Naming an already named middleware replaces its name.
+//
+// Named is never inlined, so every middleware it returns shares one code
+// pointer that middlewareName can recognize.
+//
+//go:noinline
+func Named(name string, m Middleware) Middleware {
+    return func(next http.Handler) http.Handler {
+        if _, ok := next.(nameProbe); ok { return &namedHandler{name: name} }
+        h := m(next)
+        if nh, ok := h.(*namedHandler); ok { h = nh.Handler }
+        return &namedHandler{Handler: h, name: name}
+    }
+}
+
+type namedHandler struct {
+    http.Handler
+    name string
+}
+
+func (h *namedHandler) Name() string { return h.name }
+
+// MiddlewareNames returns the names of the middlewares registered with Use
+// and With, outermost first. Middlewares not wrapped with Named are reported
+// by their function name, e.g. "main.auth".
+func (r *Router) MiddlewareNames() []string { return middlewareNames(r.middlewares) }
+
+// middleware is a registered Middleware with its name, resolved once by Use.
+type middleware struct {
+    fn   Middleware
+    name string
+}
+
+func middlewareNames(mws []middleware) []string {
+    names := make([]string, len(mws))
+    for i, m := range mws { names[i] = m.name }
+    return names
+}
+
+// nameProbe is passed by middlewareName to middlewares returned by Named,
+// which report their name without applying the middleware they wrap.
+type nameProbe struct{}
+
+func (nameProbe) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
+var namedPC = reflect.ValueOf(Named("", nil)).Pointer()
+
+// middlewareName returns the name set by Named, or else m's function name.
+// It never applies m.
+func middlewareName(m Middleware) string {
+    pc := reflect.ValueOf(m).Pointer()
+    if pc == namedPC { return m(nameProbe{}).(NamedMiddleware).Name() }
+    fn := runtime.FuncForPC(pc)
+    if fn == nil { return "unknown" }
+    name := fn.Name()
+    if i := strings.LastIndexByte(name, '/'); i >= 0 { name = name[i+1:] }
+    return strings.TrimSuffix(name, ".func1")
+}
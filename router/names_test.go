@@ -0,0 +1,38 @@
+package router_test
+
+import (
+    "io"
+    "net/http"
+    "reflect"
+    "testing"
+    "time"
+
+    "github.com/shkmv/httplib/router"
+    "github.com/shkmv/httplib/router/middleware"
+)
+
+func passthrough(next http.Handler) http.Handler { return next }
+
+func TestMiddlewareNames(t *testing.T) {
+    r := router.New()
+    r.Use(middleware.RequestID(), middleware.RealIP(), middleware.Logger(nil), middleware.Recoverer(nil))
+    api := r.With(middleware.Timeout(time.Second, ""), router.Named("auth", passthrough), passthrough)
+
+    want := []string{"RequestID", "RealIP", "Logger", "Recoverer"}
+    if got := r.MiddlewareNames(); !reflect.DeepEqual(got, want) { t.Fatalf("got %v, want %v", got, want) }
+    want = append(want, "Timeout", "auth", "router_test.passthrough")
+    if got := api.MiddlewareNames(); !reflect.DeepEqual(got, want) { t.Fatalf("got %v, want %v", got, want) }
+}
+
+func TestMiddlewareNamesDoNotApply(t *testing.T) {
+    applied := 0
+    counting := func(next http.Handler) http.Handler { applied++; return next }
+    r := router.New()
+    r.Use(counting, router.Named("counted", counting))
+    if applied != 0 { t.Fatalf("Use must not apply middlewares, got %d applications", applied) }
+    r.GetFunc("/a", func(http.ResponseWriter, *http.Request) {})
+    registered := applied
+    r.MiddlewareNames()
+    r.Print(io.Discard)
+    if applied != registered { t.Fatalf("listing names must not apply middlewares, got %d extra applications", applied-registered) }
+}
@@ -0,0 +1,122 @@
+package router
+
+import (
+    "encoding/xml"
+    "html/template"
+    "net/http"
+    "strconv"
+    "strings"
+)
+
+// Offers lists the representations Negotiate may render.
+type Offers struct {
+    JSON    bool                        // application/json, as {"data": v} like RenderData
+    XML     bool                        // application/xml or text/xml, v marshalled with encoding/xml
+    MsgPack func(v any) ([]byte, error) // application/msgpack, e.g. a msgpack library's Marshal
+    HTML    *template.Template          // text/html, the template executed with v
+}
+
+// Negotiate renders v with status in the representation the request's Accept
+// header prefers among offers, honouring q-values and preferring specific
+// media ranges over wildcards; ties go to the order JSON, XML, MsgPack, HTML.
+// Without an Accept header the first offer is used. When nothing acceptable
+// is offered it responds 406 with the error envelope.
+//  router.Negotiate(w, r, http.StatusOK, user, router.Offers{JSON: true, HTML: userPage})
+func Negotiate(w http.ResponseWriter, r *http.Request, status int, v any, offers Offers) {
+    var types []string
+    if offers.JSON { types = append(types, "application/json") }
+    if offers.XML { types = append(types, "application/xml", "text/xml") }
+    if offers.MsgPack != nil { types = append(types, "application/msgpack", "application/x-msgpack") }
+    if offers.HTML != nil { types = append(types, "text/html") }
+    w.Header().Add("Vary", "Accept")
+
+    switch ct := NegotiateContentType(r, types...); ct {
+    case "application/json":
+        RenderData(w, r, status, v)
+    case "application/xml", "text/xml":
+        b, err := xml.Marshal(v)
+        if err != nil {
+            InternalError(w, r, "internal_error", "could not encode XML response")
+            return
+        }
+        w.Header().Set("Content-Type", ct+"; charset=utf-8")
+        w.WriteHeader(status)
+        _, _ = w.Write([]byte(xml.Header))
+        _, _ = w.Write(b)
+    case "application/msgpack", "application/x-msgpack":
+        b, err := offers.MsgPack(v)
+        if err != nil {
+            InternalError(w, r, "internal_error", "could not encode MessagePack response")
+            return
+        }
+        w.Header().Set("Content-Type", ct)
+        w.WriteHeader(status)
+        _, _ = w.Write(b)
+    case "text/html":
+        var buf strings.Builder
+        if err := offers.HTML.Execute(&buf, v); err != nil {
+            InternalError(w, r, "internal_error", "could not render HTML response")
+            return
+        }
+        w.Header().Set("Content-Type", "text/html; charset=utf-8")
+        w.WriteHeader(status)
+        _, _ = w.Write([]byte(buf.String()))
+    default:
+        RenderError(w, r, http.StatusNotAcceptable, "not_acceptable", "no acceptable representation", map[string][]string{"available": types})
+    }
+}
+
+// NegotiateContentType returns the offered media type the request's Accept
+// header ranks highest, or "" if it accepts none of them. Each offer is
+// weighed by the most specific matching media range (type/subtype, then
+// type/*, then */*); ties go to the earlier offer. Without an Accept header
+// the first offer is returned.
+func NegotiateContentType(r *http.Request, offers ...string) string {
+    header := strings.Join(r.Header.Values("Accept"), ",")
+    if strings.TrimSpace(header) == "" {
+        if len(offers) == 0 { return "" }
+        return offers[0]
+    }
+    ranges := parseAccept(header)
+    best, bestQ := "", 0.0
+    for _, offer := range offers {
+        typ, sub, _ := strings.Cut(strings.ToLower(offer), "/")
+        q, spec := 0.0, -1
+        for _, ar := range ranges {
+            s := -1
+            switch {
+            case ar.typ == typ && ar.sub == sub:
+                s = 2
+            case ar.typ == typ && ar.sub == "*":
+                s = 1
+            case ar.typ == "*" && ar.sub == "*":
+                s = 0
+            }
+            if s > spec { q, spec = ar.q, s }
+        }
+        if q > bestQ { best, bestQ = offer, q }
+    }
+    return best
+}
+
+type acceptRange struct {
+    typ, sub string
+    q        float64
+}
+
+func parseAccept(header string) []acceptRange {
+    var out []acceptRange
+    for _, part := range strings.Split(header, ",") {
+        mt, params, _ := strings.Cut(part, ";")
+        typ, sub, ok := strings.Cut(strings.ToLower(strings.TrimSpace(mt)), "/")
+        if !ok { continue }
+        ar := acceptRange{typ: strings.TrimSpace(typ), sub: strings.TrimSpace(sub), q: 1}
+        for _, p := range strings.Split(params, ";") {
+            if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+                if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 1 { ar.q = f }
+            }
+        }
+        out = append(out, ar)
+    }
+    return out
+}
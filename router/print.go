@@ -0,0 +1,64 @@
+package router
+
+import (
+    "fmt"
+    "io"
+    "strings"
+)
+
+// Print writes the registered routes to w as a tree of path segments, each
+// route listing its method and middleware names (see MiddlewareNames), to
+// check at startup what is actually mounted:
+//  /api
+//    /users
+//      GET     RequestID, Logger
+//      POST    RequestID, Logger, auth
+//      /{id}
+//        GET     RequestID, Logger
+func (r *Router) Print(w io.Writer) {
+    root := &printNode{}
+    for _, e := range r.entries() {
+        n := root
+        trimmed := strings.Trim(e.Pattern, "/")
+        if trimmed != "" {
+            segs := strings.Split(trimmed, "/")
+            if strings.HasSuffix(e.Pattern, "/") { segs[len(segs)-1] += "/" }
+            for _, seg := range segs { n = n.child(seg) }
+        }
+        n.routes = append(n.routes, e)
+    }
+    if len(root.routes) > 0 {
+        fmt.Fprintln(w, "/")
+        root.printRoutes(w, 1)
+    }
+    for _, c := range root.children { c.print(w, 0) }
+}
+
+type printNode struct {
+    seg      string
+    children []*printNode
+    routes   []routeEntry
+}
+
+// child returns the child for seg, creating it in registration order.
+func (n *printNode) child(seg string) *printNode {
+    for _, c := range n.children {
+        if c.seg == seg { return c }
+    }
+    c := &printNode{seg: seg}
+    n.children = append(n.children, c)
+    return c
+}
+
+func (n *printNode) print(w io.Writer, depth int) {
+    fmt.Fprintf(w, "%s/%s\n", strings.Repeat("  ", depth), n.seg)
+    n.printRoutes(w, depth+1)
+    for _, c := range n.children { c.print(w, depth+1) }
+}
+
+func (n *printNode) printRoutes(w io.Writer, depth int) {
+    indent := strings.Repeat("  ", depth)
+    for _, e := range n.routes {
+        fmt.Fprintln(w, strings.TrimRight(fmt.Sprintf("%s%-7s %s", indent, e.Method, strings.Join(e.mws, ", ")), " "))
+    }
+}
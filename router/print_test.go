@@ -0,0 +1,47 @@
+package router
+
+import (
+    "bytes"
+    "net/http"
+    "testing"
+)
+
+func TestPrint(t *testing.T) {
+    named := func(name string) Middleware {
+        return Named(name, func(next http.Handler) http.Handler { return next })
+    }
+    ok := func(w http.ResponseWriter, req *http.Request) {}
+
+    admin := New()
+    admin.Use(named("AdminOnly"))
+    admin.GetFunc("/stats", ok)
+
+    r := New()
+    r.GetFunc("/", ok)
+    r.Use(named("RequestID"))
+    r.GetFunc("/ping", ok)
+    r.Route("/api", func(api *Router) {
+        api.GetFunc("/users", ok)
+        api.With(named("Auth")).PostFunc("/users", ok)
+        api.GetFunc("/users/{id}", ok)
+    })
+    r.Mount("/admin", admin)
+
+    var buf bytes.Buffer
+    r.Print(&buf)
+    want := `/
+  GET
+/ping
+  GET     RequestID
+/api
+  /users
+    GET     RequestID
+    POST    RequestID, Auth
+    /{id}
+      GET     RequestID
+/admin
+  /stats
+    GET     RequestID, AdminOnly
+`
+    if buf.String() != want { t.Fatalf("unexpected tree:\n%s\nwant:\n%s", buf.String(), want) }
+}
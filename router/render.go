@@ -1,8 +1,13 @@
 package router
 
 import (
+    "bytes"
+    "compress/gzip"
     "encoding/json"
+    "io"
     "net/http"
+    "strconv"
+    "strings"
     "github.com/shkmv/httplib/router/ctxutil"
 )
 
@@ -21,6 +26,23 @@ type ErrorEnvelope struct {
 	Details   any    `json:"details,omitempty"`
 }
 
+// ItemResult is the outcome of a single item in a batch operation.
+// Status is the item's own HTTP status; Error and Message describe failures.
+type ItemResult struct {
+	ID      string `json:"id,omitempty"`
+	Status  int    `json:"status"`
+	Data    any    `json:"data,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// BatchResult is the payload rendered by RenderMulti under {"data": ...}.
+type BatchResult struct {
+	Results   []ItemResult `json:"results"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+}
+
 // RenderData writes a JSON success response with the given status and data under {"data": ...}.
 func RenderData(w http.ResponseWriter, r *http.Request, status int, v any) {
 	w.Header().Set("Content-Type", contentTypeJSON)
@@ -44,6 +66,94 @@ func RenderNoContent(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// RenderMulti writes the per-item outcome of a batch operation as
+// {"data": {"results": [...], "succeeded": n, "failed": m}}. The response is
+// 200 when every item has a 2xx status and 207 Multi-Status otherwise.
+func RenderMulti(w http.ResponseWriter, r *http.Request, results []ItemResult) {
+    batch := BatchResult{Results: results}
+    if batch.Results == nil { batch.Results = []ItemResult{} }
+    for _, res := range results {
+        if res.Status >= 200 && res.Status < 300 { batch.Succeeded++ } else { batch.Failed++ }
+    }
+    status := http.StatusOK
+    if batch.Failed > 0 { status = http.StatusMultiStatus }
+    RenderData(w, r, status, batch)
+}
+
+// RenderRedirectJSON tells XHR clients (X-Requested-With: XMLHttpRequest) where
+// to go with a 200 {"data": {"redirect": target}} body, since they cannot
+// meaningfully follow a 3xx. Other clients get a regular 302 redirect.
+func RenderRedirectJSON(w http.ResponseWriter, r *http.Request, target string) {
+    if !strings.EqualFold(r.Header.Get("X-Requested-With"), "XMLHttpRequest") {
+        http.Redirect(w, r, target, http.StatusFound)
+        return
+    }
+    RenderOK(w, r, map[string]string{"redirect": target})
+}
+
+// RenderPrecompressed serves a static JSON document that was gzip-compressed
+// ahead of time (config, feature flags, ...), avoiding re-encoding on every
+// request. Clients accepting gzip get the bytes as-is with
+// Content-Encoding: gzip; others get them decompressed on the fly. A non-empty
+// etag is set as the ETag header and answered with 304 on If-None-Match.
+func RenderPrecompressed(w http.ResponseWriter, r *http.Request, gzipped []byte, etag string) {
+    h := w.Header()
+    h.Set("Content-Type", contentTypeJSON)
+    h.Add("Vary", "Accept-Encoding")
+    if etag != "" {
+        h.Set("ETag", etag)
+        if ETagMatches(r.Header.Get("If-None-Match"), etag) {
+            w.WriteHeader(http.StatusNotModified)
+            return
+        }
+    }
+    if acceptsGzip(r) {
+        h.Set("Content-Encoding", "gzip")
+        h.Set("Content-Length", strconv.Itoa(len(gzipped)))
+        w.WriteHeader(http.StatusOK)
+        _, _ = w.Write(gzipped)
+        return
+    }
+    zr, err := gzip.NewReader(bytes.NewReader(gzipped))
+    if err != nil {
+        h.Del("Vary")
+        InternalError(w, r, "internal_error", "invalid precompressed payload")
+        return
+    }
+    defer zr.Close()
+    w.WriteHeader(http.StatusOK)
+    _, _ = io.Copy(w, zr)
+}
+
+// ETagMatches reports whether an If-None-Match header lists etag, or is "*",
+// using the weak comparison If-None-Match requires: W/"a" matches "a".
+// Entity tags are compared whole, so "v1" does not match "v10".
+func ETagMatches(header, etag string) bool {
+    want := strings.TrimPrefix(etag, "W/")
+    for header = strings.TrimLeft(header, " \t,"); header != ""; header = strings.TrimLeft(header, " \t,") {
+        if header[0] == '*' { return true }
+        header = strings.TrimPrefix(header, "W/")
+        if header == "" || header[0] != '"' { return false }
+        end := strings.IndexByte(header[1:], '"')
+        if end < 0 { return false }
+        if header[:end+2] == want { return true }
+        header = header[end+2:]
+    }
+    return false
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip.
+func acceptsGzip(r *http.Request) bool {
+    for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+        name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+        if !strings.EqualFold(strings.TrimSpace(name), "gzip") { continue }
+        q := 1.0
+        if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok { q, _ = strconv.ParseFloat(v, 64) }
+        return q > 0
+    }
+    return false
+}
+
 // RenderError writes a JSON error response with a standard shape.
 // code is a machine-readable error identifier; message is a human-friendly description.
 // details can be any additional payload (validation errors, fields, etc.).
@@ -86,3 +196,20 @@ func UnprocessableEntity(w http.ResponseWriter, r *http.Request, code, message s
 func InternalError(w http.ResponseWriter, r *http.Request, code, message string) {
 	RenderError(w, r, http.StatusInternalServerError, code, message, nil)
 }
+
+// JSONHandler adapts a typed handler returning (status, value, error) into an
+// http.Handler. On success the value is rendered via RenderData with the given
+// status (200 when status is 0); a non-nil error is logged with the request's
+// logger (see ctxutil.Logger) and renders a generic 500 error envelope.
+func JSONHandler[T any](fn func(*http.Request) (int, T, error)) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        status, v, err := fn(r)
+        if err != nil {
+            ctxutil.Logger(r.Context()).ErrorContext(r.Context(), "handler failed", "error", err)
+            InternalError(w, r, "internal_error", http.StatusText(http.StatusInternalServerError))
+            return
+        }
+        if status == 0 { status = http.StatusOK }
+        RenderData(w, r, status, v)
+    })
+}
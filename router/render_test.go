@@ -1,11 +1,17 @@
 package router_test
 
 import (
+    "bytes"
+    "compress/gzip"
     "encoding/json"
+    "errors"
+    "html/template"
+    "io"
     "net/http"
     "net/http/httptest"
     "strings"
     "testing"
+    "time"
     "github.com/shkmv/httplib/router"
     rmid "github.com/shkmv/httplib/router/middleware"
 )
@@ -55,3 +61,210 @@ func TestRenderError_WithReqID(t *testing.T) {
         t.Fatalf("unexpected error envelope: %+v", got)
     }
 }
+
+func TestJSONHandler(t *testing.T) {
+    type user struct {
+        ID   int    `json:"id"`
+        Name string `json:"name"`
+    }
+    r := router.New()
+    r.Get("/user", router.JSONHandler(func(*http.Request) (int, user, error) {
+        return http.StatusCreated, user{ID: 1, Name: "John"}, nil
+    }))
+    r.Get("/fail", router.JSONHandler(func(*http.Request) (int, *user, error) {
+        return 0, nil, errors.New("db down")
+    }))
+
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/user", nil))
+    if rr.Code != http.StatusCreated {
+        t.Fatalf("status: %d", rr.Code)
+    }
+    var got router.DataEnvelope[user]
+    if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+        t.Fatalf("json: %v", err)
+    }
+    if got.Data.ID != 1 || got.Data.Name != "John" {
+        t.Fatalf("unexpected data: %+v", got)
+    }
+
+    rr2 := httptest.NewRecorder()
+    r.ServeHTTP(rr2, httptest.NewRequest(http.MethodGet, "/fail", nil))
+    if rr2.Code != http.StatusInternalServerError {
+        t.Fatalf("expected 500, got %d", rr2.Code)
+    }
+    if strings.Contains(rr2.Body.String(), "db down") {
+        t.Fatalf("error text leaked to the client: %s", rr2.Body.String())
+    }
+    var env router.ErrorEnvelope
+    if err := json.Unmarshal(rr2.Body.Bytes(), &env); err != nil {
+        t.Fatalf("json: %v", err)
+    }
+    if env.Error != "internal_error" || env.Message != http.StatusText(http.StatusInternalServerError) {
+        t.Fatalf("unexpected error envelope: %+v", env)
+    }
+}
+
+func TestRenderMulti(t *testing.T) {
+    r := router.New()
+    r.PostFunc("/batch", func(w http.ResponseWriter, req *http.Request) {
+        router.RenderMulti(w, req, []router.ItemResult{
+            {ID: "1", Status: http.StatusCreated, Data: map[string]any{"name": "a"}},
+            {ID: "2", Status: http.StatusConflict, Error: "duplicate", Message: "already exists"},
+        })
+    })
+
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/batch", nil))
+    if rr.Code != http.StatusMultiStatus {
+        t.Fatalf("expected 207, got %d", rr.Code)
+    }
+    var got router.DataEnvelope[router.BatchResult]
+    if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+        t.Fatalf("json: %v", err)
+    }
+    res := got.Data.Results
+    if len(res) != 2 || got.Data.Succeeded != 1 || got.Data.Failed != 1 {
+        t.Fatalf("unexpected batch: %+v", got.Data)
+    }
+    if res[0].ID != "1" || res[0].Status != http.StatusCreated || res[1].Status != http.StatusConflict || res[1].Error != "duplicate" {
+        t.Fatalf("unexpected item results: %+v", res)
+    }
+}
+
+func TestRenderRedirectJSON(t *testing.T) {
+    r := router.New()
+    r.GetFunc("/login", func(w http.ResponseWriter, req *http.Request) {
+        router.RenderRedirectJSON(w, req, "/dashboard")
+    })
+
+    req := httptest.NewRequest(http.MethodGet, "/login", nil)
+    req.Header.Set("X-Requested-With", "XMLHttpRequest")
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, req)
+    if rr.Code != http.StatusOK {
+        t.Fatalf("expected 200 for XHR, got %d", rr.Code)
+    }
+    var got router.DataEnvelope[map[string]string]
+    if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+        t.Fatalf("json: %v", err)
+    }
+    if got.Data["redirect"] != "/dashboard" {
+        t.Fatalf("unexpected redirect body: %+v", got)
+    }
+
+    rr2 := httptest.NewRecorder()
+    r.ServeHTTP(rr2, httptest.NewRequest(http.MethodGet, "/login", nil))
+    if rr2.Code != http.StatusFound || rr2.Header().Get("Location") != "/dashboard" {
+        t.Fatalf("expected 302 to /dashboard, got %d %q", rr2.Code, rr2.Header().Get("Location"))
+    }
+}
+
+func TestRenderPrecompressed(t *testing.T) {
+    var gz bytes.Buffer
+    zw := gzip.NewWriter(&gz)
+    io.WriteString(zw, `{"data":{"flag":true}}`)
+    zw.Close()
+    payload := gz.Bytes()
+
+    r := router.New()
+    r.GetFunc("/flags", func(w http.ResponseWriter, req *http.Request) {
+        router.RenderPrecompressed(w, req, payload, `"v1"`)
+    })
+
+    req := httptest.NewRequest(http.MethodGet, "/flags", nil)
+    req.Header.Set("Accept-Encoding", "br, gzip")
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, req)
+    if rr.Header().Get("Content-Encoding") != "gzip" || !bytes.Equal(rr.Body.Bytes(), payload) {
+        t.Fatalf("expected raw gzip bytes, got encoding %q", rr.Header().Get("Content-Encoding"))
+    }
+    if rr.Header().Get("ETag") != `"v1"` {
+        t.Fatalf("missing etag: %v", rr.Header())
+    }
+    for inm, code := range map[string]int{`"v1"`: http.StatusNotModified, `"v0", W/"v1"`: http.StatusNotModified, `*`: http.StatusNotModified, `"v10"`: http.StatusOK, `"xv1x"`: http.StatusOK} {
+        req := httptest.NewRequest(http.MethodGet, "/flags", nil)
+        req.Header.Set("If-None-Match", inm)
+        rr := httptest.NewRecorder()
+        r.ServeHTTP(rr, req)
+        if rr.Code != code { t.Fatalf("If-None-Match %s: expected %d, got %d", inm, code, rr.Code) }
+    }
+
+    rr2 := httptest.NewRecorder()
+    r.ServeHTTP(rr2, httptest.NewRequest(http.MethodGet, "/flags", nil))
+    if rr2.Header().Get("Content-Encoding") != "" || rr2.Body.String() != `{"data":{"flag":true}}` {
+        t.Fatalf("expected decompressed json, got %q", rr2.Body.String())
+    }
+}
+
+func TestNegotiate(t *testing.T) {
+    type user struct {
+        Name string `json:"name" xml:"name"`
+    }
+    page := template.Must(template.New("user").Parse(`<h1>{{.Name}}</h1>`))
+    offers := router.Offers{JSON: true, XML: true, HTML: page, MsgPack: func(v any) ([]byte, error) { return []byte{0x81}, nil }}
+    do := func(accept string, o router.Offers) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodGet, "/", nil)
+        if accept != "" { req.Header.Set("Accept", accept) }
+        rec := httptest.NewRecorder()
+        router.Negotiate(rec, req, http.StatusOK, user{Name: "<ann>"}, o)
+        return rec
+    }
+    cases := []struct {
+        accept, contentType, body string
+    }{
+        {"", "application/json; charset=utf-8", `{"data":{"name":"\u003cann\u003e"}}` + "\n"},
+        {"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "text/html; charset=utf-8", "<h1>&lt;ann&gt;</h1>"},
+        {"application/json;q=0.5, text/xml", "text/xml; charset=utf-8", `<?xml version="1.0" encoding="UTF-8"?>` + "\n<user><name>&lt;ann&gt;</name></user>"},
+        {"application/*;q=0.2, application/msgpack", "application/msgpack", "\x81"},
+        {"text/*, text/html;q=0", "text/xml; charset=utf-8", ""},
+    }
+    for _, c := range cases {
+        rec := do(c.accept, offers)
+        if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != c.contentType || rec.Header().Get("Vary") != "Accept" {
+            t.Fatalf("Accept %q: got %d %q", c.accept, rec.Code, rec.Header().Get("Content-Type"))
+        }
+        if c.body != "" && rec.Body.String() != c.body { t.Fatalf("Accept %q: unexpected body %q", c.accept, rec.Body.String()) }
+    }
+    rec := do("image/png", router.Offers{JSON: true})
+    if rec.Code != http.StatusNotAcceptable || !strings.Contains(rec.Body.String(), `"not_acceptable"`) { t.Fatalf("expected 406, got %d %q", rec.Code, rec.Body.String()) }
+}
+
+func TestRenderBytes(t *testing.T) {
+    updated := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+    body := []byte("id,name\n1,ann\n2,bob\n")
+    c := router.Content{Type: "text/csv", ModTime: updated, ETag: `"v7"`, Filename: "users.csv", CacheControl: "private, max-age=60"}
+    do := func(hdr ...string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodGet, "/export", nil)
+        for i := 0; i+1 < len(hdr); i += 2 { req.Header.Set(hdr[i], hdr[i+1]) }
+        rec := httptest.NewRecorder()
+        router.RenderBytes(rec, req, c, body)
+        return rec
+    }
+
+    rec := do()
+    h := rec.Header()
+    if rec.Code != http.StatusOK || rec.Body.String() != string(body) || h.Get("Content-Type") != "text/csv" || h.Get("ETag") != `"v7"` {
+        t.Fatalf("unexpected response %d %q %v", rec.Code, rec.Body.String(), h)
+    }
+    if h.Get("Last-Modified") != updated.Format(http.TimeFormat) || h.Get("Content-Disposition") != `attachment; filename=users.csv` || h.Get("Accept-Ranges") != "bytes" {
+        t.Fatalf("unexpected headers %v", h)
+    }
+    cases := []struct {
+        name   string
+        hdr    []string
+        status int
+        body   string
+    }{
+        {"etag match", []string{"If-None-Match", `"v7"`}, http.StatusNotModified, ""},
+        {"not modified since", []string{"If-Modified-Since", updated.Format(http.TimeFormat)}, http.StatusNotModified, ""},
+        {"precondition", []string{"If-Match", `"v6"`}, http.StatusPreconditionFailed, ""},
+        {"range", []string{"Range", "bytes=8-12"}, http.StatusPartialContent, "1,ann"},
+        {"stale if-range", []string{"Range", "bytes=8-12", "If-Range", `"v6"`}, http.StatusOK, string(body)},
+        {"unsatisfiable", []string{"Range", "bytes=100-"}, http.StatusRequestedRangeNotSatisfiable, ""},
+    }
+    for _, tc := range cases {
+        rec := do(tc.hdr...)
+        if rec.Code != tc.status || (tc.body != "" && rec.Body.String() != tc.body) { t.Fatalf("%s: got %d %q", tc.name, rec.Code, rec.Body.String()) }
+    }
+}
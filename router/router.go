@@ -1,9 +1,15 @@
 package router
 
 import (
+    "context"
+    "fmt"
+    "mime"
     "net/http"
     "path"
     "strings"
+    "time"
+
+    "github.com/shkmv/httplib/router/ctxutil"
 )
 
 // Middleware defines a function to process middleware.
@@ -14,32 +20,118 @@ type Middleware func(http.Handler) http.Handler
 //
 // It shares a single underlying *http.ServeMux across grouped/nested routers
 // and implements http.Handler for easy use with http.Server.
+//
+// Patterns use the Go 1.22 ServeMux syntax. The main module must declare
+// go 1.22 or later and must not set GODEBUG=httpmuxgo121=1; otherwise the mux
+// matches "{id}" literally and parameterised routes never match.
 type Router struct {
     mux         *http.ServeMux
     base        string
-    middlewares []Middleware
+    middlewares []middleware
+    hideMethods bool
+    timeout     time.Duration
+    foldCase    bool
+    unmatched   bool // run middlewares for requests that match no route
+    routes      *routeTable
 }
 
-// New creates a new root Router.
-func New() *Router {
-    return &Router{mux: http.NewServeMux()}
+// Option configures a Router or a route group created with Route or Mount.
+type Option func(*Router)
+
+// HideMethods makes a subtree answer 404 instead of 405 when a path exists but
+// the request method is not registered, hiding which endpoints exist.
+func HideMethods() Option { return func(r *Router) { r.hideMethods = true } }
+
+// WithTimeout gives routes in a group their own request timeout, e.g. for
+// report endpoints that legitimately outlast CRUD ones:
+//  api.Route("/reports", fn, router.WithTimeout(30*time.Second))
+// The handler is cut off with 503 after d, as with middleware.Timeout, and a
+// Timeout middleware installed on the router steps aside for these routes.
+func WithTimeout(d time.Duration) Option { return func(r *Router) { r.timeout = d } }
+
+// WithCaseInsensitivePaths makes a root Router match paths regardless of
+// case, e.g. for APIs migrating from systems where "/Users" and "/users" are
+// the same resource. Incoming paths are lowercased before matching, so
+// patterns must be registered in lowercase. Path values keep the case the
+// client sent; handlers, and routers mounted below, see the lowercased
+// URL.Path. It only has an effect when passed to New.
+func WithCaseInsensitivePaths() Option { return func(r *Router) { r.foldCase = true } }
+
+// WithUnmatchedMiddlewares makes a root Router run its middlewares for
+// requests that match no route, before the default 404, as it always does
+// when a NotFound handler is set. Short-circuiting middlewares such as
+// middleware.Favicon then work without a route of their own, at the cost of
+// every stray request going through the whole chain.
+func WithUnmatchedMiddlewares() Option { return func(r *Router) { r.unmatched = true } }
+
+// New creates a new root Router configured by opts.
+func New(opts ...Option) *Router {
+    r := &Router{mux: http.NewServeMux(), routes: newRouteTable()}
+    for _, opt := range opts { opt(r) }
+    return r
 }
 
 // ServeHTTP satisfies http.Handler by delegating to the underlying mux.
+// Requests matching no route go to the NotFound handler, if one is set, behind
+// this router's middlewares; otherwise they get the mux's plain 404 without
+// them, unless WithUnmatchedMiddlewares is set.
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+    if req.Method == http.MethodConnect && req.URL.Path == "" {
+        // Authority-form CONNECT ("CONNECT host:443") carries no path.
+        req = req.Clone(req.Context())
+        req.URL.Path = "/"
+    }
+    if r.foldCase {
+        r.serveFolded(w, req)
+        return
+    }
+    if nf := r.notFoundHandler(); nf != nil {
+        if _, pattern := r.mux.Handler(req); pattern == "" {
+            r.chain(nf).ServeHTTP(w, req)
+            return
+        }
+    }
     r.mux.ServeHTTP(w, req)
 }
 
+// NotFound sets the handler for requests that match no route, replacing the
+// mux's plain-text 404. It runs behind the middlewares of the router that
+// serves the request and applies to every router sharing this one's routes.
+//  r.NotFound(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+//      router.NotFound(w, req, "not_found", "no such endpoint")
+//  }))
+func (r *Router) NotFound(h http.Handler) {
+    r.routes.mu.Lock(); defer r.routes.mu.Unlock()
+    r.routes.notFound = h
+}
+
+// MethodNotAllowed sets the handler for requests whose path is registered but
+// whose method is not. The Allow header is set before h runs. Routes under
+// HideMethods use the NotFound handler instead.
+func (r *Router) MethodNotAllowed(h http.Handler) {
+    r.routes.mu.Lock(); defer r.routes.mu.Unlock()
+    r.routes.notAllowed = h
+}
+
+// internal: the handler to run behind this router's middlewares for requests
+// that match no route, or nil to leave them to the mux unchained.
+func (r *Router) notFoundHandler() http.Handler {
+    if nf, _ := r.routes.errorHandlers(); nf != nil { return nf }
+    if r.unmatched { return http.NotFoundHandler() }
+    return nil
+}
+
 // Use appends middlewares to this router. Middlewares are applied in the
 // order they were added, outermost to innermost.
 func (r *Router) Use(mws ...Middleware) {
-    r.middlewares = append(r.middlewares, mws...)
+    for _, m := range mws { r.middlewares = append(r.middlewares, middleware{m, middlewareName(m)}) }
 }
 
 // With returns a shallow copy of the router with additional middlewares appended.
 func (r *Router) With(mws ...Middleware) *Router {
     clone := *r
-    clone.middlewares = append(append([]Middleware{}, r.middlewares...), mws...)
+    clone.middlewares = append([]middleware{}, r.middlewares...)
+    clone.Use(mws...)
     return &clone
 }
 
@@ -48,27 +140,50 @@ func (r *Router) With(mws ...Middleware) *Router {
 //  r.Route("/api", func(api *router.Router) {
 //      api.Get("/ping", handler)
 //  })
-func (r *Router) Route(prefix string, fn func(*Router)) {
+// Options such as HideMethods and WithTimeout apply to routes registered
+// within the group.
+func (r *Router) Route(prefix string, fn func(*Router), opts ...Option) {
     sub := r.withPrefix(prefix)
+    for _, opt := range opts { opt(sub) }
     fn(sub)
 }
 
 // Group is an alias for Route.
-func (r *Router) Group(prefix string, fn func(*Router)) { r.Route(prefix, fn) }
+func (r *Router) Group(prefix string, fn func(*Router), opts ...Option) { r.Route(prefix, fn, opts...) }
+
+// Scope runs fn with a scoped copy of the router at the same path. Middlewares
+// added with Use inside fn apply only to routes registered inside fn, after
+// the parent's middlewares:
+//  r.Scope(func(g *router.Router) {
+//      g.Use(auth)
+//      g.GetFunc("/me", me) // auth runs here
+//  })
+//  r.GetFunc("/health", health) // but not here
+// As everywhere, Use only affects routes registered after it.
+func (r *Router) Scope(fn func(*Router), opts ...Option) { r.Route("", fn, opts...) }
 
 // Mount mounts an http.Handler (another Router or any handler) under a prefix.
 // If the prefix does not end in a slash, requests to the exact prefix are
 // rewritten to "/" for the mounted handler. For all other requests, the prefix
 // is stripped before being passed to the mounted handler.
-func (r *Router) Mount(prefix string, h http.Handler) {
+// Options such as HideMethods and WithTimeout apply to everything served by h.
+func (r *Router) Mount(prefix string, h http.Handler, opts ...Option) {
+    cfg := *r
+    for _, opt := range opts { opt(&cfg) }
     full := r.join(prefix)
+    if sub, ok := h.(*Router); ok {
+        r.record("*", full, sub)
+    } else {
+        r.record("*", strings.TrimRight(full, "/")+"/*", nil)
+    }
+    if cfg.hideMethods { h = hideMethodNotAllowed(h) }
 
     // If the path doesn't have a trailing slash, add a handler for the
     // exact path, rewriting it to "/". This is not needed if the path
     // already has a trailing slash, as the subtree handler will catch it.
     if !strings.HasSuffix(full, "/") {
-        r.mux.Handle(full, r.wrap(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-            req2 := req.Clone(req.Context())
+        r.handle(full, "mount "+full, cfg.wrap(full, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+            req2 := req.Clone(withMountPrefix(req.Context(), full))
             req2.URL.Path = "/"
             h.ServeHTTP(w, req2)
         })))
@@ -81,13 +196,31 @@ func (r *Router) Mount(prefix string, h http.Handler) {
     }
     // The prefix for stripping should not have a trailing slash.
     stripPrefix := strings.TrimRight(full, "/")
-    r.mux.Handle(subtree, r.wrap(http.StripPrefix(stripPrefix, h)))
+    stripped := http.StripPrefix(stripPrefix, h)
+    r.handle(subtree, "mount "+full, cfg.wrap(subtree, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        stripped.ServeHTTP(w, req.WithContext(withMountPrefix(req.Context(), stripPrefix)))
+    })))
+}
+
+// SubApp builds a fully isolated Router and mounts it under prefix. Unlike
+// Route, which registers into the parent's shared mux and inherits its
+// middlewares, the sub-app has its own mux and middleware stack: the parent's
+// middlewares do not run for it, and its own (including a catch-all "/"
+// handler acting as NotFound) apply only within prefix.
+func (r *Router) SubApp(prefix string, build func(*Router)) {
+    app := New()
+    build(app)
+    isolated := *r
+    isolated.middlewares = nil
+    isolated.Mount(prefix, app)
 }
 
 // Handle registers a handler for any HTTP method at the full pattern.
 // Pattern is joined with any existing group prefix.
 func (r *Router) Handle(pattern string, h http.Handler) {
-    r.mux.Handle(r.join(pattern), r.wrap(h))
+    full := r.join(pattern)
+    r.handle(full, "* "+full, r.wrap(full, h))
+    r.record("*", full, nil)
 }
 
 // HandleFunc registers a handler func for any HTTP method.
@@ -95,20 +228,89 @@ func (r *Router) HandleFunc(pattern string, h func(http.ResponseWriter, *http.Re
     r.Handle(pattern, http.HandlerFunc(h))
 }
 
-// Method registers a handler for a specific HTTP method. If the request
-// method does not match, it responds with 405 Method Not Allowed.
+// Method registers a handler for a specific HTTP method. Several methods may
+// be registered on one pattern; requests for any other method get 405 Method
+// Not Allowed with an Allow header listing the registered ones, or the
+// MethodNotAllowed handler if set. Unless an OPTIONS handler is registered,
+// OPTIONS requests get 204 No Content with that Allow header plus OPTIONS.
 func (r *Router) Method(method, pattern string, h http.Handler) {
     method = strings.ToUpper(method)
-    r.mux.Handle(r.join(pattern), r.wrap(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-        if req.Method != method {
-            w.Header().Set("Allow", method)
-            http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+    full := r.join(pattern)
+    mr, isNew := r.routes.add(full, method, r.chain(h))
+    r.record(method, full, nil)
+    if !isNew { return }
+
+    // The first registration for a pattern owns the mux entry and decides how
+    // unregistered methods are answered.
+    routes := r.routes
+    hide := r.hideMethods
+    mr.fallback = r.chain(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        notFound, notAllowed := routes.errorHandlers()
+        if hide {
+            if notFound != nil {
+                notFound.ServeHTTP(w, req)
+                return
+            }
+            http.NotFound(w, req)
+            return
+        }
+        if req.Method == http.MethodOptions {
+            w.Header().Set("Allow", routes.allowed(full, true))
+            w.WriteHeader(http.StatusNoContent)
             return
         }
+        w.Header().Set("Allow", routes.allowed(full, false))
+        if notAllowed != nil {
+            notAllowed.ServeHTTP(w, req)
+            return
+        }
+        http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+    }))
+    r.handle(full, method+" "+full, withPattern(full, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        h, mr := routes.lookup(full, req.Method)
+        if h == nil { h = mr.fallback }
         h.ServeHTTP(w, req)
     })))
 }
 
+// Match registers h for each of methods on pattern, like calling Method
+// once per method.
+func (r *Router) Match(methods []string, pattern string, h http.Handler) {
+    for _, m := range methods { r.Method(m, pattern, h) }
+}
+
+// anyMethods are the methods registered by Any.
+var anyMethods = []string{
+    http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+    http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+}
+
+// Any registers h for every standard HTTP method on pattern. Unlike Handle,
+// the routes are listed per method by Routes, and other methods get 405.
+func (r *Router) Any(pattern string, h http.Handler) { r.Match(anyMethods, pattern, h) }
+
+// Accept registers a handler for pattern that dispatches on the request
+// Content-Type media type (parameters such as charset or boundary are ignored),
+// e.g. to treat JSON and multipart uploads differently on one path.
+// Requests whose Content-Type matches no key get 415 Unsupported Media Type.
+func (r *Router) Accept(pattern string, byContentType map[string]http.Handler) {
+    handlers := make(map[string]http.Handler, len(byContentType))
+    for ct, h := range byContentType {
+        mt, _, err := mime.ParseMediaType(ct)
+        if err != nil { mt = strings.ToLower(strings.TrimSpace(ct)) }
+        handlers[mt] = h
+    }
+    r.Handle(pattern, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        mt, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+        h, ok := handlers[mt]
+        if !ok {
+            http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+            return
+        }
+        h.ServeHTTP(w, req)
+    }))
+}
+
 // Convenience helpers for common HTTP methods.
 func (r *Router) Get(pattern string, h http.Handler)               { r.Method(http.MethodGet, pattern, h) }
 func (r *Router) GetFunc(pattern string, h func(http.ResponseWriter, *http.Request)) {
@@ -138,11 +340,91 @@ func (r *Router) Head(pattern string, h http.Handler)               { r.Method(h
 func (r *Router) HeadFunc(pattern string, h func(http.ResponseWriter, *http.Request)) {
     r.Head(pattern, http.HandlerFunc(h))
 }
+// Connect registers h for CONNECT requests. Proxy requests use the authority
+// form, "CONNECT example.com:443", which has no path; they are routed as "/",
+// so register proxies with r.Connect("/", h) and read the target from req.Host.
+func (r *Router) Connect(pattern string, h http.Handler)            { r.Method(http.MethodConnect, pattern, h) }
+func (r *Router) ConnectFunc(pattern string, h func(http.ResponseWriter, *http.Request)) {
+    r.Connect(pattern, http.HandlerFunc(h))
+}
+func (r *Router) Trace(pattern string, h http.Handler)              { r.Method(http.MethodTrace, pattern, h) }
+func (r *Router) TraceFunc(pattern string, h func(http.ResponseWriter, *http.Request)) {
+    r.Trace(pattern, http.HandlerFunc(h))
+}
+
+// internal: match a lowercased copy of req, then restore path values from
+// the original path so identifiers keep their case.
+func (r *Router) serveFolded(w http.ResponseWriter, req *http.Request) {
+    orig := req.URL.Path
+    req = req.Clone(req.Context())
+    req.URL.Path, req.URL.RawPath = strings.ToLower(orig), ""
+    h, pattern := r.mux.Handler(req)
+    if pattern == "" {
+        if nf := r.notFoundHandler(); nf != nil { h = r.chain(nf) }
+        h.ServeHTTP(w, req)
+        return
+    }
+    osegs := strings.Split(orig, "/")
+    for i, seg := range strings.Split(pattern, "/") {
+        if i >= len(osegs) { break }
+        if !strings.HasPrefix(seg, "{") || !strings.HasSuffix(seg, "}") || seg == "{$}" { continue }
+        name := seg[1 : len(seg)-1]
+        if rest, ok := strings.CutSuffix(name, "..."); ok {
+            req.SetPathValue(rest, strings.Join(osegs[i:], "/"))
+            break
+        }
+        req.SetPathValue(name, osegs[i])
+    }
+    h.ServeHTTP(w, req)
+}
+
+// internal: register h with the mux for pattern on behalf of route. Exact
+// duplicates and patterns the mux rejects as conflicting panic with the
+// location of the registration.
+func (r *Router) handle(pattern, route string, h http.Handler) {
+    r.routes.claim(pattern, route)
+    at := callerLocation()
+    defer func() {
+        if v := recover(); v != nil { panic(fmt.Sprintf("router: %s registered at %s: %v", route, at, v)) }
+    }()
+    r.mux.Handle(muxPattern(pattern), h)
+}
+
+// internal: rewrite 405 responses from h into plain 404s.
+func hideMethodNotAllowed(h http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        h.ServeHTTP(&hide405Writer{ResponseWriter: w, req: req}, req)
+    })
+}
 
-// internal: create a new router with additional path prefix.
+type hide405Writer struct {
+    http.ResponseWriter
+    req    *http.Request
+    hidden bool
+}
+
+func (w *hide405Writer) WriteHeader(code int) {
+    if code == http.StatusMethodNotAllowed {
+        w.hidden = true
+        w.Header().Del("Allow")
+        http.NotFound(w.ResponseWriter, w.req)
+        return
+    }
+    w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *hide405Writer) Write(b []byte) (int, error) {
+    if w.hidden { return len(b), nil }
+    return w.ResponseWriter.Write(b)
+}
+
+// internal: create a new router with additional path prefix. The
+// middleware slice is capped so Use on the copy never writes into the
+// parent's backing array.
 func (r *Router) withPrefix(prefix string) *Router {
     clone := *r
     clone.base = r.join(prefix)
+    clone.middlewares = r.middlewares[:len(r.middlewares):len(r.middlewares)]
     return &clone
 }
 
@@ -163,15 +445,47 @@ func (r *Router) join(p string) string {
     return joined
 }
 
-// internal: apply middleware chain.
-func (r *Router) wrap(h http.Handler) http.Handler {
-    if len(r.middlewares) == 0 {
-        return h
-    }
-    wrapped := h
+// internal: apply middleware chain, recording the matched route pattern in
+// the request context before any middleware runs.
+func (r *Router) wrap(pattern string, h http.Handler) http.Handler {
+    return withPattern(pattern, r.chain(h))
+}
+
+// internal: apply this router's middlewares around h. With a group timeout,
+// h itself is limited and the timeout is recorded in the context before the
+// middlewares run, so a router-wide Timeout can defer to it.
+func (r *Router) chain(h http.Handler) http.Handler {
+    if r.timeout > 0 { h = http.TimeoutHandler(h, r.timeout, "request timeout") }
     for i := len(r.middlewares) - 1; i >= 0; i-- {
-        wrapped = r.middlewares[i](wrapped)
+        h = r.middlewares[i].fn(h)
+    }
+    if r.timeout > 0 {
+        d, next := r.timeout, h
+        h = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+            next.ServeHTTP(w, req.WithContext(ctxutil.WithRouteTimeout(req.Context(), d)))
+        })
     }
-    return wrapped
+    return h
+}
+
+// internal: record pattern as the matched route for h, prefixed with the
+// paths of any routers it is mounted under, e.g. "/admin/users/{id}".
+func withPattern(pattern string, h http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        ctx := req.Context()
+        h.ServeHTTP(w, req.WithContext(ctxutil.WithRoutePattern(ctx, mountPrefix(ctx)+pattern)))
+    })
 }
 
+type mountPrefixKey struct{}
+
+// internal: extend the mount prefix in ctx by the mount path prefix, which
+// has no trailing slash.
+func withMountPrefix(ctx context.Context, prefix string) context.Context {
+    return context.WithValue(ctx, mountPrefixKey{}, mountPrefix(ctx)+strings.TrimRight(prefix, "/"))
+}
+
+func mountPrefix(ctx context.Context) string {
+    p, _ := ctx.Value(mountPrefixKey{}).(string)
+    return p
+}
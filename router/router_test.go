@@ -1,6 +1,7 @@
 package router
 
 import (
+    "bufio"
     "io"
     "net/http"
     "net/http/httptest"
@@ -145,3 +146,322 @@ func TestMountWithTrailingSlash(t *testing.T) {
         t.Fatalf("expected 200 dash, got %d %q", rr2.Code, rr2.Body.String())
     }
 }
+
+func TestHideMethods(t *testing.T) {
+    r := New()
+    ok := func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, "ok") }
+    r.Route("/internal", func(in *Router) {
+        in.GetFunc("/stats", ok)
+    }, HideMethods())
+    r.Route("/public", func(pub *Router) {
+        pub.GetFunc("/stats", ok)
+    })
+    admin := New()
+    admin.GetFunc("/users", ok)
+    r.Mount("/admin", admin, HideMethods())
+
+    cases := []struct {
+        path string
+        want int
+    }{
+        {"/internal/stats", http.StatusNotFound},
+        {"/admin/users", http.StatusNotFound},
+        {"/public/stats", http.StatusMethodNotAllowed},
+    }
+    for _, tc := range cases {
+        rr := httptest.NewRecorder()
+        r.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, tc.path, nil))
+        if rr.Code != tc.want {
+            t.Fatalf("DELETE %s: expected %d, got %d", tc.path, tc.want, rr.Code)
+        }
+        if tc.want == http.StatusNotFound && rr.Header().Get("Allow") != "" {
+            t.Fatalf("DELETE %s: Allow header leaked: %q", tc.path, rr.Header().Get("Allow"))
+        }
+    }
+
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/internal/stats", nil))
+    if rr.Code != http.StatusOK {
+        t.Fatalf("expected 200 for registered method, got %d", rr.Code)
+    }
+}
+
+func TestAcceptDispatchesByContentType(t *testing.T) {
+    r := New()
+    r.Accept("/upload", map[string]http.Handler{
+        "application/json": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, "json") }),
+        "multipart/form-data": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, "multipart") }),
+    })
+
+    cases := []struct {
+        contentType string
+        code        int
+        body        string
+    }{
+        {"application/json; charset=utf-8", http.StatusOK, "json"},
+        {"multipart/form-data; boundary=xyz", http.StatusOK, "multipart"},
+        {"text/plain", http.StatusUnsupportedMediaType, ""},
+    }
+    for _, tc := range cases {
+        req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("x"))
+        req.Header.Set("Content-Type", tc.contentType)
+        rr := httptest.NewRecorder()
+        r.ServeHTTP(rr, req)
+        if rr.Code != tc.code || (tc.body != "" && rr.Body.String() != tc.body) {
+            t.Fatalf("%s: expected %d %q, got %d %q", tc.contentType, tc.code, tc.body, rr.Code, rr.Body.String())
+        }
+    }
+}
+
+func TestSubAppIsolation(t *testing.T) {
+    tag := func(name string) Middleware {
+        return func(next http.Handler) http.Handler {
+            return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+                w.Header().Add("X-Layer", name)
+                next.ServeHTTP(w, req)
+            })
+        }
+    }
+    r := New()
+    r.Use(tag("root"))
+    r.GetFunc("/ping", func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, "pong") })
+    r.SubApp("/app", func(app *Router) {
+        app.Use(tag("app"))
+        app.GetFunc("/hello", func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, "hello") })
+        app.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+            w.WriteHeader(http.StatusNotFound)
+            io.WriteString(w, "app not found")
+        })
+    })
+
+    cases := []struct {
+        path, layers, body string
+        code               int
+    }{
+        {"/app/hello", "app", "hello", http.StatusOK},
+        {"/app/missing", "app", "app not found", http.StatusNotFound},
+        {"/ping", "root", "pong", http.StatusOK},
+        {"/missing", "", "404 page not found\n", http.StatusNotFound},
+    }
+    for _, tc := range cases {
+        rr := httptest.NewRecorder()
+        r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+        layers := strings.Join(rr.Header()["X-Layer"], ",")
+        if rr.Code != tc.code || rr.Body.String() != tc.body || layers != tc.layers {
+            t.Fatalf("%s: got %d %q layers=%q, want %d %q layers=%q", tc.path, rr.Code, rr.Body.String(), layers, tc.code, tc.body, tc.layers)
+        }
+    }
+}
+
+func TestCustomNotFoundAndMethodNotAllowed(t *testing.T) {
+    r := New()
+    r.NotFound(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        NotFound(w, req, "not_found", "no such endpoint")
+    }))
+    r.MethodNotAllowed(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        RenderError(w, req, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed", nil)
+    }))
+    r.Route("/api", func(api *Router) {
+        api.GetFunc("/users", func(w http.ResponseWriter, req *http.Request) { w.Write([]byte("users")) })
+    })
+    r.Route("/private", func(p *Router) {
+        p.GetFunc("/secret", func(w http.ResponseWriter, req *http.Request) {})
+    }, HideMethods())
+
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/missing", nil))
+    if rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), `"not_found"`) {
+        t.Fatalf("expected JSON 404, got %d %q", rr.Code, rr.Body.String())
+    }
+
+    rr = httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/users", nil))
+    if rr.Code != http.StatusMethodNotAllowed || !strings.Contains(rr.Body.String(), `"method_not_allowed"`) {
+        t.Fatalf("expected JSON 405, got %d %q", rr.Code, rr.Body.String())
+    }
+    if got := rr.Header().Get("Allow"); got != "GET" {
+        t.Fatalf("expected Allow GET, got %q", got)
+    }
+
+    rr = httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/private/secret", nil))
+    if rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), `"not_found"`) {
+        t.Fatalf("expected hidden route to use NotFound handler, got %d %q", rr.Code, rr.Body.String())
+    }
+
+    rr = httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/users", nil))
+    if rr.Code != http.StatusOK || rr.Body.String() != "users" {
+        t.Fatalf("expected 200 users, got %d %q", rr.Code, rr.Body.String())
+    }
+}
+
+func TestAllowAggregatesMethodsAcrossGroups(t *testing.T) {
+    r := New()
+    tag := func(v string) Middleware {
+        return func(next http.Handler) http.Handler {
+            return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+                w.Header().Add("X-Tag", v)
+                next.ServeHTTP(w, req)
+            })
+        }
+    }
+    echo := func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, req.Method) }
+    r.GetFunc("/orders/{id}", echo)
+    r.With(tag("write")).PutFunc("/orders/{id}", echo)
+    r.Route("/orders", func(o *Router) { o.DeleteFunc("/{id}", echo) })
+
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/orders/7", nil))
+    if rr.Code != http.StatusMethodNotAllowed { t.Fatalf("expected 405, got %d", rr.Code) }
+    if got := rr.Header().Get("Allow"); got != "GET, PUT, DELETE" { t.Fatalf("unexpected Allow %q", got) }
+
+    for _, m := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+        rr := httptest.NewRecorder()
+        r.ServeHTTP(rr, httptest.NewRequest(m, "/orders/7", nil))
+        if rr.Code != http.StatusOK || rr.Body.String() != m { t.Fatalf("%s: expected 200 %s, got %d %q", m, m, rr.Code, rr.Body.String()) }
+        if tagged := rr.Header().Get("X-Tag") == "write"; tagged != (m == http.MethodPut) {
+            t.Fatalf("%s: middleware scoped to PUT leaked or was lost (X-Tag=%q)", m, rr.Header().Get("X-Tag"))
+        }
+    }
+}
+
+func TestCaseInsensitivePaths(t *testing.T) {
+    r := New(WithCaseInsensitivePaths())
+    r.Route("/api", func(api *Router) {
+        api.GetFunc("/users/{id}", func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, "user="+req.PathValue("id")) })
+        api.GetFunc("/files/*path", func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, "file="+Wildcard(req)) })
+    })
+
+    cases := map[string]string{
+        "/api/users/AbC":          "user=AbC",
+        "/API/Users/AbC":          "user=AbC",
+        "/Api/FILES/Docs/Read.MD": "file=Docs/Read.MD",
+    }
+    for path, want := range cases {
+        rr := httptest.NewRecorder()
+        r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+        if rr.Code != http.StatusOK || rr.Body.String() != want {
+            t.Fatalf("GET %s: expected 200 %q, got %d %q", path, want, rr.Code, rr.Body.String())
+        }
+    }
+
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/API/Missing", nil))
+    if rr.Code != http.StatusNotFound { t.Fatalf("expected 404, got %d", rr.Code) }
+
+    strict := New()
+    strict.GetFunc("/api/users/{id}", func(w http.ResponseWriter, req *http.Request) {})
+    rr = httptest.NewRecorder()
+    strict.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/API/Users/1", nil))
+    if rr.Code != http.StatusNotFound { t.Fatalf("expected case-sensitive default router to 404, got %d", rr.Code) }
+}
+
+func TestAnyAndMatch(t *testing.T) {
+    r := New()
+    echo := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, req.Method) })
+    r.Any("/any", echo)
+    r.Match([]string{"get", http.MethodPost}, "/some", echo)
+
+    for _, m := range []string{http.MethodGet, http.MethodPut, http.MethodDelete, http.MethodTrace} {
+        rr := httptest.NewRecorder()
+        r.ServeHTTP(rr, httptest.NewRequest(m, "/any", nil))
+        if rr.Code != http.StatusOK || rr.Body.String() != m { t.Fatalf("Any %s: expected 200 %s, got %d %q", m, m, rr.Code, rr.Body.String()) }
+    }
+
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/some", nil))
+    if rr.Code != http.StatusOK || rr.Body.String() != http.MethodPost { t.Fatalf("Match POST: got %d %q", rr.Code, rr.Body.String()) }
+    rr = httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/some", nil))
+    if rr.Code != http.StatusMethodNotAllowed || rr.Header().Get("Allow") != "GET, POST" {
+        t.Fatalf("Match PUT: expected 405 Allow GET, POST, got %d %q", rr.Code, rr.Header().Get("Allow"))
+    }
+}
+
+func TestConnectAndTrace(t *testing.T) {
+    r := New()
+    echo := func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, req.Method) }
+    r.ConnectFunc("/tunnel", echo)
+    r.TraceFunc("/diag", echo)
+
+    for path, m := range map[string]string{"/tunnel": http.MethodConnect, "/diag": http.MethodTrace} {
+        rr := httptest.NewRecorder()
+        r.ServeHTTP(rr, httptest.NewRequest(m, path, nil))
+        if rr.Code != http.StatusOK || rr.Body.String() != m { t.Fatalf("%s %s: expected 200, got %d %q", m, path, rr.Code, rr.Body.String()) }
+    }
+}
+
+func TestConnectAuthorityForm(t *testing.T) {
+    r := New()
+    r.ConnectFunc("/", func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, "tunnel to "+req.Host) })
+    req, err := http.ReadRequest(bufio.NewReader(strings.NewReader("CONNECT example.com:443 HTTP/1.1\r\nHost: example.com:443\r\n\r\n")))
+    if err != nil { t.Fatal(err) }
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, req)
+    if rr.Code != http.StatusOK || rr.Body.String() != "tunnel to example.com:443" {
+        t.Fatalf("expected authority-form CONNECT to reach the / route, got %d %q", rr.Code, rr.Body.String())
+    }
+}
+
+func TestScopeScopesMiddlewares(t *testing.T) {
+    var order []string
+    mark := func(name string) Middleware {
+        return func(next http.Handler) http.Handler {
+            return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+                order = append(order, name)
+                next.ServeHTTP(w, req)
+            })
+        }
+    }
+    ok := func(w http.ResponseWriter, req *http.Request) {}
+
+    r := New()
+    r.Use(mark("parent"))
+    r.Scope(func(g *Router) {
+        g.GetFunc("/before", ok)
+        g.Use(mark("auth"))
+        g.GetFunc("/me", ok)
+    })
+    r.GetFunc("/health", ok)
+
+    cases := map[string]string{
+        "/me":     "parent,auth",
+        "/before": "parent",
+        "/health": "parent",
+    }
+    for path, want := range cases {
+        order = nil
+        rr := httptest.NewRecorder()
+        r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+        if rr.Code != http.StatusOK || strings.Join(order, ",") != want {
+            t.Fatalf("GET %s: expected %q, got %d %q", path, want, rr.Code, strings.Join(order, ","))
+        }
+    }
+}
+
+func TestSiblingGroupsDoNotShareMiddlewares(t *testing.T) {
+    tag := func(v string) Middleware {
+        return func(next http.Handler) http.Handler {
+            return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+                w.Header().Add("X-Tag", v)
+                next.ServeHTTP(w, req)
+            })
+        }
+    }
+    r := New()
+    // Three appends leave spare capacity in the parent's middleware slice.
+    r.Use(tag("1"))
+    r.Use(tag("2"))
+    r.Use(tag("3"))
+    var a, b *Router
+    r.Route("/a", func(sub *Router) { a = sub })
+    r.Route("/b", func(sub *Router) { b = sub })
+    a.Use(tag("a"))
+    b.Use(tag("b"))
+    a.GetFunc("/x", func(w http.ResponseWriter, req *http.Request) {})
+
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/a/x", nil))
+    if got := strings.Join(rr.Header().Values("X-Tag"), ","); got != "1,2,3,a" { t.Fatalf("expected 1,2,3,a, got %q", got) }
+}
@@ -0,0 +1,220 @@
+package router
+
+import (
+    "fmt"
+    "net/http"
+    "path/filepath"
+    "runtime"
+    "strings"
+    "sync"
+
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+// routeTable records the methods registered for each full pattern. It is
+// shared by every Router derived from the same root, so GET and POST on one
+// path registered from different groups dispatch through one mux entry. It
+// also holds the custom NotFound, MethodNotAllowed and error handlers, if
+// any, and the patterns of named routes.
+type routeTable struct {
+    mu         sync.RWMutex
+    byPattern  map[string]*methodRoutes
+    notFound   http.Handler
+    notAllowed http.Handler
+    onError    ErrorHandlerFunc
+    registered []routeEntry
+    names      map[string]string
+    claimed    map[string]origin
+}
+
+// origin records which route first registered a mux pattern, and where.
+type origin struct {
+    route string
+    at    string
+}
+
+// RouteInfo describes one registered route as reported by Router.Routes.
+// Method is "*" for routes registered with Handle or Mount, which accept any
+// method. Middlewares counts the middlewares wrapping the handler.
+type RouteInfo struct {
+    Method      string
+    Pattern     string
+    Middlewares int
+}
+
+// routeEntry is a registration in order; mount is set when a *Router was
+// mounted, so its routes can be listed under the mount prefix. mws are the
+// names of the middlewares wrapping the handler, outermost first.
+type routeEntry struct {
+    RouteInfo
+    mws   []string
+    mount *Router
+}
+
+// methodRoutes holds the per-method handlers for one pattern, in
+// registration order. fallback answers requests for unregistered methods.
+type methodRoutes struct {
+    methods  []string
+    handlers map[string]http.Handler
+    at       map[string]string
+    fallback http.Handler
+}
+
+func newRouteTable() *routeTable { return &routeTable{byPattern: map[string]*methodRoutes{}, names: map[string]string{}, claimed: map[string]origin{}} }
+
+// add registers h for method on pattern and reports whether pattern was new,
+// in which case the caller must register a dispatcher with the mux.
+// Registering the same method and pattern twice panics, naming where the
+// first registration was made.
+func (t *routeTable) add(pattern, method string, h http.Handler) (*methodRoutes, bool) {
+    at := callerLocation()
+    t.mu.Lock(); defer t.mu.Unlock()
+    mr, ok := t.byPattern[pattern]
+    if !ok {
+        mr = &methodRoutes{handlers: map[string]http.Handler{}, at: map[string]string{}}
+        t.byPattern[pattern] = mr
+    }
+    if first, dup := mr.at[method]; dup {
+        panic(fmt.Sprintf("router: %s %s registered at %s is already registered at %s", method, pattern, at, first))
+    }
+    mr.methods = append(mr.methods, method)
+    mr.handlers[method] = h
+    mr.at[method] = at
+    return mr, !ok
+}
+
+// claim reserves the mux pattern for route, panicking with both locations if
+// another route already holds it.
+func (t *routeTable) claim(pattern, route string) {
+    at := callerLocation()
+    t.mu.Lock(); defer t.mu.Unlock()
+    if o, dup := t.claimed[pattern]; dup {
+        panic(fmt.Sprintf("router: %s registered at %s conflicts with %s registered at %s", route, at, o.route, o.at))
+    }
+    t.claimed[pattern] = origin{route, at}
+}
+
+// routerDir is this package's source directory, used to skip its frames.
+var routerDir = func() string {
+    _, file, _, _ := runtime.Caller(0)
+    return filepath.Dir(file)
+}()
+
+// callerLocation returns the file:line of the first caller outside this
+// package, i.e. the application code registering a route.
+func callerLocation() string {
+    pcs := make([]uintptr, 32)
+    frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+    for {
+        f, more := frames.Next()
+        if filepath.Dir(f.File) != routerDir || strings.HasSuffix(f.File, "_test.go") {
+            return fmt.Sprintf("%s:%d", f.File, f.Line)
+        }
+        if !more { return "unknown" }
+    }
+}
+
+func (t *routeTable) name(name, pattern string) {
+    t.mu.Lock(); defer t.mu.Unlock()
+    if _, dup := t.names[name]; dup { panic("router: multiple registrations for route name " + name) }
+    t.names[name] = pattern
+}
+
+// record adds a registration by r to the route list.
+func (r *Router) record(method, pattern string, mount *Router) {
+    mws := middlewareNames(r.middlewares)
+    r.routes.mu.Lock(); defer r.routes.mu.Unlock()
+    r.routes.registered = append(r.routes.registered, routeEntry{RouteInfo{method, pattern, len(mws)}, mws, mount})
+}
+
+func (t *routeTable) lookup(pattern, method string) (http.Handler, *methodRoutes) {
+    t.mu.RLock(); defer t.mu.RUnlock()
+    mr := t.byPattern[pattern]
+    if mr == nil { return nil, nil }
+    return mr.handlers[method], mr
+}
+
+func (t *routeTable) errorHandlers() (notFound, notAllowed http.Handler) {
+    t.mu.RLock(); defer t.mu.RUnlock()
+    return t.notFound, t.notAllowed
+}
+
+// allowed returns the methods registered for pattern formatted for an Allow
+// header, adding OPTIONS when withOptions is set. It is empty for unknown
+// patterns.
+func (t *routeTable) allowed(pattern string, withOptions bool) string {
+    t.mu.RLock(); defer t.mu.RUnlock()
+    mr := t.byPattern[pattern]
+    if mr == nil { return "" }
+    methods := append([]string{}, mr.methods...)
+    if withOptions && mr.handlers[http.MethodOptions] == nil { methods = append(methods, http.MethodOptions) }
+    return strings.Join(methods, ", ")
+}
+
+// Routes returns every route registered on this router and the groups and
+// routers mounted under it, in registration order, e.g. to print a route
+// table at startup:
+//  for _, ri := range r.Routes() {
+//      log.Printf("%-7s %s", ri.Method, ri.Pattern)
+//  }
+// Routes of a mounted *Router are listed under the mount prefix; any other
+// mounted handler, or a router mounted inside itself, appears as a single "*"
+// route ending in "/*".
+func (r *Router) Routes() []RouteInfo {
+    entries := r.entries()
+    out := make([]RouteInfo, len(entries))
+    for i, e := range entries { out[i] = e.RouteInfo }
+    return out
+}
+
+// entries returns the registered routes with mounted routers expanded.
+func (r *Router) entries() []routeEntry { return r.expand(map[*routeTable]bool{}) }
+
+// expand lists r's routes, expanding mounted routers whose route tables are
+// not already being expanded in the enclosing mounts.
+func (r *Router) expand(active map[*routeTable]bool) []routeEntry {
+    active[r.routes] = true
+    defer delete(active, r.routes)
+    r.routes.mu.RLock()
+    registered := append([]routeEntry{}, r.routes.registered...)
+    r.routes.mu.RUnlock()
+
+    var out []routeEntry
+    for _, e := range registered {
+        if e.mount != nil && active[e.mount.routes] {
+            e.Pattern, e.mount = strings.TrimRight(e.Pattern, "/")+"/*", nil
+        }
+        if e.mount == nil {
+            out = append(out, e)
+            continue
+        }
+        prefix := strings.TrimRight(e.Pattern, "/")
+        for _, sub := range e.mount.expand(active) {
+            sub.Pattern = prefix + sub.Pattern
+            sub.mws = append(append([]string{}, e.mws...), sub.mws...)
+            sub.Middlewares = len(sub.mws)
+            out = append(out, sub)
+        }
+    }
+    return out
+}
+
+// AutoOptionsHandler returns a handler that answers OPTIONS for the matched
+// route with 204 No Content and an Allow header listing every method
+// registered for that path, e.g. "GET, POST, OPTIONS". Unknown paths get 404.
+//  r.GetFunc("/users", list)
+//  r.PostFunc("/users", create)
+//  r.Options("/users", r.AutoOptionsHandler())
+func (r *Router) AutoOptionsHandler() http.Handler {
+    routes := r.routes
+    return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        ctx := req.Context()
+        allow := routes.allowed(strings.TrimPrefix(ctxutil.GetRoutePattern(ctx), mountPrefix(ctx)), true)
+        if allow == "" {
+            http.NotFound(w, req)
+            return
+        }
+        w.Header().Set("Allow", allow)
+        w.WriteHeader(http.StatusNoContent)
+    })
+}
@@ -0,0 +1,176 @@
+package router
+
+import (
+    "fmt"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+func TestAutoOptionsHandler(t *testing.T) {
+    r := New()
+    ok := func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, req.Method) }
+    r.Route("/users", func(u *Router) {
+        u.GetFunc("/", ok)
+        u.PostFunc("/", ok)
+        u.Options("/", u.AutoOptionsHandler())
+    })
+
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodOptions, "/users", nil))
+    if rr.Code != http.StatusNoContent { t.Fatalf("expected 204, got %d", rr.Code) }
+    if got := rr.Header().Get("Allow"); got != "GET, POST, OPTIONS" { t.Fatalf("unexpected Allow %q", got) }
+
+    for _, m := range []string{http.MethodGet, http.MethodPost} {
+        rr := httptest.NewRecorder()
+        r.ServeHTTP(rr, httptest.NewRequest(m, "/users", nil))
+        if rr.Code != http.StatusOK || rr.Body.String() != m { t.Fatalf("%s: expected 200 %s, got %d %q", m, m, rr.Code, rr.Body.String()) }
+    }
+
+    rr = httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/users", nil))
+    if rr.Code != http.StatusMethodNotAllowed { t.Fatalf("expected 405, got %d", rr.Code) }
+    if got := rr.Header().Get("Allow"); got != "GET, POST, OPTIONS" { t.Fatalf("unexpected Allow on 405 %q", got) }
+}
+
+func TestRoutes(t *testing.T) {
+    mw := func(next http.Handler) http.Handler { return next }
+    ok := func(w http.ResponseWriter, req *http.Request) {}
+
+    admin := New()
+    admin.Use(mw)
+    admin.GetFunc("/users", ok)
+
+    r := New()
+    r.Use(mw)
+    r.GetFunc("/ping", ok)
+    r.Route("/api", func(api *Router) {
+        api.With(mw).PostFunc("/orders", ok)
+        api.HandleFunc("/legacy", ok)
+    })
+    r.Mount("/admin", admin)
+    r.Mount("/static", http.FileServer(http.Dir(".")))
+
+    want := []RouteInfo{
+        {"GET", "/ping", 1},
+        {"POST", "/api/orders", 2},
+        {"*", "/api/legacy", 1},
+        {"GET", "/admin/users", 2},
+        {"*", "/static/*", 1},
+    }
+    got := r.Routes()
+    if len(got) != len(want) { t.Fatalf("expected %d routes, got %+v", len(want), got) }
+    for i := range want {
+        if got[i] != want[i] { t.Fatalf("route %d: expected %+v, got %+v", i, want[i], got[i]) }
+    }
+}
+
+func TestRoutesMountCycle(t *testing.T) {
+    ok := func(w http.ResponseWriter, req *http.Request) {}
+    a, b := New(), New()
+    a.GetFunc("/x", ok)
+    b.GetFunc("/y", ok)
+    a.Mount("/b", b)
+    b.Mount("/a", a)
+
+    want := []RouteInfo{{"GET", "/x", 0}, {"GET", "/b/y", 0}, {"*", "/b/a/*", 0}}
+    got := a.Routes()
+    if len(got) != len(want) { t.Fatalf("expected %d routes, got %+v", len(want), got) }
+    for i := range want {
+        if got[i] != want[i] { t.Fatalf("route %d: expected %+v, got %+v", i, want[i], got[i]) }
+    }
+    a.Print(io.Discard)
+}
+
+func TestAutomaticOptions(t *testing.T) {
+    r := New()
+    ok := func(w http.ResponseWriter, req *http.Request) {}
+    r.GetFunc("/items", ok)
+    r.Route("/items", func(items *Router) { items.DeleteFunc("/", ok) })
+    r.Route("/private", func(p *Router) { p.GetFunc("/thing", ok) }, HideMethods())
+
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodOptions, "/items", nil))
+    if rr.Code != http.StatusNoContent { t.Fatalf("expected 204, got %d", rr.Code) }
+    if got := rr.Header().Get("Allow"); got != "GET, DELETE, OPTIONS" { t.Fatalf("unexpected Allow %q", got) }
+
+    rr = httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/items", nil))
+    if got := rr.Header().Get("Allow"); rr.Code != http.StatusMethodNotAllowed || got != "GET, DELETE" {
+        t.Fatalf("expected 405 with Allow GET, DELETE, got %d %q", rr.Code, got)
+    }
+
+    rr = httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodOptions, "/private/thing", nil))
+    if rr.Code != http.StatusNotFound || rr.Header().Get("Allow") != "" { t.Fatalf("expected hidden 404, got %d %q", rr.Code, rr.Header().Get("Allow")) }
+}
+
+func TestRoutePatternIncludesMountPrefix(t *testing.T) {
+    echo := func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, ctxutil.GetRoutePattern(req.Context())) }
+    users := New()
+    users.GetFunc("/{id}", echo)
+    users.GetFunc("/", echo)
+    users.Options("/{id}", users.AutoOptionsHandler())
+    admin := New()
+    admin.Mount("/users", users)
+    r := New()
+    r.Route("/api", func(api *Router) {
+        api.Mount("/admin", admin)
+        api.GetFunc("/orders/{id}", echo)
+    })
+
+    cases := map[string]string{
+        "/api/orders/9":       "/api/orders/{id}",
+        "/api/admin/users/42": "/api/admin/users/{id}",
+        "/api/admin/users":    "/api/admin/users/",
+    }
+    for path, want := range cases {
+        rr := httptest.NewRecorder()
+        r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+        if rr.Body.String() != want { t.Fatalf("GET %s: expected pattern %q, got %d %q", path, want, rr.Code, rr.Body.String()) }
+    }
+
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodOptions, "/api/admin/users/42", nil))
+    if got := rr.Header().Get("Allow"); rr.Code != http.StatusNoContent || got != "GET, OPTIONS" {
+        t.Fatalf("expected AutoOptionsHandler under mounts, got %d %q", rr.Code, got)
+    }
+}
+
+func TestDuplicateRegistrationPanics(t *testing.T) {
+    ok := func(w http.ResponseWriter, req *http.Request) {}
+    panics := func(register func(r *Router)) string {
+        t.Helper()
+        var msg string
+        func() {
+            defer func() { msg = fmt.Sprint(recover()) }()
+            r := New()
+            r.GetFunc("/users/{id}", ok) // first registration
+            register(r)
+        }()
+        return msg
+    }
+
+    cases := map[string]func(r *Router){
+        "GET /users/{id} registered at": func(r *Router) { r.GetFunc("/users/{id}", ok) },
+        "* /users/{id} registered at":   func(r *Router) { r.HandleFunc("/users/{id}", ok) },
+        "mount /users/{id} registered":  func(r *Router) { r.Mount("/users/{id}", http.NotFoundHandler()) },
+        "GET /users/{name} registered":  func(r *Router) { r.GetFunc("/users/{name}", ok) },
+    }
+    for want, register := range cases {
+        msg := panics(register)
+        if !strings.Contains(msg, want) || !strings.Contains(msg, "routes_test.go:") {
+            t.Fatalf("expected panic mentioning %q and the test file, got %q", want, msg)
+        }
+    }
+    if msg := panics(func(r *Router) { r.GetFunc("/users/{id}", ok) }); !strings.Contains(msg, "already registered at") {
+        t.Fatalf("expected first registration location, got %q", msg)
+    }
+    if msg := panics(func(r *Router) { r.PostFunc("/users/{id}", ok) }); msg != "<nil>" {
+        t.Fatalf("expected another method on the same pattern to be allowed, got %q", msg)
+    }
+}
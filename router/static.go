@@ -0,0 +1,67 @@
+package router
+
+import (
+    "fmt"
+    "io/fs"
+    "net/http"
+    "path"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// StaticOptions configures Router.Static.
+type StaticOptions struct {
+    // MaxAge sets Cache-Control: public, max-age=N on served files. Zero
+    // leaves Cache-Control unset.
+    MaxAge time.Duration
+    // Index is served for directory requests, e.g. "index.html". Directories
+    // without one get 404; listings are never generated.
+    Index string
+    // SPA serves the root Index for paths that match no file, so a
+    // single-page app's client-side routes load the app shell.
+    SPA bool
+}
+
+// Static serves files from root under prefix for GET and HEAD:
+//  r.Static("/assets", http.Dir("./public"), router.StaticOptions{MaxAge: time.Hour, Index: "index.html"})
+// Paths are cleaned before opening, so "..", including escaped forms, cannot
+// leave root. Responses carry Last-Modified and a weak ETag derived from the
+// file's size and modification time, and conditional requests get 304.
+func (r *Router) Static(prefix string, root http.FileSystem, opts StaticOptions) {
+    h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        name := path.Clean("/" + Wildcard(req))
+        f, st, err := openStatic(root, name, opts.Index)
+        if err != nil && opts.SPA && opts.Index != "" && !strings.Contains(path.Base(name), ".") {
+            f, st, err = openStatic(root, "/"+opts.Index, "")
+        }
+        if err != nil {
+            http.NotFound(w, req)
+            return
+        }
+        defer f.Close()
+        if opts.MaxAge > 0 {
+            w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(opts.MaxAge.Seconds())))
+        }
+        w.Header().Set("ETag", fmt.Sprintf(`W/"%x-%x"`, st.ModTime().UnixNano(), st.Size()))
+        http.ServeContent(w, req, st.Name(), st.ModTime(), f)
+    })
+    pattern := strings.TrimRight(prefix, "/") + "/*filepath"
+    r.Get(pattern, h)
+    r.Head(pattern, h)
+}
+
+// openStatic opens name in root, resolving directories to their index file.
+func openStatic(root http.FileSystem, name, index string) (http.File, fs.FileInfo, error) {
+    f, err := root.Open(name)
+    if err != nil { return nil, nil, err }
+    st, err := f.Stat()
+    if err != nil {
+        f.Close()
+        return nil, nil, err
+    }
+    if !st.IsDir() { return f, st, nil }
+    f.Close()
+    if index == "" { return nil, nil, fs.ErrNotExist }
+    return openStatic(root, path.Join(name, index), "")
+}
@@ -0,0 +1,58 @@
+package router
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "testing/fstest"
+    "time"
+)
+
+func TestStatic(t *testing.T) {
+    files := http.FS(fstest.MapFS{
+        "index.html":      {Data: []byte("<app>")},
+        "css/site.css":    {Data: []byte("body{}"), ModTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+        "docs/index.html": {Data: []byte("docs")},
+        "empty/a.txt":     {Data: []byte("a")},
+    })
+    r := New()
+    r.Static("/assets", files, StaticOptions{MaxAge: time.Hour, Index: "index.html"})
+    r.Static("/app", files, StaticOptions{Index: "index.html", SPA: true})
+
+    get := func(path string, hdr ...string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodGet, path, nil)
+        for i := 0; i+1 < len(hdr); i += 2 { req.Header.Set(hdr[i], hdr[i+1]) }
+        rr := httptest.NewRecorder()
+        r.ServeHTTP(rr, req)
+        return rr
+    }
+
+    rr := get("/assets/css/site.css")
+    if rr.Code != http.StatusOK || rr.Body.String() != "body{}" { t.Fatalf("expected file, got %d %q", rr.Code, rr.Body.String()) }
+    if got := rr.Header().Get("Cache-Control"); got != "public, max-age=3600" { t.Fatalf("unexpected Cache-Control %q", got) }
+    if rr.Header().Get("Last-Modified") == "" { t.Fatal("expected Last-Modified") }
+    etag := rr.Header().Get("ETag")
+    if etag == "" { t.Fatal("expected ETag") }
+
+    if rr := get("/assets/css/site.css", "If-None-Match", etag); rr.Code != http.StatusNotModified {
+        t.Fatalf("expected 304, got %d", rr.Code)
+    }
+    // Tags are compared whole: a longer tag that contains this one is a miss.
+    if rr := get("/assets/css/site.css", "If-None-Match", etag[:len(etag)-1]+`0"`); rr.Code != http.StatusOK {
+        t.Fatalf("expected 200 for a different tag, got %d", rr.Code)
+    }
+    if !ETagMatches(`"other", `+etag, etag) { t.Fatal("expected Static's ETag to match through ETagMatches") }
+    if rr := get("/assets/docs/"); rr.Code != http.StatusOK || rr.Body.String() != "docs" {
+        t.Fatalf("expected directory index, got %d %q", rr.Code, rr.Body.String())
+    }
+    for _, p := range []string{"/assets/empty/", "/assets/missing.js", "/assets/%2e%2e/router.go", "/assets/css/..%2f..%2f..%2frouter.go"} {
+        if rr := get(p); rr.Code != http.StatusNotFound { t.Fatalf("%s: expected 404, got %d", p, rr.Code) }
+    }
+
+    if rr := get("/app/settings/profile"); rr.Code != http.StatusOK || rr.Body.String() != "<app>" {
+        t.Fatalf("expected SPA fallback, got %d %q", rr.Code, rr.Body.String())
+    }
+    if rr := get("/app/missing.js"); rr.Code != http.StatusNotFound {
+        t.Fatalf("expected missing asset to 404 despite SPA, got %d", rr.Code)
+    }
+}
@@ -0,0 +1,73 @@
+package router
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+)
+
+// ErrUnknownRoute is returned by URL for a name no route was registered with.
+var ErrUnknownRoute = errors.New("router: unknown route name")
+
+// MethodNamed registers h like Method and records pattern under name, so
+// URL can build paths for it. Names are shared by every Router derived from
+// the same root; registering a name twice panics.
+func (r *Router) MethodNamed(name, method, pattern string, h http.Handler) {
+    r.routes.name(name, r.join(pattern))
+    r.Method(method, pattern, h)
+}
+
+// GetNamed registers a named GET route. See MethodNamed.
+func (r *Router) GetNamed(name, pattern string, h http.Handler) { r.MethodNamed(name, http.MethodGet, pattern, h) }
+
+// PostNamed registers a named POST route. See MethodNamed.
+func (r *Router) PostNamed(name, pattern string, h http.Handler) { r.MethodNamed(name, http.MethodPost, pattern, h) }
+
+// URL builds the path of the route registered under name, filling its
+// wildcards from params given as name/value pairs:
+//  r.GetNamed("user_show", "/users/{id}", showUser)
+//  u, err := r.URL("user_show", "id", "42") // "/users/42"
+// Values are path-escaped; catch-all segments ("{path...}" or "*path") keep
+// their slashes. Missing, unknown, or odd params are an error.
+func (r *Router) URL(name string, params ...string) (string, error) {
+    if len(params)%2 != 0 { return "", fmt.Errorf("router: URL %q: odd number of params", name) }
+    r.routes.mu.RLock()
+    pattern, ok := r.routes.names[name]
+    r.routes.mu.RUnlock()
+    if !ok { return "", fmt.Errorf("%w: %q", ErrUnknownRoute, name) }
+
+    values := make(map[string]string, len(params)/2)
+    for i := 0; i < len(params); i += 2 { values[params[i]] = params[i+1] }
+
+    segs := strings.Split(pattern, "/")
+    for i, seg := range segs {
+        key, rest := "", false
+        switch {
+        case seg == "{$}":
+            segs[i] = ""
+            continue
+        case strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "...}"):
+            key, rest = seg[1:len(seg)-4], true
+        case strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}"):
+            key = seg[1 : len(seg)-1]
+        case strings.HasPrefix(seg, "*") && i == len(segs)-1:
+            key, rest = wildcardName(seg[1:]), true
+        default:
+            continue
+        }
+        v, ok := values[key]
+        if !ok { return "", fmt.Errorf("router: URL %q: missing param %q", name, key) }
+        delete(values, key)
+        if rest {
+            parts := strings.Split(v, "/")
+            for j, p := range parts { parts[j] = url.PathEscape(p) }
+            segs[i] = strings.Join(parts, "/")
+        } else {
+            segs[i] = url.PathEscape(v)
+        }
+    }
+    for key := range values { return "", fmt.Errorf("router: URL %q: unknown param %q", name, key) }
+    return strings.Join(segs, "/"), nil
+}
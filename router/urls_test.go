@@ -0,0 +1,47 @@
+package router
+
+import (
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+func TestURL(t *testing.T) {
+    r := New()
+    ok := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})
+    r.Route("/api", func(api *Router) {
+        api.GetNamed("user_show", "/users/{id}", ok)
+        api.PostNamed("user_files", "/users/{id}/files/*path", ok)
+        api.GetNamed("home", "/{$}", ok)
+    })
+
+    cases := []struct {
+        name   string
+        params []string
+        want   string
+    }{
+        {"user_show", []string{"id", "42"}, "/api/users/42"},
+        {"user_show", []string{"id", "a b/c"}, "/api/users/a%20b%2Fc"},
+        {"user_files", []string{"id", "7", "path", "docs/q 1.pdf"}, "/api/users/7/files/docs/q%201.pdf"},
+        {"home", nil, "/api/"},
+    }
+    for _, c := range cases {
+        got, err := r.URL(c.name, c.params...)
+        if err != nil || got != c.want { t.Fatalf("URL(%q, %v) = %q, %v; want %q", c.name, c.params, got, err, c.want) }
+    }
+
+    if _, err := r.URL("missing"); !errors.Is(err, ErrUnknownRoute) { t.Fatalf("expected ErrUnknownRoute, got %v", err) }
+    if _, err := r.URL("user_show"); err == nil { t.Fatal("expected error for missing param") }
+    if _, err := r.URL("user_show", "id", "1", "extra", "2"); err == nil { t.Fatal("expected error for unknown param") }
+    if _, err := r.URL("user_show", "id"); err == nil { t.Fatal("expected error for odd params") }
+
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/users/42", nil))
+    if rr.Code != http.StatusOK { t.Fatalf("expected named route to serve, got %d", rr.Code) }
+
+    defer func() {
+        if recover() == nil { t.Fatal("expected panic on duplicate route name") }
+    }()
+    r.GetNamed("user_show", "/other", ok)
+}
@@ -0,0 +1,79 @@
+package router
+
+import (
+    "errors"
+    "net/http"
+    "sync"
+
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+// FieldViolation describes a single failed validation rule.
+type FieldViolation struct {
+	Field   string `json:"field"`
+	Tag     string `json:"tag,omitempty"`
+	Message string `json:"message"`
+}
+
+// FieldViolator is implemented by validation errors that can list their
+// per-field violations directly.
+type FieldViolator interface {
+    FieldViolations() []FieldViolation
+}
+
+// ValidationAdapter converts a validator library's error into field
+// violations, reporting false if err is not one of its errors. Adapters keep
+// RenderValidation independent of any particular validation library.
+type ValidationAdapter func(err error) ([]FieldViolation, bool)
+
+var (
+    validationMu       sync.RWMutex
+    validationAdapters []*ValidationAdapter
+)
+
+// RegisterValidationAdapter adds an adapter consulted by RenderValidation and
+// returns a func that removes it again. It is typically called once during
+// program initialization; tests should defer the returned func so the
+// process-wide registry is left as they found it.
+func RegisterValidationAdapter(a ValidationAdapter) (unregister func()) {
+    validationMu.Lock()
+    defer validationMu.Unlock()
+    p := &a
+    validationAdapters = append(validationAdapters, p)
+    return func() {
+        validationMu.Lock()
+        defer validationMu.Unlock()
+        for i, v := range validationAdapters {
+            if v == p {
+                validationAdapters = append(validationAdapters[:i:i], validationAdapters[i+1:]...)
+                return
+            }
+        }
+    }
+}
+
+// RenderValidation renders a struct validation error as a 422 envelope with
+// error "validation_failed" and the per-field violations as details.
+// Errors are recognized via FieldViolator anywhere in the errors.As chain or a
+// registered ValidationAdapter; anything else renders a 400 "invalid_request"
+// with a fixed message, since err may carry internal detail, and is logged at
+// debug level with ctxutil.Logger.
+func RenderValidation(w http.ResponseWriter, r *http.Request, err error) {
+    if violations, ok := fieldViolations(err); ok {
+        UnprocessableEntity(w, r, "validation_failed", "request validation failed", violations)
+        return
+    }
+    ctxutil.Logger(r.Context()).DebugContext(r.Context(), "invalid request", "error", err)
+    BadRequest(w, r, "invalid_request", "request is invalid", nil)
+}
+
+func fieldViolations(err error) ([]FieldViolation, bool) {
+    var fv FieldViolator
+    if errors.As(err, &fv) { return fv.FieldViolations(), true }
+    validationMu.RLock()
+    defer validationMu.RUnlock()
+    for _, a := range validationAdapters {
+        if v, ok := (*a)(err); ok { return v, true }
+    }
+    return nil, false
+}
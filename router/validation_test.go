@@ -0,0 +1,68 @@
+package router_test
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "github.com/shkmv/httplib/router"
+)
+
+// mockFieldError mimics a third-party validator's per-field error.
+type mockFieldError struct{ field, tag string }
+
+type mockValidationErrors []mockFieldError
+
+func (m mockValidationErrors) Error() string { return "validation failed" }
+
+func TestRenderValidation(t *testing.T) {
+    defer router.RegisterValidationAdapter(func(err error) ([]router.FieldViolation, bool) {
+        var verrs mockValidationErrors
+        if !errors.As(err, &verrs) { return nil, false }
+        out := make([]router.FieldViolation, 0, len(verrs))
+        for _, fe := range verrs {
+            out = append(out, router.FieldViolation{Field: fe.field, Tag: fe.tag, Message: fmt.Sprintf("%s failed on %s", fe.field, fe.tag)})
+        }
+        return out, true
+    })()
+
+    r := router.New()
+    r.PostFunc("/users", func(w http.ResponseWriter, req *http.Request) {
+        err := fmt.Errorf("bind: %w", mockValidationErrors{{"email", "required"}, {"age", "gte"}})
+        router.RenderValidation(w, req, err)
+    })
+    rr := httptest.NewRecorder()
+    r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/users", nil))
+    if rr.Code != http.StatusUnprocessableEntity {
+        t.Fatalf("expected 422, got %d", rr.Code)
+    }
+    var got struct {
+        Error   string                  `json:"error"`
+        Details []router.FieldViolation `json:"details"`
+    }
+    if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+        t.Fatalf("json: %v", err)
+    }
+    if got.Error != "validation_failed" || len(got.Details) != 2 {
+        t.Fatalf("unexpected envelope: %+v", got)
+    }
+    if d := got.Details[0]; d.Field != "email" || d.Tag != "required" || d.Message != "email failed on required" {
+        t.Fatalf("unexpected field details: %+v", d)
+    }
+
+    r.PostFunc("/other", func(w http.ResponseWriter, req *http.Request) {
+        router.RenderValidation(w, req, errors.New("malformed json"))
+    })
+    rr2 := httptest.NewRecorder()
+    r.ServeHTTP(rr2, httptest.NewRequest(http.MethodPost, "/other", nil))
+    if rr2.Code != http.StatusBadRequest {
+        t.Fatalf("expected 400 for non-validation error, got %d", rr2.Code)
+    }
+    if strings.Contains(rr2.Body.String(), "malformed json") {
+        t.Fatalf("error text leaked into response: %s", rr2.Body.String())
+    }
+}
@@ -0,0 +1,39 @@
+package router
+
+import (
+    "fmt"
+    "net/http"
+    "strings"
+
+    "github.com/shkmv/httplib/router/ctxutil"
+)
+
+// defaultWildcard names a bare trailing "*" segment.
+const defaultWildcard = "wildcard"
+
+// muxPattern translates a router pattern into ServeMux syntax: a trailing
+// catch-all segment "*name" becomes "{name...}", matching the rest of the path.
+// A catch-all anywhere but the last segment panics.
+func muxPattern(p string) string {
+    i := strings.Index(p, "/*")
+    if i < 0 { return p }
+    if strings.Contains(p[i+2:], "/") {
+        panic(fmt.Sprintf("router: catch-all segment in %q must be the last segment", p))
+    }
+    return p[:i+1] + "{" + wildcardName(p[i+2:]) + "...}"
+}
+
+func wildcardName(name string) string {
+    if name == "" { return defaultWildcard }
+    return name
+}
+
+// Wildcard returns the remainder of the path matched by a route's trailing
+// catch-all segment, e.g. "css/site.css" for "/files/css/site.css" on
+// "/files/*filepath". It returns "" for routes without one.
+func Wildcard(r *http.Request) string {
+    p := ctxutil.GetRoutePattern(r.Context())
+    i := strings.LastIndex(p, "/*")
+    if i < 0 { return "" }
+    return r.PathValue(wildcardName(p[i+2:]))
+}
@@ -0,0 +1,46 @@
+package router
+
+import (
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+func TestWildcardRoutes(t *testing.T) {
+    r := New()
+    r.Route("/static", func(s *Router) {
+        s.GetFunc("/files/*filepath", func(w http.ResponseWriter, req *http.Request) {
+            io.WriteString(w, "file="+Wildcard(req))
+        })
+    })
+    r.HandleFunc("/docs/*", func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, "doc="+Wildcard(req)) })
+    r.GetFunc("/plain", func(w http.ResponseWriter, req *http.Request) { io.WriteString(w, "plain="+Wildcard(req)) })
+
+    cases := map[string]string{
+        "/static/files/css/site.css": "file=css/site.css",
+        "/static/files/a":            "file=a",
+        "/static/files/":             "file=",
+        "/docs/guide/intro.md":       "doc=guide/intro.md",
+        "/plain":                     "plain=",
+    }
+    for path, want := range cases {
+        rr := httptest.NewRecorder()
+        r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+        if rr.Code != http.StatusOK || rr.Body.String() != want {
+            t.Fatalf("GET %s: expected 200 %q, got %d %q", path, want, rr.Code, rr.Body.String())
+        }
+    }
+}
+
+func TestWildcardMustBeLast(t *testing.T) {
+    defer func() {
+        v := recover()
+        msg, _ := v.(string)
+        if !strings.Contains(msg, "must be the last segment") || !strings.Contains(msg, "/a/*b/c") {
+            t.Fatalf("expected a catch-all placement panic, got %v", v)
+        }
+    }()
+    New().GetFunc("/a/*b/c", func(http.ResponseWriter, *http.Request) {})
+}
@@ -0,0 +1,143 @@
+// Package server runs an http.Server with graceful shutdown, plain or over
+// TLS with static certificates or automatically issued ones (ACME).
+package server
+
+import (
+    "context"
+    "crypto/tls"
+    "errors"
+    "fmt"
+    "net"
+    "net/http"
+    "slices"
+    "time"
+)
+
+// DefaultShutdownTimeout bounds how long Run and RunTLS wait for in-flight
+// requests after ctx is cancelled.
+const DefaultShutdownTimeout = 10 * time.Second
+
+// CertManager issues certificates on demand and answers ACME HTTP-01
+// challenges. *autocert.Manager from golang.org/x/crypto/acme/autocert
+// satisfies it:
+//  m := &autocert.Manager{Prompt: autocert.AcceptTOS, HostPolicy: autocert.HostWhitelist("example.com"), Cache: autocert.DirCache("certs")}
+//  server.RunTLS(ctx, srv, server.TLS{Manager: m})
+type CertManager interface {
+    GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error)
+    HTTPHandler(fallback http.Handler) http.Handler
+}
+
+// TLS selects the certificate source for RunTLS: either CertFile and KeyFile,
+// or a Manager.
+type TLS struct {
+    CertFile string
+    KeyFile  string
+    // Manager issues certificates automatically. RunTLS then also listens on
+    // HTTPAddr (default ":80") for HTTP-01 challenges; other plain HTTP
+    // requests go to HTTPHandler (default RedirectHTTPS()). The challenge
+    // cannot be routed through srv's handler: ACME validates HTTP-01 over
+    // plain HTTP on port 80, while srv only accepts TLS. Pass a Router as
+    // HTTPHandler to serve routes over plain HTTP next to the challenges.
+    Manager     CertManager
+    HTTPAddr    string
+    HTTPHandler http.Handler
+    // ShutdownTimeout overrides DefaultShutdownTimeout.
+    ShutdownTimeout time.Duration
+}
+
+// Run serves srv until ctx is cancelled, then shuts it down gracefully,
+// waiting up to DefaultShutdownTimeout for in-flight requests.
+func Run(ctx context.Context, srv *http.Server) error {
+    addr := srv.Addr
+    if addr == "" { addr = ":http" }
+    ln, err := net.Listen("tcp", addr)
+    if err != nil { return err }
+    return run(ctx, DefaultShutdownTimeout, srv, ln)
+}
+
+// RunTLS is like Run but serves HTTPS using the certificates selected by cfg.
+// srv.Addr and srv.TLSConfig are read but not modified. HTTP/2 is offered
+// when srv.TLSConfig is nil or lists "h2" in NextProtos, the cases in which
+// net/http enables it on srv; otherwise clients get HTTP/1.1. With a Manager,
+// both listeners are opened before serving starts, so an unusable HTTPAddr is
+// reported immediately; if the challenge server stops, so does srv.
+func RunTLS(ctx context.Context, srv *http.Server, cfg TLS) error {
+    if cfg.Manager == nil && (cfg.CertFile == "" || cfg.KeyFile == "") {
+        return errors.New("server: RunTLS needs CertFile and KeyFile or a Manager")
+    }
+    timeout := cfg.ShutdownTimeout
+    if timeout <= 0 { timeout = DefaultShutdownTimeout }
+    config := &tls.Config{}
+    if srv.TLSConfig != nil { config = srv.TLSConfig.Clone() }
+    if cfg.Manager == nil {
+        cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+        if err != nil { return err }
+        config.Certificates = []tls.Certificate{cert}
+    } else {
+        config.GetCertificate = cfg.Manager.GetCertificate
+    }
+    if srv.TLSConfig == nil { config.NextProtos = append(config.NextProtos, "h2") }
+    if !slices.Contains(config.NextProtos, "http/1.1") { config.NextProtos = append(config.NextProtos, "http/1.1") }
+
+    addr := srv.Addr
+    if addr == "" { addr = ":https" }
+    var challenge *http.Server
+    var httpLn net.Listener
+    if cfg.Manager != nil {
+        config.NextProtos = append(config.NextProtos, "acme-tls/1")
+        httpAddr := cfg.HTTPAddr
+        if httpAddr == "" { httpAddr = ":80" }
+        ln, err := net.Listen("tcp", httpAddr)
+        if err != nil { return fmt.Errorf("server: ACME challenge listener: %w", err) }
+        httpLn = ln
+        fallback := cfg.HTTPHandler
+        if fallback == nil { fallback = RedirectHTTPS() }
+        challenge = &http.Server{Addr: httpAddr, Handler: cfg.Manager.HTTPHandler(fallback), ReadHeaderTimeout: 10 * time.Second}
+    }
+    ln, err := net.Listen("tcp", addr)
+    if err != nil {
+        if httpLn != nil { httpLn.Close() }
+        return err
+    }
+    ln = tls.NewListener(ln, config)
+    if challenge == nil { return run(ctx, timeout, srv, ln) }
+
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+    errc := make(chan error, 1)
+    go func() {
+        errc <- run(ctx, timeout, challenge, httpLn)
+        cancel()
+    }()
+    err = run(ctx, timeout, srv, ln)
+    cancel()
+    return errors.Join(err, <-errc)
+}
+
+// RedirectHTTPS returns a handler that permanently redirects requests to the
+// same host and path over HTTPS.
+func RedirectHTTPS() http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        host := req.Host
+        if h, _, err := net.SplitHostPort(host); err == nil { host = h }
+        u := *req.URL
+        u.Scheme, u.Host = "https", host
+        http.Redirect(w, req, u.String(), http.StatusMovedPermanently)
+    })
+}
+
+// run serves srv on ln and shuts it down on ctx.
+func run(ctx context.Context, timeout time.Duration, srv *http.Server, ln net.Listener) error {
+    errc := make(chan error, 1)
+    go func() { errc <- srv.Serve(ln) }()
+    select {
+    case err := <-errc:
+        return err
+    case <-ctx.Done():
+    }
+    sctx, cancel := context.WithTimeout(context.Background(), timeout)
+    defer cancel()
+    err := srv.Shutdown(sctx)
+    if serr := <-errc; !errors.Is(serr, http.ErrServerClosed) { err = errors.Join(err, serr) }
+    return err
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+    "context"
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/tls"
+    "crypto/x509"
+    "crypto/x509/pkix"
+    "encoding/pem"
+    "io"
+    "math/big"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+// selfSigned writes a certificate for 127.0.0.1 to dir and returns its paths.
+func selfSigned(t *testing.T, dir string) (certFile, keyFile string) {
+    t.Helper()
+    key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    if err != nil { t.Fatal(err) }
+    tmpl := &x509.Certificate{
+        SerialNumber: big.NewInt(1),
+        Subject:      pkix.Name{CommonName: "test"},
+        IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+        NotBefore:    time.Now().Add(-time.Hour),
+        NotAfter:     time.Now().Add(time.Hour),
+    }
+    der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+    if err != nil { t.Fatal(err) }
+    kder, err := x509.MarshalECPrivateKey(key)
+    if err != nil { t.Fatal(err) }
+    certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+    os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+    os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder}), 0o600)
+    return certFile, keyFile
+}
+
+func freeAddr(t *testing.T) string {
+    t.Helper()
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil { t.Fatal(err) }
+    defer ln.Close()
+    return ln.Addr().String()
+}
+
+// get retries until the server started by the test is accepting connections.
+func get(t *testing.T, c *http.Client, url string) *http.Response {
+    t.Helper()
+    deadline := time.Now().Add(5 * time.Second)
+    for {
+        resp, err := c.Get(url)
+        if err == nil { return resp }
+        if time.Now().After(deadline) { t.Fatalf("GET %s: %v", url, err) }
+        time.Sleep(20 * time.Millisecond)
+    }
+}
+
+var insecure = &http.Client{
+    Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+    CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+func TestRunTLSStaticCert(t *testing.T) {
+    certFile, keyFile := selfSigned(t, t.TempDir())
+    addr := freeAddr(t)
+    srv := &http.Server{Addr: addr, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "secure") })}
+
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan error, 1)
+    go func() { done <- RunTLS(ctx, srv, TLS{CertFile: certFile, KeyFile: keyFile}) }()
+
+    resp := get(t, insecure, "https://"+addr+"/")
+    body, _ := io.ReadAll(resp.Body)
+    resp.Body.Close()
+    if string(body) != "secure" { t.Fatalf("unexpected body %q", body) }
+
+    cancel()
+    if err := <-done; err != nil { t.Fatalf("expected clean shutdown, got %v", err) }
+}
+
+type fakeManager struct{ cert tls.Certificate }
+
+func (m *fakeManager) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) { return &m.cert, nil }
+
+func (m *fakeManager) HTTPHandler(fallback http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if strings.HasPrefix(r.URL.Path, "/.well-known/acme-challenge/") {
+            io.WriteString(w, "token")
+            return
+        }
+        fallback.ServeHTTP(w, r)
+    })
+}
+
+func TestRunTLSManager(t *testing.T) {
+    certFile, keyFile := selfSigned(t, t.TempDir())
+    cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+    if err != nil { t.Fatal(err) }
+    addr, httpAddr := freeAddr(t), freeAddr(t)
+    srv := &http.Server{Addr: addr, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "secure") })}
+
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan error, 1)
+    go func() { done <- RunTLS(ctx, srv, TLS{Manager: &fakeManager{cert: cert}, HTTPAddr: httpAddr}) }()
+
+    resp := get(t, insecure, "https://"+addr+"/")
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusOK { t.Fatalf("expected 200 over TLS, got %d", resp.StatusCode) }
+
+    resp = get(t, insecure, "http://"+httpAddr+"/.well-known/acme-challenge/abc")
+    body, _ := io.ReadAll(resp.Body)
+    resp.Body.Close()
+    if string(body) != "token" { t.Fatalf("expected challenge response, got %q", body) }
+
+    resp = get(t, insecure, "http://"+httpAddr+"/users?page=2")
+    resp.Body.Close()
+    if loc := resp.Header.Get("Location"); resp.StatusCode != http.StatusMovedPermanently || loc != "https://127.0.0.1/users?page=2" {
+        t.Fatalf("expected redirect to HTTPS, got %d %q", resp.StatusCode, loc)
+    }
+
+    cancel()
+    if err := <-done; err != nil { t.Fatalf("expected clean shutdown, got %v", err) }
+}
+
+func TestRunTLSHTTP2(t *testing.T) {
+    certFile, keyFile := selfSigned(t, t.TempDir())
+    h2 := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, ForceAttemptHTTP2: true}}
+    cases := []struct {
+        name   string
+        config *tls.Config
+        proto  int
+    }{
+        {"default", nil, 2},
+        {"h2 listed", &tls.Config{NextProtos: []string{"h2"}}, 2},
+        {"h2 not listed", &tls.Config{MinVersion: tls.VersionTLS12}, 1},
+    }
+    for _, c := range cases {
+        addr := freeAddr(t)
+        srv := &http.Server{Addr: addr, TLSConfig: c.config, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+        ctx, cancel := context.WithCancel(context.Background())
+        done := make(chan error, 1)
+        go func() { done <- RunTLS(ctx, srv, TLS{CertFile: certFile, KeyFile: keyFile}) }()
+
+        resp := get(t, h2, "https://"+addr+"/")
+        resp.Body.Close()
+        if resp.ProtoMajor != c.proto { t.Fatalf("%s: expected HTTP/%d, got %s", c.name, c.proto, resp.Proto) }
+        cancel()
+        if err := <-done; err != nil { t.Fatalf("%s: expected clean shutdown, got %v", c.name, err) }
+        h2.CloseIdleConnections()
+    }
+}
+
+func TestRunTLSManagerHTTPHandler(t *testing.T) {
+    addr, httpAddr := freeAddr(t), freeAddr(t)
+    plain := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "plain "+r.URL.Path) })
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan error, 1)
+    go func() { done <- RunTLS(ctx, &http.Server{Addr: addr}, TLS{Manager: &fakeManager{}, HTTPAddr: httpAddr, HTTPHandler: plain}) }()
+
+    for path, want := range map[string]string{"/.well-known/acme-challenge/abc": "token", "/status": "plain /status"} {
+        resp := get(t, insecure, "http://"+httpAddr+path)
+        body, _ := io.ReadAll(resp.Body)
+        resp.Body.Close()
+        if string(body) != want { t.Fatalf("%s: expected %q, got %q", path, want, body) }
+    }
+    cancel()
+    if err := <-done; err != nil { t.Fatalf("expected clean shutdown, got %v", err) }
+}
+
+func TestRunTLSChallengeListenerFailsFast(t *testing.T) {
+    busy, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil { t.Fatal(err) }
+    defer busy.Close()
+    config := &tls.Config{MinVersion: tls.VersionTLS12}
+    srv := &http.Server{Addr: freeAddr(t), TLSConfig: config}
+
+    done := make(chan error, 1)
+    go func() { done <- RunTLS(context.Background(), srv, TLS{Manager: &fakeManager{}, HTTPAddr: busy.Addr().String()}) }()
+    select {
+    case err := <-done:
+        if err == nil { t.Fatal("expected challenge listener error") }
+    case <-time.After(2 * time.Second):
+        t.Fatal("RunTLS did not return on challenge listener error")
+    }
+    if srv.TLSConfig != config || config.GetCertificate != nil || len(config.NextProtos) != 0 {
+        t.Fatal("RunTLS modified srv.TLSConfig")
+    }
+}
+
+func TestRunTLSLeavesServerUntouched(t *testing.T) {
+    certFile, keyFile := selfSigned(t, t.TempDir())
+    config := &tls.Config{MinVersion: tls.VersionTLS12}
+    srv := &http.Server{Addr: freeAddr(t), TLSConfig: config, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan error, 1)
+    addr := srv.Addr
+    go func() { done <- RunTLS(ctx, srv, TLS{CertFile: certFile, KeyFile: keyFile}) }()
+    get(t, insecure, "https://"+addr+"/").Body.Close()
+    cancel()
+    if err := <-done; err != nil { t.Fatalf("expected clean shutdown, got %v", err) }
+    if srv.Addr != addr || srv.TLSConfig != config || len(config.Certificates) != 0 {
+        t.Fatal("RunTLS modified srv")
+    }
+}
+
+func TestRunTLSRequiresCertificates(t *testing.T) {
+    if err := RunTLS(context.Background(), &http.Server{}, TLS{CertFile: "cert.pem"}); err == nil {
+        t.Fatal("expected error without a key file")
+    }
+}
+
+func TestRedirectHTTPS(t *testing.T) {
+    rr := httptest.NewRecorder()
+    RedirectHTTPS().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "http://example.com:8080/a?b=c", nil))
+    if got := rr.Header().Get("Location"); got != "https://example.com/a?b=c" { t.Fatalf("unexpected Location %q", got) }
+}
@@ -0,0 +1,200 @@
+package session
+
+import (
+    "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/binary"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "sync"
+    "time"
+)
+
+// Backend is a key-value store with expiry that server-side sessions are
+// kept in. Adapting Redis takes a few lines:
+//  func (b redisBackend) Get(ctx context.Context, key string) ([]byte, error) {
+//      v, err := b.c.Get(ctx, "session:"+key).Bytes()
+//      if errors.Is(err, redis.Nil) { return nil, session.ErrNotFound }
+//      return v, err
+//  }
+//  func (b redisBackend) Set(ctx context.Context, key string, v []byte, ttl time.Duration) error {
+//      return b.c.Set(ctx, "session:"+key, v, ttl).Err()
+//  }
+//  func (b redisBackend) Delete(ctx context.Context, key string) error {
+//      return b.c.Del(ctx, "session:"+key).Err()
+//  }
+type Backend interface {
+    // Get returns the value for key, or ErrNotFound if missing or expired.
+    Get(ctx context.Context, key string) ([]byte, error)
+    Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+    Delete(ctx context.Context, key string) error
+}
+
+// BackendStore is a Store keeping sessions in a Backend under random
+// 256-bit IDs; the cookie carries only the ID.
+type BackendStore struct {
+    b Backend
+}
+
+// NewBackendStore creates a server-side store on b.
+func NewBackendStore(b Backend) *BackendStore { return &BackendStore{b: b} }
+
+// NewMemoryStore creates a server-side store kept in process memory.
+func NewMemoryStore() *BackendStore { return NewBackendStore(NewMemoryBackend()) }
+
+// NewFileStore creates a server-side store keeping one file per session in
+// dir, which is created if needed.
+func NewFileStore(dir string) (*BackendStore, error) {
+    b, err := NewFileBackend(dir)
+    if err != nil { return nil, err }
+    return NewBackendStore(b), nil
+}
+
+// Load implements Store.
+func (s *BackendStore) Load(ctx context.Context, token string) (map[string]any, error) {
+    data, err := s.b.Get(ctx, token)
+    if err != nil { return nil, err }
+    values, err := decode(data)
+    if err != nil { return nil, fmt.Errorf("session: decoding %w", err) }
+    return values, nil
+}
+
+// Save implements Store.
+func (s *BackendStore) Save(ctx context.Context, token string, values map[string]any, ttl time.Duration) (string, error) {
+    if token == "" {
+        id := make([]byte, 32)
+        if _, err := rand.Read(id); err != nil { return "", err }
+        token = base64.RawURLEncoding.EncodeToString(id)
+    }
+    data, err := encode(values)
+    if err != nil { return "", err }
+    if err := s.b.Set(ctx, token, data, ttl); err != nil { return "", err }
+    return token, nil
+}
+
+// Delete implements Store.
+func (s *BackendStore) Delete(ctx context.Context, token string) error { return s.b.Delete(ctx, token) }
+
+// MemoryBackend is an in-process Backend. Expired entries are dropped when
+// read and swept every 1024 writes.
+type MemoryBackend struct {
+    mu     sync.Mutex
+    items  map[string]memoryItem
+    writes int
+}
+
+type memoryItem struct {
+    value   []byte
+    expires time.Time
+}
+
+// NewMemoryBackend creates an empty MemoryBackend.
+func NewMemoryBackend() *MemoryBackend { return &MemoryBackend{items: map[string]memoryItem{}} }
+
+// Get implements Backend.
+func (b *MemoryBackend) Get(_ context.Context, key string) ([]byte, error) {
+    b.mu.Lock(); defer b.mu.Unlock()
+    it, ok := b.items[key]
+    if !ok || !time.Now().Before(it.expires) {
+        delete(b.items, key)
+        return nil, ErrNotFound
+    }
+    return it.value, nil
+}
+
+// Set implements Backend.
+func (b *MemoryBackend) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+    b.mu.Lock(); defer b.mu.Unlock()
+    now := time.Now()
+    if b.writes++; b.writes%1024 == 0 {
+        for k, it := range b.items {
+            if !now.Before(it.expires) { delete(b.items, k) }
+        }
+    }
+    b.items[key] = memoryItem{value: value, expires: now.Add(ttl)}
+    return nil
+}
+
+// Delete implements Backend.
+func (b *MemoryBackend) Delete(_ context.Context, key string) error {
+    b.mu.Lock(); defer b.mu.Unlock()
+    delete(b.items, key)
+    return nil
+}
+
+// FileBackend is a Backend keeping each entry in a file named by the hash of
+// its key, prefixed with its expiry. Expired files are removed when read;
+// remove stale ones periodically with Sweep.
+type FileBackend struct {
+    dir string
+}
+
+// NewFileBackend creates a FileBackend in dir.
+func NewFileBackend(dir string) (*FileBackend, error) {
+    if err := os.MkdirAll(dir, 0o700); err != nil { return nil, fmt.Errorf("session: %w", err) }
+    return &FileBackend{dir: dir}, nil
+}
+
+// path hashes key so that client-supplied tokens never reach the filesystem.
+func (b *FileBackend) path(key string) string {
+    sum := sha256.Sum256([]byte(key))
+    return filepath.Join(b.dir, hex.EncodeToString(sum[:])+".session")
+}
+
+// Get implements Backend.
+func (b *FileBackend) Get(_ context.Context, key string) ([]byte, error) {
+    p := b.path(key)
+    data, err := os.ReadFile(p)
+    if errors.Is(err, fs.ErrNotExist) { return nil, ErrNotFound }
+    if err != nil { return nil, err }
+    if len(data) < 8 || time.Now().UnixNano() >= int64(binary.BigEndian.Uint64(data)) {
+        _ = os.Remove(p)
+        return nil, ErrNotFound
+    }
+    return data[8:], nil
+}
+
+// Set implements Backend, writing through a temporary file so readers never
+// see a partial session.
+func (b *FileBackend) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+    f, err := os.CreateTemp(b.dir, ".tmp-*")
+    if err != nil { return err }
+    buf := binary.BigEndian.AppendUint64(make([]byte, 0, 8+len(value)), uint64(time.Now().Add(ttl).UnixNano()))
+    _, err = f.Write(append(buf, value...))
+    if cerr := f.Close(); err == nil { err = cerr }
+    if err == nil { err = os.Rename(f.Name(), b.path(key)) }
+    if err != nil { _ = os.Remove(f.Name()) }
+    return err
+}
+
+// Delete implements Backend.
+func (b *FileBackend) Delete(_ context.Context, key string) error {
+    err := os.Remove(b.path(key))
+    if errors.Is(err, fs.ErrNotExist) { return nil }
+    return err
+}
+
+// Sweep removes expired session files.
+func (b *FileBackend) Sweep() error {
+    entries, err := os.ReadDir(b.dir)
+    if err != nil { return err }
+    now := time.Now().UnixNano()
+    for _, e := range entries {
+        if filepath.Ext(e.Name()) != ".session" { continue }
+        p := filepath.Join(b.dir, e.Name())
+        f, err := os.Open(p)
+        if err != nil { continue }
+        var exp [8]byte
+        _, err = io.ReadFull(f, exp[:])
+        f.Close()
+        if err != nil || now >= int64(binary.BigEndian.Uint64(exp[:])) { _ = os.Remove(p) }
+    }
+    return nil
+}
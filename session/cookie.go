@@ -0,0 +1,79 @@
+package session
+
+import (
+    "context"
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/rand"
+    "encoding/base64"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "time"
+)
+
+// MaxCookieSize is the largest token CookieStore produces; browsers drop
+// cookies above about 4KB.
+const MaxCookieSize = 4000
+
+// ErrTooLarge is returned by CookieStore.Save when the encoded session does
+// not fit in a cookie.
+var ErrTooLarge = errors.New("session: too large for a cookie")
+
+// CookieStore keeps the whole session in the cookie, encrypted and
+// authenticated with AES-GCM. Nothing is stored server-side, so Delete cannot
+// revoke a copied cookie before it expires; use a server-side store when that
+// matters.
+type CookieStore struct {
+    aeads []cipher.AEAD
+}
+
+// NewCookieStore creates a store from one or more 16-, 24- or 32-byte AES
+// keys. The first key encrypts; all of them decrypt, so keys can be rotated
+// by prepending a new one.
+func NewCookieStore(keys ...[]byte) (*CookieStore, error) {
+    if len(keys) == 0 { return nil, errors.New("session: cookie store needs a key") }
+    s := &CookieStore{}
+    for _, k := range keys {
+        block, err := aes.NewCipher(k)
+        if err != nil { return nil, fmt.Errorf("session: %w", err) }
+        aead, err := cipher.NewGCM(block)
+        if err != nil { return nil, err }
+        s.aeads = append(s.aeads, aead)
+    }
+    return s, nil
+}
+
+// Load implements Store.
+func (s *CookieStore) Load(_ context.Context, token string) (map[string]any, error) {
+    raw, err := base64.RawURLEncoding.DecodeString(token)
+    if err != nil { return nil, ErrNotFound }
+    for _, aead := range s.aeads {
+        n := aead.NonceSize()
+        if len(raw) < n { break }
+        plain, err := aead.Open(nil, raw[:n], raw[n:], nil)
+        if err != nil { continue }
+        if len(plain) < 8 || time.Now().Unix() >= int64(binary.BigEndian.Uint64(plain)) { return nil, ErrNotFound }
+        values, err := decode(plain[8:])
+        if err != nil { return nil, ErrNotFound }
+        return values, nil
+    }
+    return nil, ErrNotFound
+}
+
+// Save implements Store; the returned token is the encrypted values.
+func (s *CookieStore) Save(_ context.Context, _ string, values map[string]any, ttl time.Duration) (string, error) {
+    data, err := encode(values)
+    if err != nil { return "", err }
+    plain := binary.BigEndian.AppendUint64(make([]byte, 0, 8+len(data)), uint64(time.Now().Add(ttl).Unix()))
+    plain = append(plain, data...)
+    aead := s.aeads[0]
+    nonce := make([]byte, aead.NonceSize())
+    if _, err := rand.Read(nonce); err != nil { return "", err }
+    token := base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, nil))
+    if len(token) > MaxCookieSize { return "", ErrTooLarge }
+    return token, nil
+}
+
+// Delete implements Store; it is a no-op since the data lives in the cookie.
+func (s *CookieStore) Delete(context.Context, string) error { return nil }
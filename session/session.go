@@ -0,0 +1,206 @@
+// Package session provides HTTP sessions kept either entirely in an
+// encrypted cookie (CookieStore) or server-side under a random ID
+// (MemoryStore, FileStore, or any Backend such as Redis). Handlers read the
+// request's session with FromContext; middleware.Session loads it on first
+// use and saves it only when it changed.
+package session
+
+import (
+    "bytes"
+    "context"
+    "encoding/gob"
+    "errors"
+    "sync"
+    "time"
+)
+
+// ErrNotFound is returned by Store.Load for missing, expired, or tampered
+// sessions.
+var ErrNotFound = errors.New("session: not found")
+
+// Store loads and saves session values by the token kept in the cookie.
+// Values are encoded with encoding/gob; register custom types with
+// gob.Register.
+type Store interface {
+    // Load returns the values saved under token, or ErrNotFound.
+    Load(ctx context.Context, token string) (map[string]any, error)
+    // Save stores values for ttl and returns the token for the cookie. An
+    // empty token asks for a new session.
+    Save(ctx context.Context, token string, values map[string]any, ttl time.Duration) (string, error)
+    // Delete removes the session saved under token.
+    Delete(ctx context.Context, token string) error
+}
+
+// Session is the state of one client's session. It is safe for concurrent
+// use by the goroutines serving a request.
+type Session struct {
+    ctx   context.Context
+    store Store
+
+    mu         sync.Mutex
+    token      string
+    values     map[string]any
+    loaded     bool
+    err        error
+    modified   bool
+    destroyed  bool
+    regenerate bool
+}
+
+// Load returns the session for the cookie value token (empty for a new
+// client). Nothing is read from store until the session is first used.
+func Load(ctx context.Context, store Store, token string) *Session {
+    return &Session{ctx: ctx, store: store, token: token}
+}
+
+// load fetches the values on first use; s.mu must be held. A token the store
+// does not know is dropped so that clients cannot choose their session ID.
+func (s *Session) load() {
+    if s.loaded { return }
+    s.loaded = true
+    if s.token != "" {
+        v, err := s.store.Load(s.ctx, s.token)
+        switch {
+        case err == nil:
+            s.values = v
+        case errors.Is(err, ErrNotFound):
+            s.token = ""
+        default:
+            s.err = err
+        }
+    }
+    if s.values == nil { s.values = map[string]any{} }
+}
+
+// Get returns the value stored under key, or nil.
+func (s *Session) Get(key string) any {
+    s.mu.Lock(); defer s.mu.Unlock()
+    s.load()
+    return s.values[key]
+}
+
+// GetString returns the string stored under key, or "".
+func (s *Session) GetString(key string) string {
+    v, _ := s.Get(key).(string)
+    return v
+}
+
+// Pop returns the value stored under key and removes it, e.g. for flash
+// messages.
+func (s *Session) Pop(key string) any {
+    s.mu.Lock(); defer s.mu.Unlock()
+    s.load()
+    v, ok := s.values[key]
+    if ok {
+        delete(s.values, key)
+        s.modified = true
+    }
+    return v
+}
+
+// Set stores v under key.
+func (s *Session) Set(key string, v any) {
+    s.mu.Lock(); defer s.mu.Unlock()
+    s.load()
+    s.values[key] = v
+    s.modified = true
+}
+
+// Delete removes key.
+func (s *Session) Delete(key string) {
+    s.mu.Lock(); defer s.mu.Unlock()
+    s.load()
+    if _, ok := s.values[key]; ok {
+        delete(s.values, key)
+        s.modified = true
+    }
+}
+
+// Keys returns the stored keys in no particular order.
+func (s *Session) Keys() []string {
+    s.mu.Lock(); defer s.mu.Unlock()
+    s.load()
+    keys := make([]string, 0, len(s.values))
+    for k := range s.values { keys = append(keys, k) }
+    return keys
+}
+
+// Regenerate moves the values to a new session ID and deletes the old one.
+// Call it when privileges change, e.g. on login, to prevent session fixation.
+func (s *Session) Regenerate() {
+    s.mu.Lock(); defer s.mu.Unlock()
+    s.load()
+    s.regenerate = true
+    s.modified = true
+}
+
+// Destroy deletes the session and expires its cookie.
+func (s *Session) Destroy() {
+    s.mu.Lock(); defer s.mu.Unlock()
+    s.loaded = true
+    s.values = map[string]any{}
+    s.destroyed = true
+    s.modified = true
+}
+
+// Err returns the error, if any, from loading the session. The session then
+// behaves as empty, and Commit refuses to save it over the stored values.
+func (s *Session) Err() error {
+    s.mu.Lock(); defer s.mu.Unlock()
+    return s.err
+}
+
+// Modified reports whether the session changed and must be committed.
+func (s *Session) Modified() bool {
+    s.mu.Lock(); defer s.mu.Unlock()
+    return s.modified
+}
+
+// Commit saves a modified session for ttl and returns the token for the
+// cookie, or "" after Destroy. If loading failed it returns that error
+// without saving, since the stored values would otherwise be overwritten by
+// the partial ones; a destroyed session is still deleted.
+func (s *Session) Commit(ctx context.Context, ttl time.Duration) (string, error) {
+    s.mu.Lock(); defer s.mu.Unlock()
+    if s.err != nil && !s.destroyed { return "", s.err }
+    old := s.token
+    if s.destroyed || s.regenerate {
+        s.token = ""
+        if old != "" {
+            if err := s.store.Delete(ctx, old); err != nil { return "", err }
+        }
+        if s.destroyed {
+            s.modified = false
+            return "", nil
+        }
+    }
+    token, err := s.store.Save(ctx, s.token, s.values, ttl)
+    if err != nil { return "", err }
+    s.token, s.modified, s.regenerate = token, false, false
+    return token, nil
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying s.
+func NewContext(ctx context.Context, s *Session) context.Context {
+    return context.WithValue(ctx, contextKey{}, s)
+}
+
+// FromContext returns the session stored by middleware.Session, or nil.
+func FromContext(ctx context.Context) *Session {
+    s, _ := ctx.Value(contextKey{}).(*Session)
+    return s
+}
+
+func encode(values map[string]any) ([]byte, error) {
+    var buf bytes.Buffer
+    if err := gob.NewEncoder(&buf).Encode(values); err != nil { return nil, err }
+    return buf.Bytes(), nil
+}
+
+func decode(b []byte) (map[string]any, error) {
+    var values map[string]any
+    if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&values); err != nil { return nil, err }
+    return values, nil
+}
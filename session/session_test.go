@@ -0,0 +1,103 @@
+package session
+
+import (
+    "context"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestStoresRoundTrip(t *testing.T) {
+    ctx := context.Background()
+    cookies, err := NewCookieStore([]byte("0123456789abcdef"))
+    if err != nil { t.Fatal(err) }
+    files, err := NewFileStore(t.TempDir())
+    if err != nil { t.Fatal(err) }
+    for name, store := range map[string]Store{"cookie": cookies, "memory": NewMemoryStore(), "file": files} {
+        token, err := store.Save(ctx, "", map[string]any{"user": "alice", "n": 3}, time.Hour)
+        if err != nil || token == "" { t.Fatalf("%s: save: %q %v", name, token, err) }
+        v, err := store.Load(ctx, token)
+        if err != nil || v["user"] != "alice" || v["n"] != 3 { t.Fatalf("%s: load: %v %v", name, v, err) }
+
+        if _, err := store.Load(ctx, "bogus"); !errors.Is(err, ErrNotFound) { t.Fatalf("%s: expected ErrNotFound for unknown token, got %v", name, err) }
+        expired, _ := store.Save(ctx, "", map[string]any{"user": "bob"}, -time.Second)
+        if _, err := store.Load(ctx, expired); !errors.Is(err, ErrNotFound) { t.Fatalf("%s: expected expired session to be gone, got %v", name, err) }
+    }
+}
+
+func TestCookieStoreTamperingAndRotation(t *testing.T) {
+    ctx := context.Background()
+    oldKey, newKey := []byte("0123456789abcdef"), []byte("fedcba9876543210")
+    old, _ := NewCookieStore(oldKey)
+    token, _ := old.Save(ctx, "", map[string]any{"user": "alice"}, time.Hour)
+
+    flipped := []byte(token)
+    flipped[len(flipped)/2] ^= 1
+    if _, err := old.Load(ctx, string(flipped)); !errors.Is(err, ErrNotFound) { t.Fatalf("expected tampered cookie rejected, got %v", err) }
+
+    rotated, _ := NewCookieStore(newKey, oldKey)
+    if v, err := rotated.Load(ctx, token); err != nil || v["user"] != "alice" { t.Fatalf("expected old-key cookie accepted after rotation, got %v %v", v, err) }
+    if _, err := NewCookieStore([]byte("short")); err == nil { t.Fatal("expected invalid key size rejected") }
+    if _, err := old.Save(ctx, "", map[string]any{"blob": strings.Repeat("x", MaxCookieSize)}, time.Hour); !errors.Is(err, ErrTooLarge) {
+        t.Fatalf("expected ErrTooLarge, got %v", err)
+    }
+}
+
+func TestSessionLifecycle(t *testing.T) {
+    ctx := context.Background()
+    store := NewMemoryStore()
+
+    s := Load(ctx, store, "attacker-chosen")
+    s.Set("user", "alice")
+    token, err := s.Commit(ctx, time.Hour)
+    if err != nil || token == "" || token == "attacker-chosen" { t.Fatalf("expected a fresh token, got %q %v", token, err) }
+
+    s = Load(ctx, store, token)
+    if s.Modified() { t.Fatal("loading must not mark the session modified") }
+    if s.GetString("user") != "alice" { t.Fatalf("unexpected user %v", s.Get("user")) }
+    s.Regenerate()
+    next, err := s.Commit(ctx, time.Hour)
+    if err != nil || next == token { t.Fatalf("expected a new token after Regenerate, got %q %v", next, err) }
+    if _, err := store.Load(ctx, token); !errors.Is(err, ErrNotFound) { t.Fatal("old session must be deleted on Regenerate") }
+
+    s = Load(ctx, store, next)
+    s.Set("flash", "saved")
+    if s.Pop("flash") != "saved" || s.Get("flash") != nil { t.Fatal("Pop must return and remove the value") }
+    s.Destroy()
+    if gone, err := s.Commit(ctx, time.Hour); err != nil || gone != "" { t.Fatalf("expected empty token after Destroy, got %q %v", gone, err) }
+    if _, err := store.Load(ctx, next); !errors.Is(err, ErrNotFound) { t.Fatal("destroyed session must be deleted") }
+}
+
+// flakyStore fails Load with a transient error.
+type flakyStore struct {
+    Store
+    err error
+}
+
+func (f flakyStore) Load(context.Context, string) (map[string]any, error) { return nil, f.err }
+
+func TestCommitAfterLoadError(t *testing.T) {
+    ctx := context.Background()
+    mem := NewMemoryStore()
+    token, _ := mem.Save(ctx, "", map[string]any{"user": "alice", "cart": 3}, time.Hour)
+
+    down := errors.New("connection refused")
+    s := Load(ctx, flakyStore{Store: mem, err: down}, token)
+    s.Set("seen", true)
+    if !errors.Is(s.Err(), down) { t.Fatalf("expected load error, got %v", s.Err()) }
+    if _, err := s.Commit(ctx, time.Hour); !errors.Is(err, down) { t.Fatalf("expected Commit to return the load error, got %v", err) }
+    if v, err := mem.Load(ctx, token); err != nil || v["user"] != "alice" || v["cart"] != 3 || v["seen"] != nil {
+        t.Fatalf("stored session must be left intact, got %v %v", v, err)
+    }
+}
+
+func TestFileBackendSweep(t *testing.T) {
+    ctx := context.Background()
+    b, _ := NewFileBackend(t.TempDir())
+    b.Set(ctx, "live", []byte("a"), time.Hour)
+    b.Set(ctx, "dead", []byte("b"), -time.Second)
+    if err := b.Sweep(); err != nil { t.Fatal(err) }
+    if _, err := b.Get(ctx, "live"); err != nil { t.Fatalf("live entry swept: %v", err) }
+    if err := b.Delete(ctx, "dead"); err != nil { t.Fatalf("deleting a swept entry should succeed, got %v", err) }
+}